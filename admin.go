@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// adminPath is the path of the admin dashboard; its pages require the admin scope.
+const adminPath = "/admin"
+
+// Limits of the dashboard views.
+const (
+	adminRecentRuns       = 20
+	adminArticlePageSize  = 20
+	adminArticleScanLimit = 500  // Articles scanned per page of a keyword search
+	summaryQueueScanLimit = 1000 // The summarization queue is counted up to this many articles
+)
+
+// adminContentSecurityPolicy keeps the dashboard self-contained: inline styles only, no
+// scripts, images or frames, and forms posted to the server itself.
+const adminContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'"
+
+//go:embed templates/admin/*.html
+var adminTemplateFiles embed.FS
+
+// adminPages are the dashboard templates by page name, each parsed with the layout.
+var adminPages = parseAdminPages("dashboard", "run", "articles", "article")
+
+var adminTemplateFuncs = template.FuncMap{
+	"kst": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(seoulLocation()).Format("2006-01-02 15:04:05")
+	},
+	"since": func(from, to time.Time) string {
+		if to.IsZero() {
+			to = time.Now()
+		}
+		return to.Sub(from).Round(time.Second).String()
+	},
+	"percent": func(ratio float64) string {
+		return fmt.Sprintf("%.0f%%", ratio*100)
+	},
+	"summaryStatus": func(article NewsArticle) string {
+		switch {
+		case article.AISummary != "":
+			return SummaryStatusDone
+		case article.SummaryRetryCount > 0:
+			return fmt.Sprintf("%s (%d)", SummaryStatusRetrying, article.SummaryRetryCount)
+		}
+		return SummaryStatusPending
+	},
+}
+
+func parseAdminPages(names ...string) map[string]*template.Template {
+	pages := map[string]*template.Template{}
+	for _, name := range names {
+		pages[name] = template.Must(template.New("layout.html").Funcs(adminTemplateFuncs).
+			ParseFS(adminTemplateFiles, "templates/admin/layout.html", "templates/admin/"+name+".html"))
+	}
+	return pages
+}
+
+// renderAdmin renders a dashboard page.
+func renderAdmin(c *fiber.Ctx, page string, data interface{}) error {
+	var b bytes.Buffer
+	if err := adminPages[page].Execute(&b, data); err != nil {
+		return err
+	}
+	c.Set("Content-Security-Policy", adminContentSecurityPolicy)
+	c.Set("Cache-Control", "no-store")
+	c.Type("html", "utf-8")
+	return c.Send(b.Bytes())
+}
+
+// SummaryQueue counts the articles waiting for an AI summary.
+type SummaryQueue struct {
+	Pending  int  // Never tried
+	Retrying int  // Summarization failed at least once
+	Capped   bool // More articles are waiting than were counted
+}
+
+// summaryQueueDepth counts the articles without an AI summary, up to summaryQueueScanLimit.
+func summaryQueueDepth(ctx context.Context, store ArticleStore) (SummaryQueue, error) {
+	var queue SummaryQueue
+	noSummary := false
+	err := walkArticles(ctx, store, ArticleQuery{HasAISummary: &noSummary}, func(article *NewsArticle) (bool, error) {
+		if queue.Pending+queue.Retrying == summaryQueueScanLimit {
+			queue.Capped = true
+			return false, nil
+		}
+		if article.SummaryRetryCount > 0 {
+			queue.Retrying++
+		} else {
+			queue.Pending++
+		}
+		return true, nil
+	})
+	return queue, err
+}
+
+// searchArticles returns a page of the articles matching q whose title, summary or content
+// contain keyword. Keyword searches scan at most adminArticleScanLimit articles per page, so
+// a page may hold fewer matches while NextCursor continues the search.
+func searchArticles(ctx context.Context, store ArticleStore, q ArticleQuery, keyword string) (*ArticlePage, error) {
+	if q.Limit == 0 {
+		q.Limit = adminArticlePageSize
+	}
+	if keyword == "" {
+		return store.ListArticles(ctx, q)
+	}
+	filter := ArticleFilter{Keyword: keyword}
+	result := &ArticlePage{Articles: []NewsArticle{}}
+	size := q.Limit
+	q.Limit = MaxArticlePageSize
+	for scanned := 0; ; {
+		page, err := store.ListArticles(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, article := range page.Articles {
+			if len(result.Articles) == size || scanned == adminArticleScanLimit {
+				return result, nil
+			}
+			scanned++
+			result.NextCursor = articleCursor{CollectedAt: article.CollectedAt, DocID: articleDocID(article.URL)}.encode()
+			if filter.Matches(&article) {
+				result.Articles = append(result.Articles, article)
+			}
+		}
+		if page.NextCursor == "" {
+			result.NextCursor = ""
+			return result, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// startAdminCrawl starts a crawl of sourceID ("" for the Naver Finance main news) in the
+// background. Its progress and outcome are recorded in the run history.
+func startAdminCrawl(ctx context.Context, s *NewsCrawlerService, jobID, sourceID string, pages int) error {
+	job := s.Job(context.WithoutCancel(ctx), JobInfo{ID: jobID, Trigger: RunTriggerManual})
+	var def *SourceDefinition
+	if sourceID != "" {
+		var ok bool
+		if def, ok = job.Sources[sourceID]; !ok {
+			return fmt.Errorf("unknown source: %s", sourceID)
+		}
+	}
+	end, err := job.beginCrawl(sourceID)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer end()
+		if def == nil {
+			job.CrawlNaverFinanceNews(pages)
+		} else {
+			job.CrawlSource(def, pages)
+		}
+	}()
+	return nil
+}
+
+// fetchRawHTML fetches the page of an article again, converted to UTF-8: its raw HTML is not
+// stored, only the cleaned content. The request is left out of the crawl fetch metrics.
+func fetchRawHTML(ctx context.Context, s *NewsCrawlerService, articleURL string) (string, error) {
+	live := *s.Fetcher
+	live.Unobserved = true
+	body, contentType, err := live.fetch(ctx, articleURL, s.Config.ArticleFetchTimeout)
+	if err != nil {
+		return "", err
+	}
+	html, err := io.ReadAll(decodeBody(body, contentType))
+	if err != nil {
+		return "", fmt.Errorf("error decoding %s: %v", articleURL, err)
+	}
+	return string(html), nil
+}
+
+// adminSameOrigin reports whether a form post comes from a page of this server. Browsers send
+// the stored HTTP Basic credentials along with forms posted from any site, so the dashboard
+// checks the Origin (or Referer) header of its posts.
+func adminSameOrigin(c *fiber.Ctx) bool {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		origin = c.Get(fiber.HeaderReferer)
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == c.Hostname()
+}
+
+// adminNotice returns the message of the action the dashboard was redirected from.
+func adminNotice(c *fiber.Ctx) string {
+	switch c.Query("notice") {
+	case "crawl-started":
+		return fmt.Sprintf("Crawl of %s started.", c.Query("source"))
+	case "crawl-busy":
+		return fmt.Sprintf("A crawl of %s is already running.", c.Query("source"))
+	case "shutting-down":
+		return "The service is shutting down: no crawl was started."
+	case "requeued":
+		return fmt.Sprintf("%s failed summaries requeued.", c.Query("count"))
+	}
+	return ""
+}
+
+// registerAdminRoutes registers the admin dashboard: recent and running crawl runs, parser
+// health, fetch statistics per host, the summarization queue and an article browser, with
+// forms to trigger crawls and requeue failed summaries.
+func registerAdminRoutes(app *fiber.App, s *NewsCrawlerService) {
+	app.Get(adminPath, func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		data := struct {
+			Notice         string
+			ShuttingDown   bool
+			RunningSources []string
+			InProgress     []CrawlRun
+			Runs           []CrawlRun
+			RunsError      string
+			Health         []*ParserHealth // By source
+			Hosts          []HostFetchStats
+			Queue          SummaryQueue
+			QueueError     string
+			Sources        []string
+		}{
+			Notice:         adminNotice(c),
+			ShuttingDown:   s.ShuttingDown(),
+			RunningSources: s.runningCrawls(),
+			Health:         s.ParserHealthReports(),
+			Sources:        []string{NaverMainNewsSourceID},
+		}
+		var err error
+		if data.InProgress, err = s.Runs.ListRuns(ctx, RunQuery{Outcome: RunOutcomeRunning, From: time.Now().Add(-24 * time.Hour)}); err == nil {
+			data.Runs, err = s.Runs.ListRuns(ctx, RunQuery{Limit: adminRecentRuns})
+		}
+		if err != nil {
+			data.RunsError = err.Error()
+		}
+		if data.Hosts, err = fetchStatsByHost(); err != nil {
+			requestLogger(c).Warn("Fetch statistics unavailable", LogKeyError, err)
+		}
+		if data.Queue, err = summaryQueueDepth(ctx, s.Store); err != nil {
+			data.QueueError = err.Error()
+		}
+		var sources []string
+		for id := range s.Snapshot().Sources {
+			sources = append(sources, id)
+		}
+		sort.Strings(sources)
+		data.Sources = append(data.Sources, sources...)
+		return renderAdmin(c, "dashboard", data)
+	})
+
+	app.Get(adminPath+"/runs/:id", func(c *fiber.Ctx) error {
+		run, err := s.Runs.GetRun(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return err
+		}
+		if run == nil {
+			return fiber.NewError(fiber.StatusNotFound, "Run not found.")
+		}
+		return renderAdmin(c, "run", run)
+	})
+
+	app.Get(adminPath+"/articles", func(c *fiber.Ctx) error {
+		data := struct {
+			Keyword, Source, Ticker, SummaryStatus string
+			Articles                               []NewsArticle
+			NextURL                                string
+			Error                                  string
+		}{Keyword: c.Query("q"), Source: c.Query("source"), Ticker: c.Query("ticker"), SummaryStatus: c.Query("summaryStatus")}
+		query, err := articleQueryFromRequest(c)
+		var page *ArticlePage
+		if err == nil {
+			page, err = searchArticles(c.UserContext(), s.Store, query, data.Keyword)
+		}
+		if err != nil {
+			data.Error = err.Error()
+			return renderAdmin(c, "articles", data)
+		}
+		data.Articles = page.Articles
+		if page.NextCursor != "" {
+			next := url.Values{}
+			for key, value := range c.Queries() {
+				next.Set(key, value)
+			}
+			next.Set("cursor", page.NextCursor)
+			data.NextURL = adminPath + "/articles?" + next.Encode()
+		}
+		return renderAdmin(c, "articles", data)
+	})
+
+	app.Get(adminPath+"/article", func(c *fiber.Ctx) error {
+		article, err := s.Store.GetArticle(c.UserContext(), c.Query("url"))
+		if err != nil {
+			return err
+		}
+		if article == nil {
+			return fiber.NewError(fiber.StatusNotFound, "Article not found.")
+		}
+		// The live page is fetched only on request (raw=1): not on every view of the page.
+		data := struct {
+			*NewsArticle
+			RawURL    string
+			RawLoaded bool
+			RawHTML   string
+			RawError  string
+		}{NewsArticle: article, RawLoaded: c.QueryBool("raw")}
+		data.RawURL = adminPath + "/article?" + url.Values{"url": {article.URL}, "raw": {"1"}}.Encode()
+		if data.RawLoaded {
+			if data.RawHTML, err = fetchRawHTML(c.UserContext(), s.Snapshot(), article.URL); err != nil {
+				data.RawError = err.Error()
+			}
+		}
+		return renderAdmin(c, "article", data)
+	})
+
+	app.Post(adminPath+"/crawl", func(c *fiber.Ctx) error {
+		if !adminSameOrigin(c) {
+			return fiber.NewError(fiber.StatusForbidden, "Cross-origin form post rejected.")
+		}
+		sourceID := c.FormValue("source")
+		pages, err := strconv.Atoi(c.FormValue("pages", "1"))
+		if err != nil || pages <= 0 || pages > 10 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid number of pages requested. Please specify within 1-10 pages.")
+		}
+		if sourceID == NaverMainNewsSourceID {
+			sourceID = ""
+		}
+		notice := "crawl-started"
+		switch err := startAdminCrawl(c.UserContext(), s, requestID(c), sourceID, pages); {
+		case errors.Is(err, errSourceBusy):
+			notice = "crawl-busy"
+		case errors.Is(err, errShuttingDown):
+			notice = "shutting-down"
+		case err != nil:
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return c.Redirect(adminPath+"/?"+url.Values{"notice": {notice}, "source": {c.FormValue("source")}}.Encode(), fiber.StatusSeeOther)
+	})
+
+	app.Post(adminPath+"/summaries/requeue", func(c *fiber.Ctx) error {
+		if !adminSameOrigin(c) {
+			return fiber.NewError(fiber.StatusForbidden, "Cross-origin form post rejected.")
+		}
+		result, err := RequeueFailedSummaries(c.UserContext(), s.Store, ArticleQuery{}, false)
+		if err != nil {
+			return err
+		}
+		requestLogger(c).Info("Failed summaries requeued from the admin dashboard", "articles", result.Updated)
+		return c.Redirect(adminPath+"/?"+url.Values{"notice": {"requeued"}, "count": {strconv.Itoa(result.Updated)}}.Encode(), fiber.StatusSeeOther)
+	})
+}
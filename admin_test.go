@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAdminDashboard(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ArticleStore = "memory"
+	s := NewNewsCrawlerService(cfg)
+	s.Store = NewMemoryArticleStore()
+	s.Runs = NewMemoryRunStore()
+	ctx := context.Background()
+	var fetched atomic.Int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=euc-kr")
+		w.Write([]byte("<html><body><div id=\"dic_area\">\xbf\xf8\xb9\xae</div></body></html>")) // 원문 in EUC-KR
+	}))
+	defer site.Close()
+	collected := time.Now()
+	for _, article := range []NewsArticle{
+		{URL: site.URL + "/a/1", Title: "반도체 수출 증가", Content: "원문 <b>본문</b>", ContentMarkdown: "**정제된** 본문", CollectedAt: collected},
+		{URL: "https://example.com/a/2", Title: "환율 하락", SummaryRetryCount: 3, CollectedAt: collected.Add(-time.Minute)},
+		{URL: "https://example.com/a/3", Title: "요약 완료", AISummary: "요약", CollectedAt: collected.Add(-2 * time.Minute)},
+	} {
+		if err := s.Store.SaveArticle(ctx, article); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := newCrawlRun(JobInfo{ID: "job-1", Trigger: RunTriggerScheduler, Schedule: "nightly"}, "naver-mainnews", RunParams{Pages: 1})
+	run.recordError(StageList, "https://example.com/list", errNewsListNotFound)
+	run.finish(&ParserHealth{Status: ParserStatusHealthy}, nil)
+	if err := s.Runs.SaveRun(ctx, *run); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(NewAuth(AuthConfig{
+		AnonymousScopes: []string{ScopeRead},
+		APIKeys:         []APIKeyConfig{{Name: "ops", Key: "admin-key-0123456789", Scopes: []string{ScopeAdmin}}},
+	}).Middleware())
+	registerAdminRoutes(app, s)
+	send := func(method, target string, form url.Values, header map[string]string) (*http.Response, string) {
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req := httptest.NewRequest(method, "http://crawler.example"+target, body)
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req.SetBasicAuth("admin", "admin-key-0123456789")
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, _ := io.ReadAll(resp.Body)
+		return resp, string(page)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/admin", nil))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Basic ") {
+		t.Errorf("anonymous GET /admin: %v, %v", resp, err)
+	}
+
+	resp, page := send("GET", "/admin/", nil, nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Security-Policy") == "" {
+		t.Fatalf("GET /admin/: %d %v", resp.StatusCode, resp.Header)
+	}
+	for _, want := range []string{run.ID, "outcome-empty", "scheduler nightly", "1 pending, 1 failed at least once"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("dashboard lacks %q", want)
+		}
+	}
+	if _, page := send("GET", "/admin/runs/"+run.ID, nil, nil); !strings.Contains(page, errNewsListNotFound.Error()) {
+		t.Errorf("run page lacks its error:\n%s", page)
+	}
+
+	_, page = send("GET", "/admin/articles?q=반도체", nil, nil)
+	if !strings.Contains(page, "반도체 수출 증가") || strings.Contains(page, "환율 하락") {
+		t.Errorf("keyword search:\n%s", page)
+	}
+	// The live page is fetched only through the re-fetch link, out of the fetch metrics.
+	articlePath := "/admin/article?url=" + url.QueryEscape(site.URL+"/a/1")
+	_, page = send("GET", articlePath, nil, nil)
+	if fetched.Load() != 0 || strings.Contains(page, "dic_area") || !strings.Contains(page, "raw=1") {
+		t.Errorf("article page fetched the live page (%d requests):\n%s", fetched.Load(), page)
+	}
+	before, _ := fetchStatsByHost()
+	_, page = send("GET", articlePath+"&raw=1", nil, nil)
+	for _, want := range []string{`&lt;div id=&#34;dic_area&#34;&gt;원문&lt;/div&gt;`, "re-fetched live", "**정제된** 본문", "원문 &lt;b&gt;본문&lt;/b&gt;"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("article page lacks %s:\n%s", want, page)
+		}
+	}
+	if after, _ := fetchStatsByHost(); fetched.Load() != 1 || !reflect.DeepEqual(after, before) {
+		t.Errorf("re-fetch: %d requests, host stats %+v, were %+v", fetched.Load(), after, before)
+	}
+
+	if resp, _ := send("POST", "/admin/summaries/requeue", url.Values{}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("requeue without Origin: %d, want 403", resp.StatusCode)
+	}
+	resp, _ = send("POST", "/admin/summaries/requeue", url.Values{}, map[string]string{"Origin": "http://crawler.example"})
+	if resp.StatusCode != http.StatusSeeOther || !strings.Contains(resp.Header.Get("Location"), "count=1") {
+		t.Errorf("requeue: %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if article, _ := s.Store.GetArticle(ctx, "https://example.com/a/2"); article.SummaryRetryCount != 0 {
+		t.Errorf("summary not requeued: %+v", article)
+	}
+
+	if resp, _ := send("POST", "/admin/crawl", url.Values{"source": {"missing"}, "pages": {"1"}}, map[string]string{"Origin": "http://crawler.example"}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("crawl of an unknown source: %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestFetchStatsByHost(t *testing.T) {
+	observeFetch("https://stats-test.example/a", time.Now().Add(-time.Second), 200)
+	observeFetch("https://stats-test.example/b", time.Now(), 503)
+	observeFetch("https://stats-test.example/c", time.Now(), 0)
+	stats, err := fetchStatsByHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range stats {
+		if host.Host == "stats-test.example" {
+			if host.Requests != 3 || host.Errors != 2 || host.AvgDuration < 300*time.Millisecond {
+				t.Errorf("stats-test.example: %+v", host)
+			}
+			return
+		}
+	}
+	t.Errorf("no stats for stats-test.example: %+v", stats)
+}
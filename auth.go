@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Scopes of the HTTP API. The admin scope includes the others.
+const (
+	ScopeRead  = "read"  // Articles, feeds, event stream, parser health, schedules
+	ScopeCrawl = "crawl" // POST /api/schedule/crawl
+	ScopeAdmin = "admin" // Webhook subscriptions, configuration, admin dashboard
+)
+
+// Authentication headers.
+const (
+	APIKeyHeader           = "X-API-Key"
+	RequestSignatureHeader = "X-Request-Signature" // keyId=<id>,t=<unix timestamp>,v1=<hex HMAC-SHA256>, see SignRequest
+)
+
+// minSecretLength is the minimum length of API keys and HMAC secrets.
+const minSecretLength = 16
+
+// maxSignatureSkew is how far the timestamp of a signed request may be from the server clock.
+const maxSignatureSkew = 5 * time.Minute
+
+// routeScopes maps routes to the scope they require, by method ("" for any) and lowercase
+// path prefix; the first match wins. Routes not listed (such as the media files) are public.
+var routeScopes = []struct {
+	method string
+	prefix string
+	scope  string
+}{
+	{"POST", "/api/schedule/crawl", ScopeCrawl},
+	{"", "/api/admin/", ScopeAdmin},
+	{"", adminPath, ScopeAdmin},
+	{"", "/api/webhooks", ScopeAdmin},
+	{"", "/api/", ScopeRead},
+	{"", "/feeds/", ScopeRead},
+	{"", "/metrics", ScopeRead},
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Name   string // API key name, HMAC key ID, service account email, or "anonymous"
+	Method string // apiKey, hmac, oidc or anonymous
+	Scopes []string
+}
+
+// HasScope reports whether the principal was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates requests with one kind of credentials.
+type Authenticator interface {
+	// Authenticate returns the principal of a request, nil when the request carries no
+	// credentials of this kind, or an error when its credentials are invalid.
+	Authenticate(c *fiber.Ctx) (*Principal, error)
+}
+
+// Auth is the authentication middleware of the HTTP API: requests are authenticated by the
+// first authenticator recognizing their credentials, then checked against routeScopes.
+type Auth struct {
+	Authenticators []Authenticator
+	Anonymous      *Principal // Principal of requests without credentials
+}
+
+// NewAuth creates the authenticators configured in cfg.
+func NewAuth(cfg AuthConfig) *Auth {
+	a := &Auth{Anonymous: &Principal{Name: "anonymous", Method: "anonymous", Scopes: cfg.AnonymousScopes}}
+	if len(cfg.APIKeys) > 0 {
+		a.Authenticators = append(a.Authenticators, NewAPIKeyAuthenticator(cfg.APIKeys))
+	}
+	if len(cfg.HMACKeys) > 0 {
+		a.Authenticators = append(a.Authenticators, NewHMACAuthenticator(cfg.HMACKeys))
+	}
+	if len(cfg.OIDC.ServiceAccounts) > 0 {
+		a.Authenticators = append(a.Authenticators, NewOIDCAuthenticator(cfg.OIDC))
+	}
+	return a
+}
+
+// Middleware checks the scope required by the route of each request. Requests without valid
+// credentials get 401 Unauthorized, authenticated callers lacking the scope 403 Forbidden.
+func (a *Auth) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Fiber routes case-insensitively, and with or without a trailing slash
+		path := strings.ToLower(c.Path())
+		scope := requiredScope(c.Method(), path)
+		if scope == "" || c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+		if strings.HasPrefix(path, adminPath) {
+			// Lets browsers prompt for an API key (see APIKeyAuthenticator).
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="News Crawler admin", charset="UTF-8"`)
+		}
+		principal, err := a.authenticate(c)
+		if err != nil {
+			componentLogger(LogComponentAuth).Warn("Rejected credentials", "method", c.Method(), "path", c.Path(), "ip", c.IP(),
+				LogKeyRequestID, requestID(c), LogKeyError, err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials."})
+		}
+		if !principal.HasScope(scope) {
+			if principal == a.Anonymous {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": fmt.Sprintf("Authentication required (scope %s).", scope)})
+			}
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": fmt.Sprintf("%s lacks the %s scope.", principal.Name, scope)})
+		}
+		c.Locals("principal", principal)
+		return c.Next()
+	}
+}
+
+func (a *Auth) authenticate(c *fiber.Ctx) (*Principal, error) {
+	for _, authenticator := range a.Authenticators {
+		principal, err := authenticator.Authenticate(c)
+		if err != nil || principal != nil {
+			return principal, err
+		}
+	}
+	return a.Anonymous, nil
+}
+
+// requiredScope returns the scope a route requires, or "" for a public route. path must be
+// lowercase.
+func requiredScope(method, path string) string {
+	for _, route := range routeScopes {
+		if (route.method == "" || route.method == method) && strings.HasPrefix(path, route.prefix) {
+			return route.scope
+		}
+	}
+	return ""
+}
+
+// APIKeyAuthenticator accepts static API keys in the X-API-Key header, or as the password of
+// HTTP Basic authentication (the user name is ignored), which browsers can send to the admin
+// dashboard.
+type APIKeyAuthenticator struct {
+	keys []APIKeyConfig
+}
+
+// NewAPIKeyAuthenticator creates an authenticator accepting keys.
+func NewAPIKeyAuthenticator(keys []APIKeyConfig) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeyAuthenticator) Authenticate(c *fiber.Ctx) (*Principal, error) {
+	key := c.Get(APIKeyHeader)
+	if key == "" {
+		key = basicAuthPassword(c.Get(fiber.HeaderAuthorization))
+	}
+	if key == "" {
+		return nil, nil
+	}
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+			return &Principal{Name: k.Name, Method: "apiKey", Scopes: k.Scopes}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown API key")
+}
+
+// basicAuthPassword returns the password of an HTTP Basic Authorization header, or "".
+func basicAuthPassword(header string) string {
+	encoded, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return ""
+	}
+	credentials, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	_, password, _ := strings.Cut(string(credentials), ":")
+	return password
+}
+
+// HMACAuthenticator accepts requests signed with a shared secret. The X-Request-Signature
+// header carries the key ID, a timestamp and the signature (see SignRequest); the timestamp
+// must be within 5 minutes of the server clock.
+type HMACAuthenticator struct {
+	keys map[string]HMACKeyConfig
+	now  func() time.Time
+}
+
+// NewHMACAuthenticator creates an authenticator accepting requests signed with keys.
+func NewHMACAuthenticator(keys []HMACKeyConfig) *HMACAuthenticator {
+	a := &HMACAuthenticator{keys: map[string]HMACKeyConfig{}, now: time.Now}
+	for _, key := range keys {
+		a.keys[key.ID] = key
+	}
+	return a
+}
+
+// SignRequest returns the hex HMAC-SHA256 of "<timestamp>.<METHOD>.<path with query>.<body>",
+// keyed with secret: the v1 signature of X-Request-Signature.
+func SignRequest(secret, timestamp, method, pathAndQuery string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + method + "." + pathAndQuery + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticate implements Authenticator.
+func (a *HMACAuthenticator) Authenticate(c *fiber.Ctx) (*Principal, error) {
+	header := c.Get(RequestSignatureHeader)
+	if header == "" {
+		return nil, nil
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[name] = value
+	}
+	key, ok := a.keys[params["keyId"]]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", params["keyId"])
+	}
+	timestamp, err := strconv.ParseInt(params["t"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature timestamp")
+	}
+	if skew := a.now().Sub(time.Unix(timestamp, 0)); skew > maxSignatureSkew || skew < -maxSignatureSkew {
+		return nil, fmt.Errorf("signature timestamp is %v away from the server clock", skew.Round(time.Second))
+	}
+	want := SignRequest(key.Secret, params["t"], c.Method(), string(c.Request().URI().RequestURI()), c.Body())
+	if !hmac.Equal([]byte(params["v1"]), []byte(want)) {
+		return nil, fmt.Errorf("signature mismatch for key %q", key.ID)
+	}
+	return &Principal{Name: key.ID, Method: "hmac", Scopes: key.Scopes}, nil
+}
+
+// OIDCAuthenticator accepts OIDC ID tokens (Authorization: Bearer <token>), such as those
+// Google issues to service accounts (e.g. Cloud Scheduler with an OIDC token). The token must
+// be signed by a key of the issuer's JWKS, be issued for the configured audience, and belong
+// to a listed account with a verified email.
+type OIDCAuthenticator struct {
+	config   OIDCConfig
+	accounts map[string][]string // Email -> scopes
+	client   *http.Client
+
+	mu   sync.Mutex
+	jwks *keyfunc.JWKS // Fetched on first use, then refreshed hourly and on unknown key IDs
+}
+
+// NewOIDCAuthenticator creates an authenticator accepting the ID tokens of cfg.ServiceAccounts.
+func NewOIDCAuthenticator(cfg OIDCConfig) *OIDCAuthenticator {
+	a := &OIDCAuthenticator{config: cfg, accounts: map[string][]string{}, client: &http.Client{Timeout: 10 * time.Second}}
+	for _, account := range cfg.ServiceAccounts {
+		a.accounts[strings.ToLower(account.Email)] = account.Scopes
+	}
+	return a
+}
+
+// oidcClaims are the claims of an ID token checked by OIDCAuthenticator.
+type oidcClaims struct {
+	jwt.RegisteredClaims
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+func (a *OIDCAuthenticator) keys() (*keyfunc.JWKS, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.jwks == nil {
+		jwks, err := keyfunc.Get(a.config.JWKSURL, keyfunc.Options{
+			Client:            a.client,
+			RefreshInterval:   time.Hour,
+			RefreshRateLimit:  5 * time.Minute,
+			RefreshUnknownKID: true,
+			RefreshErrorHandler: func(err error) {
+				componentLogger(LogComponentAuth).Warn("Error refreshing the OIDC keys", "jwks_url", a.config.JWKSURL, LogKeyError, err)
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error fetching the OIDC keys from %s: %v", a.config.JWKSURL, err)
+		}
+		a.jwks = jwks
+	}
+	return a.jwks, nil
+}
+
+// Authenticate implements Authenticator.
+func (a *OIDCAuthenticator) Authenticate(c *fiber.Ctx) (*Principal, error) {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return nil, nil
+	}
+	jwks, err := a.keys()
+	if err != nil {
+		return nil, err
+	}
+	var claims oidcClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, jwks.Keyfunc, jwt.WithValidMethods([]string{"RS256"})); err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+	if !claims.VerifyAudience(a.config.Audience, true) {
+		return nil, fmt.Errorf("ID token audience %v is not %s", claims.Audience, a.config.Audience)
+	}
+	issuerOK := false
+	for _, issuer := range a.config.Issuers {
+		issuerOK = issuerOK || claims.Issuer == issuer
+	}
+	if !issuerOK {
+		return nil, fmt.Errorf("ID token issuer %q is not accepted", claims.Issuer)
+	}
+	scopes, ok := a.accounts[strings.ToLower(claims.Email)]
+	if !ok || !claims.EmailVerified {
+		return nil, fmt.Errorf("ID token of %q (verified: %v) is not from an accepted account", claims.Email, claims.EmailVerified)
+	}
+	return &Principal{Name: claims.Email, Method: "oidc", Scopes: scopes}, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func newAuthTestApp(auth *Auth) *fiber.App {
+	app := fiber.New()
+	app.Use(auth.Middleware())
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/api/articles", ok)
+	app.Post("/api/schedule/crawl", ok)
+	app.Get("/api/admin/config", ok)
+	app.Get("/admin", ok)
+	app.Get("/media/a.jpg", ok)
+	return app
+}
+
+func authStatus(t *testing.T, app *fiber.App, req *http.Request) int {
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestAuthScopes(t *testing.T) {
+	auth := NewAuth(AuthConfig{
+		AnonymousScopes: []string{ScopeRead},
+		APIKeys: []APIKeyConfig{
+			{Name: "scheduler", Key: "crawl-key-0123456789", Scopes: []string{ScopeCrawl}},
+			{Name: "ops", Key: "admin-key-0123456789", Scopes: []string{ScopeAdmin}},
+		},
+	})
+	app := newAuthTestApp(auth)
+	for _, tc := range []struct {
+		method, path, key string
+		want              int
+	}{
+		{"GET", "/api/articles", "", 200},
+		{"GET", "/media/a.jpg", "", 200},
+		{"POST", "/api/schedule/crawl", "", 401},
+		{"POST", "/api/schedule/crawl", "wrong-key-0123456789", 401},
+		{"POST", "/api/schedule/crawl", "crawl-key-0123456789", 200},
+		{"GET", "/api/articles", "crawl-key-0123456789", 403}, // Not anonymous: its own scopes apply
+		{"GET", "/api/admin/config", "crawl-key-0123456789", 403},
+		{"GET", "/api/admin/config", "admin-key-0123456789", 200},
+		{"POST", "/api/schedule/crawl", "admin-key-0123456789", 200},
+		// Fiber routes these to the same handlers
+		{"POST", "/API/schedule/crawl", "", 401},
+		{"POST", "/api/Schedule/Crawl/", "", 401},
+		{"GET", "/api/Admin/config", "crawl-key-0123456789", 403},
+		{"GET", "/api/admin/config/", "", 401},
+		{"GET", "/Admin", "", 401},
+		{"GET", "/ADMIN/", "", 401},
+		{"GET", "/Admin", "admin-key-0123456789", 200},
+		{"GET", "/Media/a.jpg", "", 200},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.key != "" {
+			req.Header.Set(APIKeyHeader, tc.key)
+		}
+		if got := authStatus(t, app, req); got != tc.want {
+			t.Errorf("%s %s with key %q: %d, want %d", tc.method, tc.path, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	secret := "hmac-secret-0123456789"
+	auth := NewAuth(AuthConfig{HMACKeys: []HMACKeyConfig{{ID: "cron", Secret: secret, Scopes: []string{ScopeCrawl}}}})
+	hmacAuth := auth.Authenticators[0].(*HMACAuthenticator)
+	now := time.Unix(1715300000, 0)
+	hmacAuth.now = func() time.Time { return now }
+	app := newAuthTestApp(auth)
+
+	signed := func(timestamp time.Time, body, signedBody string) *http.Request {
+		req := httptest.NewRequest("POST", "/api/schedule/crawl?pages=2", strings.NewReader(body))
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		req.Header.Set(RequestSignatureHeader, "keyId=cron,t="+ts+",v1="+SignRequest(secret, ts, "POST", "/api/schedule/crawl?pages=2", []byte(signedBody)))
+		return req
+	}
+	if got := authStatus(t, app, signed(now, "{}", "{}")); got != 200 {
+		t.Errorf("signed request: %d", got)
+	}
+	if got := authStatus(t, app, signed(now, "{}", "")); got != 401 {
+		t.Errorf("tampered body: %d", got)
+	}
+	if got := authStatus(t, app, signed(now.Add(-10*time.Minute), "{}", "{}")); got != 401 {
+		t.Errorf("replayed request: %d", got)
+	}
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwksServer.Close()
+
+	audience := "https://crawler.example.run.app"
+	auth := NewAuth(AuthConfig{OIDC: OIDCConfig{
+		Audience:        audience,
+		Issuers:         []string{"https://accounts.google.com"},
+		JWKSURL:         jwksServer.URL,
+		ServiceAccounts: []OIDCAccountConfig{{Email: "scheduler@project.iam.gserviceaccount.com", Scopes: []string{ScopeCrawl}}},
+	}})
+	app := newAuthTestApp(auth)
+
+	token := func(edit func(claims jwt.MapClaims)) *http.Request {
+		claims := jwt.MapClaims{
+			"iss":            "https://accounts.google.com",
+			"aud":            audience,
+			"email":          "scheduler@project.iam.gserviceaccount.com",
+			"email_verified": true,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		if edit != nil {
+			edit(claims)
+		}
+		t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		t.Header["kid"] = "test"
+		signed, _ := t.SignedString(key)
+		req := httptest.NewRequest("POST", "/api/schedule/crawl", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		return req
+	}
+	for name, tc := range map[string]struct {
+		edit func(jwt.MapClaims)
+		want int
+	}{
+		"valid":          {nil, 200},
+		"other audience": {func(c jwt.MapClaims) { c["aud"] = "https://other.example" }, 401},
+		"other issuer":   {func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, 401},
+		"other account":  {func(c jwt.MapClaims) { c["email"] = "someone@example.com" }, 401},
+		"unverified":     {func(c jwt.MapClaims) { c["email_verified"] = false }, 401},
+		"expired":        {func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, 401},
+	} {
+		if got := authStatus(t, app, token(tc.edit)); got != tc.want {
+			t.Errorf("%s: %d, want %d", name, got, tc.want)
+		}
+	}
+}
+
+func TestAuthConfigRedactedAndValidated(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Auth.APIKeys = []APIKeyConfig{{Name: "ops", Key: "admin-key-0123456789", Scopes: []string{ScopeAdmin}}}
+	cfg.Auth.HMACKeys = []HMACKeyConfig{{ID: "cron", Secret: "short", Scopes: []string{"write"}}}
+	redacted := cfg.Redacted()
+	if redacted.Auth.APIKeys[0].Key != "REDACTED" || redacted.Auth.HMACKeys[0].Secret != "REDACTED" {
+		t.Errorf("secrets not redacted: %+v", redacted.Auth)
+	}
+	if cfg.Auth.APIKeys[0].Key != "admin-key-0123456789" {
+		t.Error("Redacted modified the configuration")
+	}
+
+	errs := cfg.Auth.validate()
+	if len(errs) != 2 {
+		t.Errorf("got %v, want the short secret and the unknown scope", errs)
+	}
+}
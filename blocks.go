@@ -0,0 +1,428 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Content block types.
+const (
+	BlockParagraph = "paragraph"
+	BlockHeading   = "heading"
+	BlockQuote     = "quote"
+	BlockListItem  = "listItem"
+	BlockCaption   = "caption"
+	BlockTable     = "table"
+	BlockImage     = "image"
+)
+
+// TableRow is a row of a table block. Firestore does not support nested arrays,
+// hence the wrapper around the cells.
+type TableRow struct {
+	Cells []string `firestore:"cells" json:"cells"`
+}
+
+// ContentBlock is a structural element of an article body.
+type ContentBlock struct {
+	Type  string     `firestore:"type" json:"type"`
+	Text  string     `firestore:"text,omitempty" json:"text,omitempty"`   // Plain text; the caption of images and tables
+	Level int        `firestore:"level,omitempty" json:"level,omitempty"` // Heading level (1-6)
+	Rows  []TableRow `firestore:"rows,omitempty" json:"rows,omitempty"`   // Table rows, header first
+
+	HeaderRows int `firestore:"headerRows,omitempty" json:"headerRows,omitempty"` // Number of leading header rows of a table
+
+	// Image blocks: the image URL and its declared size; Text holds the caption.
+	URL    string `firestore:"url,omitempty" json:"url,omitempty"`
+	Width  int    `firestore:"width,omitempty" json:"width,omitempty"`
+	Height int    `firestore:"height,omitempty" json:"height,omitempty"`
+
+	markdown string // Inline Markdown rendition of Text (emphasis, links)
+	alt      string // Alternative text of an image
+}
+
+// Elements that start a new block; everything else is treated as inline content.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "ul": true, "ol": true, "li": true, "table": true,
+	"figure": true, "figcaption": true, "pre": true, "hr": true, "dl": true, "dt": true, "dd": true,
+}
+
+// captionClasses mark inline elements holding image captions (Naver uses <em class="img_desc">).
+var captionClasses = []string{"img_desc", "caption", "photo_caption"}
+
+// subheadingClasses mark inline elements used as subheadings (Naver uses <strong class="media_end_summary">).
+var subheadingClasses = []string{"media_end_summary", "subtitle", "sub_title"}
+
+// blockBuilder accumulates inline content into the current block while walking the DOM.
+type blockBuilder struct {
+	blocks   []ContentBlock
+	text     strings.Builder
+	markdown strings.Builder
+}
+
+// extractBlocks converts an article body element into a list of content blocks.
+// Inline emphasis and link texts are preserved; paragraphs are split on block elements and <br>.
+func extractBlocks(sel *goquery.Selection) []ContentBlock {
+	b := &blockBuilder{}
+	for _, node := range sel.Nodes {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			b.walk(child, BlockParagraph, 0)
+		}
+	}
+	b.flush(BlockParagraph, 0)
+	return b.blocks
+}
+
+// flush closes the current inline run as a block of the given type.
+func (b *blockBuilder) flush(blockType string, level int) {
+	text := normalizeSpace(b.text.String())
+	markdown := normalizeSpace(b.markdown.String())
+	b.text.Reset()
+	b.markdown.Reset()
+	if text == "" {
+		return
+	}
+	// A caption directly following an image becomes the image's caption.
+	if blockType == BlockCaption && len(b.blocks) > 0 {
+		if last := &b.blocks[len(b.blocks)-1]; last.Type == BlockImage && last.Text == "" {
+			last.Text = cleanUTF8String(text)
+			last.markdown = cleanUTF8String(markdown)
+			return
+		}
+	}
+	b.blocks = append(b.blocks, ContentBlock{Type: blockType, Text: cleanUTF8String(text), Level: level, markdown: cleanUTF8String(markdown)})
+}
+
+func (b *blockBuilder) walk(n *html.Node, blockType string, level int) {
+	switch n.Type {
+	case html.TextNode:
+		b.text.WriteString(n.Data)
+		b.markdown.WriteString(escapeMarkdown(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	sel := goquery.NewDocumentFromNode(n).Selection
+	switch tag := n.Data; {
+	case tag == "script" || tag == "style" || tag == "noscript" || tag == "iframe":
+		return
+	case tag == "img":
+		if block, ok := imageBlock(sel); ok {
+			b.flush(blockType, level)
+			b.blocks = append(b.blocks, block)
+		}
+		return
+	case tag == "br" || tag == "hr":
+		b.flush(blockType, level)
+		return
+	case tag == "table":
+		b.flush(blockType, level)
+		if rows, headerRows := tableRows(sel); len(rows) > 0 {
+			caption := cleanUTF8String(normalizeSpace(sel.ChildrenFiltered("caption").Text()))
+			b.blocks = append(b.blocks, ContentBlock{Type: BlockTable, Text: caption, Rows: rows, HeaderRows: headerRows})
+		}
+		return
+	case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+		b.flush(blockType, level)
+		b.walkChildren(n, BlockHeading, int(tag[1]-'0'))
+		b.flush(BlockHeading, int(tag[1]-'0'))
+		return
+	case tag == "blockquote":
+		b.flush(blockType, level)
+		b.walkChildren(n, BlockQuote, 0)
+		b.flush(BlockQuote, 0)
+		return
+	case tag == "li":
+		b.flush(blockType, level)
+		b.walkChildren(n, BlockListItem, 0)
+		b.flush(BlockListItem, 0)
+		return
+	case hasAnyClass(sel, subheadingClasses):
+		b.flush(blockType, level)
+		b.walkChildren(n, BlockHeading, 2)
+		b.flush(BlockHeading, 2)
+		return
+	case tag == "figcaption" || hasAnyClass(sel, captionClasses):
+		b.flush(blockType, level)
+		b.walkChildren(n, BlockCaption, 0)
+		b.flush(BlockCaption, 0)
+		return
+	case blockElements[tag]:
+		b.flush(blockType, level)
+		b.walkChildren(n, blockType, level)
+		b.flush(blockType, level)
+		return
+	case tag == "strong" || tag == "b":
+		b.wrapInline(n, blockType, level, "**", "**")
+		return
+	case tag == "em" || tag == "i":
+		b.wrapInline(n, blockType, level, "*", "*")
+		return
+	case tag == "a":
+		href, _ := sel.Attr("href")
+		if u, err := url.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			b.wrapInline(n, blockType, level, "[", "]("+escapeMarkdownURL(href)+")")
+			return
+		}
+	}
+	b.walkChildren(n, blockType, level)
+}
+
+func (b *blockBuilder) walkChildren(n *html.Node, blockType string, level int) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.walk(child, blockType, level)
+	}
+}
+
+// wrapInline renders an inline element, surrounding its Markdown with the given markers
+// unless it has no text.
+func (b *blockBuilder) wrapInline(n *html.Node, blockType string, level int, open, close string) {
+	inner := &blockBuilder{}
+	inner.walkChildren(n, blockType, level)
+	if len(inner.blocks) > 0 {
+		// The element contained block content (e.g. <b><br></b> or a <div> in a link): keep the structure.
+		b.walkChildren(n, blockType, level)
+		return
+	}
+	text := inner.text.String()
+	b.text.WriteString(text)
+	if strings.TrimSpace(text) == "" {
+		b.markdown.WriteString(inner.markdown.String())
+		return
+	}
+	b.markdown.WriteString(open + strings.TrimSpace(inner.markdown.String()) + close)
+}
+
+// hasAnyClass reports whether sel has one of the given classes.
+func hasAnyClass(sel *goquery.Selection, classes []string) bool {
+	for _, class := range classes {
+		if sel.HasClass(class) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableRows extracts the cell texts of a table as a rectangular grid: cells spanning several
+// columns or rows (colspan/rowspan) are repeated in every position they cover.
+// It also returns the number of header rows (rows of <thead>, or leading rows of <th> cells).
+func tableRows(table *goquery.Selection) ([]TableRow, int) {
+	type span struct {
+		text string
+		rows int
+	}
+	var rows []TableRow
+	headerRows := 0
+	inHeader := true
+	pending := map[int]span{} // Column -> cell spanning down from a previous row
+	table.Find("tr").Each(func(i int, tr *goquery.Selection) {
+		// Skip rows of nested tables; they are rendered as text of their cell.
+		if tr.Closest("table").Get(0) != table.Get(0) {
+			return
+		}
+		var cells []string
+		col := 0
+		fillPending := func() {
+			for {
+				p, ok := pending[col]
+				if !ok {
+					return
+				}
+				cells = append(cells, p.text)
+				if p.rows--; p.rows == 0 {
+					delete(pending, col)
+				} else {
+					pending[col] = p
+				}
+				col++
+			}
+		}
+		allHeaders := true
+		tr.ChildrenFiltered("th, td").Each(func(j int, cell *goquery.Selection) {
+			fillPending()
+			if goquery.NodeName(cell) != "th" {
+				allHeaders = false
+			}
+			text := cleanUTF8String(normalizeSpace(cell.Text()))
+			colspan := spanAttr(cell, "colspan")
+			rowspan := spanAttr(cell, "rowspan")
+			for k := 0; k < colspan; k++ {
+				cells = append(cells, text)
+				if rowspan > 1 {
+					pending[col] = span{text: text, rows: rowspan - 1}
+				}
+				col++
+			}
+		})
+		fillPending()
+
+		empty := true
+		for _, cell := range cells {
+			if cell != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			return
+		}
+		rows = append(rows, TableRow{Cells: cells})
+		if inHeader && (allHeaders || tr.ParentsFiltered("thead").Length() > 0) {
+			headerRows++
+		} else {
+			inHeader = false
+		}
+	})
+	return rows, headerRows
+}
+
+// spanAttr reads a colspan/rowspan attribute, defaulting to 1 (and capping absurd values).
+func spanAttr(cell *goquery.Selection, name string) int {
+	value, _ := cell.Attr(name)
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 1 {
+		return 1
+	}
+	if n > 50 {
+		return 50
+	}
+	return n
+}
+
+// blocksToText renders blocks as plain text with a blank line between paragraphs.
+// Table rows become tab-separated lines; images are represented by their caption, if any.
+func blocksToText(blocks []ContentBlock) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == BlockImage && block.Text == "" {
+			continue
+		}
+		if block.Type == BlockTable {
+			var lines []string
+			if block.Text != "" {
+				lines = append(lines, block.Text)
+			}
+			for _, row := range block.Rows {
+				lines = append(lines, strings.Join(row.Cells, "\t"))
+			}
+			parts = append(parts, strings.Join(lines, "\n"))
+			continue
+		}
+		parts = append(parts, block.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// blocksToMarkdown renders blocks as sanitized Markdown: all text is escaped and only
+// http(s) links and images are kept, so the result is safe to feed to a Markdown renderer.
+func blocksToMarkdown(blocks []ContentBlock) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		inline := block.markdown
+		if inline == "" {
+			inline = escapeMarkdown(block.Text)
+		}
+		switch block.Type {
+		case BlockHeading:
+			level := block.Level
+			if level < 2 {
+				level = 2 // The article title is the document's only level-1 heading
+			}
+			parts = append(parts, strings.Repeat("#", level)+" "+inline)
+		case BlockQuote:
+			parts = append(parts, "> "+inline)
+		case BlockListItem:
+			parts = append(parts, "- "+inline)
+		case BlockCaption:
+			parts = append(parts, "_"+strings.Trim(inline, "*_")+"_")
+		case BlockTable:
+			table := markdownTable(block.Rows)
+			if block.Text != "" {
+				table = "_" + escapeMarkdown(block.Text) + "_\n\n" + table
+			}
+			parts = append(parts, table)
+		case BlockImage:
+			if u, err := url.Parse(block.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				if block.Text != "" {
+					parts = append(parts, "_"+strings.Trim(inline, "*_")+"_")
+				}
+				continue
+			}
+			alt := block.alt
+			if alt == "" {
+				alt = block.Text
+			}
+			image := "![" + escapeMarkdown(alt) + "](" + escapeMarkdownURL(block.URL) + ")"
+			if block.Text != "" {
+				image += "\n_" + strings.Trim(inline, "*_") + "_"
+			}
+			parts = append(parts, image)
+		default:
+			parts = append(parts, inline)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// markdownTable renders rows as a GitHub-flavored Markdown table, the first row being the header.
+func markdownTable(rows []TableRow) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	width := 0
+	for _, row := range rows {
+		if len(row.Cells) > width {
+			width = len(row.Cells)
+		}
+	}
+	line := func(cells []string) string {
+		escaped := make([]string, width)
+		for i := range escaped {
+			if i < len(cells) {
+				escaped[i] = escapeMarkdown(cells[i])
+			}
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+	lines := []string{line(rows[0].Cells), "|" + strings.Repeat(" --- |", width)}
+	for _, row := range rows[1:] {
+		lines = append(lines, line(row.Cells))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", "&lt;", ">", "&gt;", "#", `\#`, "|", `\|`,
+)
+
+// escapeMarkdown escapes characters with a meaning in Markdown (and raw HTML).
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// escapeMarkdownURL makes a URL safe inside a Markdown link destination.
+func escapeMarkdownURL(s string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(s)
+}
+
+// setArticleBlocks stores blocks on the article with their plain-text and Markdown renditions,
+// and lists their images and numeric figures. The first body image becomes the lead image.
+func setArticleBlocks(article *NewsArticle, blocks []ContentBlock) {
+	resolveImageURLs(blocks, article.URL)
+	article.ContentBlocks = blocks
+	article.Content = blocksToText(blocks)
+	article.ContentMarkdown = blocksToMarkdown(blocks)
+	article.Images = articleImages(blocks)
+	article.Figures = extractFigures(blocks)
+	if len(article.Images) > 0 {
+		article.LeadImageURL = article.Images[0].URL
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractBlocksMarkdown(t *testing.T) {
+	const body = `<div id="body">
+<strong class="media_end_summary">HBM 수요 급증<br>하반기 증설 가속</strong>
+<h3>실적 요약</h3>
+<p>영업이익은 <a href="https://example.com/ir?id=1">IR 자료</a>와 <a href="javascript:alert(1)">일치</a>했다. 주가는 *5%* 올랐다.</p>
+<blockquote>“메모리 업황이 <em>바닥</em>을 지났다”</blockquote>
+<table><tr><th>구분</th><th>1Q24</th></tr><tr><td>매출|합계</td><td>71조9000억원</td></tr></table>
+<ul><li>첫째</li><li>둘째</li></ul>
+</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := extractBlocks(doc.Find("#body"))
+
+	wantMarkdown := strings.Join([]string{
+		"## HBM 수요 급증",
+		"## 하반기 증설 가속",
+		"### 실적 요약",
+		`영업이익은 [IR 자료](https://example.com/ir?id=1)와 일치했다. 주가는 \*5%\* 올랐다.`,
+		"> “메모리 업황이 *바닥*을 지났다”",
+		"| 구분 | 1Q24 |\n| --- | --- |\n| 매출\\|합계 | 71조9000억원 |",
+		"- 첫째",
+		"- 둘째",
+	}, "\n\n")
+	if got := blocksToMarkdown(blocks); got != wantMarkdown {
+		t.Errorf("markdown:\n%s\nwant:\n%s", got, wantMarkdown)
+	}
+
+	wantText := "HBM 수요 급증\n\n하반기 증설 가속\n\n실적 요약\n\n영업이익은 IR 자료와 일치했다. 주가는 *5%* 올랐다.\n\n" +
+		"“메모리 업황이 바닥을 지났다”\n\n구분\t1Q24\n매출|합계\t71조9000억원\n\n첫째\n\n둘째"
+	if got := blocksToText(blocks); got != wantText {
+		t.Errorf("text:\n%q\nwant:\n%q", got, wantText)
+	}
+}
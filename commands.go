@@ -0,0 +1,778 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes of the commands. Crawls exit with exitDegraded when the parser health of the run
+// is degraded (selector drift), so that schedulers can tell it apart from outages.
+const (
+	exitOK       = 0
+	exitFailure  = 1
+	exitUsage    = 2
+	exitDegraded = 3
+)
+
+// command is a subcommand of the news-crawler binary.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	// Set here rather than in the declaration: runHelpCommand refers to commands.
+	commands = []command{
+		{"serve", "run the HTTP API server (the default)", runServeCommand},
+		{"crawl", "crawl a source once", runCrawlCommand},
+		{"backfill", "crawl the Naver Finance main news of past days", runBackfillCommand},
+		{"search", "search the stored articles", runSearchCommand},
+		{"export", "export articles as NDJSON, CSV or Parquet", runExportCommand},
+		{"import", "import an export file into the article store", runImportCommand},
+		{"reindex", "recompute the derived fields of stored articles", runReindexCommand},
+		{"migrate", "upgrade articles stored by older versions", runMigrateCommand},
+		{"doctor", "check the configuration, credentials and store connectivity", runDoctorCommand},
+		{"config", "print or validate the effective configuration", runConfigCommand},
+		{"help", "show this help", runHelpCommand},
+	}
+}
+
+// runCommand runs the subcommand named by args[0] (serve when args is empty) and returns the
+// process exit code.
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		return runServeCommand(nil)
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+	if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		return runHelpCommand(nil)
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", args[0])
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+func runHelpCommand(args []string) int {
+	printUsage(os.Stdout)
+	return exitOK
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: news-crawler <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "news-crawler <command> -h" for the flags of a command.`)
+}
+
+// newFlagSet returns the flag set of a command; usage describes its arguments after the flags.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: news-crawler %s [flags]%s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// articleQueryFlags registers the article filter flags (as on /api/articles) on fs.
+// The returned function builds the query once the flags are parsed.
+func articleQueryFlags(fs *flag.FlagSet) func() (ArticleQuery, error) {
+	var q ArticleQuery
+	var from, to string
+	fs.StringVar(&q.SourceID, "source", "", "source ID")
+	fs.StringVar(&q.Publisher, "publisher", "", "publisher name or 3-digit Naver office ID")
+	fs.StringVar(&q.Ticker, "ticker", "", "6-digit KRX ticker")
+	fs.StringVar(&q.SummaryStatus, "summary-status", "", "pending, retrying or done")
+	fs.StringVar(&from, "from", "", "collected at or after (RFC 3339 or YYYY-MM-DD)")
+	fs.StringVar(&to, "to", "", "collected before (RFC 3339, or YYYY-MM-DD inclusive)")
+	return func() (ArticleQuery, error) {
+		var err error
+		if q.From, err = parseQueryTime(from, false); err != nil {
+			return q, fmt.Errorf("invalid -from: %v", err)
+		}
+		if q.To, err = parseQueryTime(to, true); err != nil {
+			return q, fmt.Errorf("invalid -to: %v", err)
+		}
+		return q, q.Validate()
+	}
+}
+
+// openArticleStore returns the article store selected by the configuration, initializing
+// the Firestore client when needed.
+func openArticleStore(cfg *Config) (ArticleStore, error) {
+	if cfg.ArticleStore == "memory" {
+		componentLogger(LogComponentStore).Warn("Using the in-memory article store. Articles are lost on restart.")
+		return instrumentArticleStore(NewMemoryArticleStore()), nil
+	}
+	if err := InitializeFirestoreClient(cfg.FirebaseServiceAccountKeyPath); err != nil {
+		return nil, fmt.Errorf("failed to initialize Firebase: %v", err)
+	}
+	return instrumentArticleStore(&FirestoreArticleStore{}), nil
+}
+
+// newCrawlerService wires the crawler service from the configuration: article, webhook and
+// run history stores, declarative sources, watchlists and the media pipeline. Every command shares it;
+// the webhook dispatcher is not started.
+func newCrawlerService(cfg *Config) (*NewsCrawlerService, error) {
+	store, err := openArticleStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := NewNewsCrawlerService(cfg)
+	s.Store = store
+	var webhookStore WebhookStore = &FirestoreWebhookStore{}
+	if cfg.ArticleStore == "memory" {
+		webhookStore = NewMemoryWebhookStore()
+	}
+	s.Webhooks = NewWebhookDispatcher(webhookStore, cfg.WebhookMaxAttempts)
+	s.Runs = &FirestoreRunStore{}
+	s.AlertDedup = &FirestoreAlertDedupStore{}
+	if cfg.ArticleStore == "memory" {
+		s.Runs = NewMemoryRunStore()
+		s.AlertDedup = NewMemoryAlertDedupStore()
+	}
+
+	sources, err := LoadSourceDefinitions(cfg.SourcesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source definitions: %v", err)
+	}
+	s.AddSources(sources)
+	componentLogger(LogComponentConfig).Info("Declarative source definitions loaded", "sources", len(sources), "dir", cfg.SourcesDir)
+	watchlists, err := LoadWatchlists(cfg.WatchlistsFile, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watchlists: %v", err)
+	}
+	if watchlists != nil {
+		watchlists.Dedup = s.AlertDedup
+		s.Watchlists = watchlists
+		componentLogger(LogComponentConfig).Info("Watchlists loaded", "rules", len(watchlists.Rules), "channels", len(watchlists.Channels), "path", cfg.WatchlistsFile)
+	}
+	if cfg.MediaStore == "local" {
+		s.Media = NewMediaPipeline(NewLocalBlobStore(cfg.MediaDir, cfg.MediaBaseURL), s.Fetcher, cfg.ThumbnailWidth)
+		s.Media.MaxImageBytes = cfg.MaxImageBytes
+		s.Media.FetchTimeout = cfg.ArticleFetchTimeout
+		componentLogger(LogComponentMedia).Info("Media pipeline enabled: lead images and thumbnails are stored locally", "dir", cfg.MediaDir)
+	}
+	return s, nil
+}
+
+// printConfigError reports configuration errors, one per line.
+func printConfigError(err error) {
+	fmt.Fprintln(os.Stderr, "Invalid configuration:")
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "  - %s\n", line)
+	}
+}
+
+// checkArticleStore verifies that the article store answers a query.
+func checkArticleStore(ctx context.Context, store ArticleStore) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if _, err := store.ListArticles(ctx, ArticleQuery{Limit: 1}); err != nil {
+		return fmt.Errorf("article store unreachable: %v", err)
+	}
+	return nil
+}
+
+// crawlExitCode maps the outcome of a crawl run to an exit code.
+func crawlExitCode(err error) int {
+	var degradedErr *ParserDegradedError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &degradedErr):
+		return exitDegraded
+	}
+	return exitFailure
+}
+
+// finishOneShot delivers what a one-shot crawl left pending before the process exits: the
+// watchlist alerts, and a first attempt of the queued webhooks (retries are left to a server).
+func finishOneShot(s *NewsCrawlerService) {
+	s.WaitForAlerts()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := s.Webhooks.ProcessDue(ctx); err != nil {
+		componentLogger(LogComponentWebhooks).Warn("Webhook queue processing failed", LogKeyError, err)
+	}
+}
+
+// runCrawlCommand implements "news-crawler crawl": one crawl run, as POST /api/schedule/crawl.
+func runCrawlCommand(args []string) int {
+	fs := newFlagSet("crawl", "")
+	config := configFlags(fs)
+	sourceID := fs.String("source", "", "declarative source ID (default: the Naver Finance main news)")
+	pages := fs.Int("pages", 1, "number of list pages")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *pages <= 0 {
+		fmt.Fprintln(os.Stderr, "-pages must be positive")
+		return exitUsage
+	}
+
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	shutdownTracing, err := setupTracing(cfg.Tracing)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer shutdownTracing(context.Background())
+	s, err := newCrawlerService(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	var def *SourceDefinition
+	if *sourceID != "" && *sourceID != NaverMainNewsSourceID {
+		if def = s.Sources[*sourceID]; def == nil {
+			fmt.Fprintf(os.Stderr, "Unknown source: %s\n", *sourceID)
+			return exitUsage
+		}
+	}
+	if err := checkArticleStore(context.Background(), s.Store); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	var articles []NewsArticle
+	job := s.Job(context.Background(), JobInfo{ID: newJobID(), Trigger: RunTriggerCLI})
+	if def == nil {
+		articles, err = job.CrawlNaverFinanceNews(*pages)
+	} else {
+		articles, err = job.CrawlSource(def, *pages)
+	}
+	finishOneShot(s)
+	job.logger().Info("Crawl command finished", "new", len(articles))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Crawl failed: %v\n", err)
+	}
+	return crawlExitCode(err)
+}
+
+// runBackfillCommand implements "news-crawler backfill": it crawls the Naver Finance main news
+// of each day of a date range, to fill gaps left by outages.
+func runBackfillCommand(args []string) int {
+	fs := newFlagSet("backfill", "")
+	config := configFlags(fs)
+	from := fs.String("from", "", "first day (YYYY-MM-DD, required)")
+	to := fs.String("to", "", "last day, inclusive (YYYY-MM-DD, default: -from)")
+	pages := fs.Int("pages", 5, "number of list pages per day")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *to == "" {
+		*to = *from
+	}
+	first, err1 := time.ParseInLocation("2006-01-02", *from, seoulLocation())
+	last, err2 := time.ParseInLocation("2006-01-02", *to, seoulLocation())
+	if err1 != nil || err2 != nil || last.Before(first) || *pages <= 0 {
+		fmt.Fprintln(os.Stderr, "-from and -to must be YYYY-MM-DD dates, -from first, and -pages positive")
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	shutdownTracing, err := setupTracing(cfg.Tracing)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer shutdownTracing(context.Background())
+	s, err := newCrawlerService(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	if err := checkArticleStore(context.Background(), s.Store); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	code, total := exitOK, 0
+	job := s.Job(context.Background(), JobInfo{ID: newJobID(), Trigger: RunTriggerCLI})
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		articles, err := job.CrawlNaverFinanceNewsOn(day, *pages)
+		total += len(articles)
+		if err != nil {
+			// Later days are still crawled; the exit code reports the worst day.
+			fmt.Fprintf(os.Stderr, "Backfill of %s failed: %v\n", day.Format("2006-01-02"), err)
+			if dayCode := crawlExitCode(err); code == exitOK || dayCode == exitFailure {
+				code = dayCode
+			}
+		}
+	}
+	finishOneShot(s)
+	job.logger().Info("Backfill finished", "new", total)
+	return code
+}
+
+// runSearchCommand implements "news-crawler search": it prints the recent articles matching a
+// query in the watchlist syntax (AND, OR, NOT, parentheses, "phrases").
+func runSearchCommand(args []string) int {
+	fs := newFlagSet("search", " QUERY")
+	config := configFlags(fs)
+	query := articleQueryFlags(fs)
+	limit := fs.Int("limit", 20, "maximum number of results")
+	scan := fs.Int("scan", 2000, "maximum number of recent articles searched")
+	asJSON := fs.Bool("json", false, "print the articles as NDJSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	expr, err := compileWatchExpr(strings.Join(fs.Args(), " "))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid query: %v\n", err)
+		return exitUsage
+	}
+	q, err := query()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	store, err := openArticleStore(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	scanned, found := 0, 0
+	err = walkArticles(context.Background(), store, q, func(a *NewsArticle) (bool, error) {
+		scanned++
+		if expr.eval(strings.ToLower(a.Title + "\n" + a.Summary + "\n" + a.Content)) {
+			found++
+			if *asJSON {
+				if err := enc.Encode(a); err != nil {
+					return false, err
+				}
+			} else {
+				fmt.Fprintf(out, "%s  %s  %s\n    %s\n", entryDate(a).In(seoulLocation()).Format("2006-01-02 15:04"), a.Source, a.Title, a.URL)
+			}
+		}
+		return found < *limit && scanned < *scan, nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
+		return exitFailure
+	}
+	componentLogger(LogComponentCLI).Info("Search finished", "matches", found, "scanned", scanned)
+	return exitOK
+}
+
+// runExportCommand implements "news-crawler export": it writes the matching articles to a file
+// (or standard output) in NDJSON, CSV or Parquet.
+func runExportCommand(args []string) int {
+	fs := newFlagSet("export", "")
+	config := configFlags(fs)
+	format := fs.String("format", "", "ndjson, csv or parquet (default: from the -o extension, else ndjson)")
+	output := fs.String("o", "-", "output file (- for standard output)")
+	max := fs.Int("limit", 0, "maximum number of articles (0 exports all)")
+	query := articleQueryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	q, err := query()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if *format == "" && *output != "-" {
+		*format = exportFormatFromPath(*output)
+	}
+	if *format, err = parseExportFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	store, err := openArticleStore(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriterSize(w, 256<<10)
+	count, err := ExportArticles(context.Background(), store, q, *max, *format, bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed after %d article(s): %v\n", count, err)
+		return exitFailure
+	}
+	componentLogger(LogComponentCLI).Info("Articles exported", "articles", count, "format", *format)
+	return exitOK
+}
+
+// runImportCommand implements "news-crawler import": it loads an export file into the
+// configured article store.
+func runImportCommand(args []string) int {
+	fs := newFlagSet("import", " FILE")
+	config := configFlags(fs)
+	format := fs.String("format", "", "ndjson, csv or parquet (default: from the file extension)")
+	overwrite := fs.Bool("overwrite", false, "replace articles that are already stored")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = exportFormatFromPath(path)
+	}
+	var err error
+	if *format, err = parseExportFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	store, err := openArticleStore(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	result, err := ImportArticles(context.Background(), store, path, *format, *overwrite)
+	componentLogger(LogComponentCLI).Info("Articles imported", "imported", result.Imported, "skipped", result.Skipped)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runReindexCommand implements "news-crawler reindex".
+func runReindexCommand(args []string) int {
+	fs := newFlagSet("reindex", "")
+	config := configFlags(fs)
+	query := articleQueryFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "report the changes without saving them")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	q, err := query()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	store, err := openArticleStore(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	result, err := ReindexArticles(context.Background(), store, q, *dryRun)
+	printMaintenanceResult(result, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Reindex failed: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runMigrateCommand implements "news-crawler migrate".
+func runMigrateCommand(args []string) int {
+	fs := newFlagSet("migrate", "")
+	config := configFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "report the changes without saving them")
+	only := fs.String("only", "", "comma-separated migration IDs to apply (default: all)")
+	list := fs.Bool("list", false, "list the migrations and exit")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *list {
+		for _, m := range articleMigrations {
+			fmt.Printf("%-18s %s\n", m.ID, m.Description)
+		}
+		return exitOK
+	}
+	migrations := articleMigrations
+	if *only != "" {
+		migrations = nil
+		for _, id := range strings.Split(*only, ",") {
+			found := false
+			for _, m := range articleMigrations {
+				if m.ID == strings.TrimSpace(id) {
+					migrations = append(migrations, m)
+					found = true
+				}
+			}
+			if !found {
+				fmt.Fprintf(os.Stderr, "Unknown migration: %s\n", id)
+				return exitUsage
+			}
+		}
+	}
+
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	store, err := openArticleStore(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	result, err := MigrateArticles(context.Background(), store, migrations, *dryRun)
+	printMaintenanceResult(result, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func printMaintenanceResult(result MaintenanceResult, dryRun bool) {
+	verb := "updated"
+	if dryRun {
+		verb = "would be updated (dry run)"
+	}
+	fmt.Printf("%d article(s) scanned, %d %s.\n", result.Scanned, result.Updated, verb)
+	names := make([]string, 0, len(result.Changes))
+	for name := range result.Changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-18s %d\n", name, result.Changes[name])
+	}
+}
+
+// runConfigCommand implements "news-crawler config print" (the effective configuration as YAML,
+// secrets redacted) and "news-crawler config validate". Both report every invalid value.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "print" && args[0] != "validate") {
+		fmt.Fprintln(os.Stderr, "Usage: news-crawler config print|validate [flags]")
+		return exitUsage
+	}
+	fs := newFlagSet("config "+args[0], "")
+	config := configFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	cfg, err := loadConfigLayers(*config)
+	if cfg != nil {
+		err = errors.Join(err, cfg.Validate())
+		if args[0] == "print" {
+			data, marshalErr := yaml.Marshal(cfg.Redacted())
+			if marshalErr != nil {
+				fmt.Fprintln(os.Stderr, marshalErr)
+				return exitFailure
+			}
+			os.Stdout.Write(data)
+		}
+	}
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	if args[0] == "validate" {
+		fmt.Println("Configuration is valid.")
+	}
+	return exitOK
+}
+
+// doctorCheck is the outcome of a doctor check; skipped checks do not apply to the configuration.
+type doctorCheck struct {
+	Name    string
+	OK      bool
+	Skipped bool
+	Detail  string
+}
+
+// runDoctorCommand implements "news-crawler doctor": it checks the configuration, the
+// credentials and the connectivity of the stores, and exits with exitFailure if any fails.
+func runDoctorCommand(args []string) int {
+	fs := newFlagSet("doctor", "")
+	config := configFlags(fs)
+	network := fs.Bool("network", false, "also check that the Naver Finance news list can be fetched")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		// The other checks need a valid configuration.
+		fmt.Printf("FAIL  config\n")
+		printConfigError(err)
+		return exitFailure
+	}
+	setupLogging(cfg)
+	checks := runDoctorChecks(context.Background(), cfg, *network)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	code := exitOK
+	for _, check := range checks {
+		status := "ok"
+		switch {
+		case check.Skipped:
+			status = "skip"
+		case !check.OK:
+			status = "FAIL"
+			code = exitFailure
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", status, check.Name, check.Detail)
+	}
+	tw.Flush()
+	return code
+}
+
+func runDoctorChecks(ctx context.Context, cfg *Config, network bool) []doctorCheck {
+	var checks []doctorCheck
+	add := func(name string, err error, detail string) {
+		check := doctorCheck{Name: name, OK: err == nil, Detail: detail}
+		if err != nil {
+			check.Detail = err.Error()
+		}
+		checks = append(checks, check)
+	}
+	skip := func(name, detail string) {
+		checks = append(checks, doctorCheck{Name: name, Skipped: true, Detail: detail})
+	}
+
+	add("config", nil, fmt.Sprintf("ARTICLE_STORE=%s, SOURCES_DIR=%s", cfg.ArticleStore, cfg.SourcesDir))
+
+	if cfg.ArticleStore == "firestore" {
+		add("credentials", checkServiceAccountKey(cfg.FirebaseServiceAccountKeyPath), cfg.FirebaseServiceAccountKeyPath)
+	} else {
+		skip("credentials", "not needed by the memory store")
+	}
+	if store, err := openArticleStore(cfg); err != nil {
+		add("article store", err, "")
+	} else {
+		add("article store", checkArticleStore(ctx, store), cfg.ArticleStore+" answers queries")
+	}
+
+	sources, err := LoadSourceDefinitions(cfg.SourcesDir)
+	add("sources", err, fmt.Sprintf("%d declarative source(s)", len(sources)))
+
+	if watchlists, err := LoadWatchlists(cfg.WatchlistsFile, nil); err != nil || watchlists != nil {
+		detail := ""
+		if watchlists != nil {
+			detail = fmt.Sprintf("%d rule(s), %d channel(s)", len(watchlists.Rules), len(watchlists.Channels))
+		}
+		add("watchlists", err, detail)
+	} else {
+		skip("watchlists", cfg.WatchlistsFile+" not found: alerts are off")
+	}
+
+	if cfg.MediaStore == "local" {
+		add("media store", checkWritableDir(cfg.MediaDir), cfg.MediaDir+" is writable")
+	} else {
+		skip("media store", "media pipeline disabled")
+	}
+
+	if network {
+		_, err := NewNewsCrawlerService(cfg).Fetcher.FetchDocument(cfg.NaverFinanceBaseURL, cfg.ListFetchTimeout)
+		add("network", err, cfg.NaverFinanceBaseURL+" reachable")
+	}
+	return checks
+}
+
+// checkServiceAccountKey verifies that path holds a Google service account key.
+func checkServiceAccountKey(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var key struct {
+		Type        string `json:"type"`
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return fmt.Errorf("%s is not a JSON key file: %v", path, err)
+	}
+	if key.Type != "service_account" || key.ProjectID == "" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return fmt.Errorf("%s is not a service account key (type, project_id, client_email and private_key are required)", path)
+	}
+	return nil
+}
+
+// checkWritableDir verifies that files can be created in dir, creating it when missing.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
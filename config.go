@@ -1,41 +1,615 @@
 package main
 
 import (
-	"log"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config struct holds application configurations.
+// Values are layered (see LoadConfig): defaults, then the YAML config file, then environment
+// variables, then -set flags. Keys are the yaml tags; secret fields are redacted by config print.
 type Config struct {
-	FirebaseServiceAccountKeyPath string
-	NaverFinanceBaseURL           string
-	NaverArticleBaseURL           string
-	UserAgent                     string
+	ReloadInterval time.Duration `yaml:"reloadInterval"` // How often the config, source and watchlist files are checked for changes (0: on SIGHUP only)
+
+	// Logging
+	LogFormat         string            `yaml:"logFormat"`         // LogFormatJSON (default) or LogFormatText
+	LogLevel          string            `yaml:"logLevel"`          // debug, info, warn or error
+	LogLevels         map[string]string `yaml:"logLevels"`         // Level per component (server, crawler, fetcher, ...), overriding logLevel
+	LogMaxFieldLength int               `yaml:"logMaxFieldLength"` // Longer log fields (e.g. HTML snippets) are truncated; 0 for no limit
+
+	// Tracing
+	Tracing TracingConfig `yaml:"tracing"`
+
+	// Article store
+	ArticleStore                  string `yaml:"articleStore"` // Article persistence: "firestore" (default) or "memory"
+	FirebaseServiceAccountKeyPath string `yaml:"firebaseServiceAccountKeyPath"`
+	EventReplaySize               int    `yaml:"eventReplaySize"` // Number of recent article events kept for stream resumption
+
+	// Sources
+	NaverFinanceBaseURL string `yaml:"naverFinanceBaseUrl"`
+	NaverArticleBaseURL string `yaml:"naverArticleBaseUrl"`
+	SourcesDir          string `yaml:"sourcesDir"` // Directory of declarative source definitions (YAML/JSON)
+
+	// Fetcher
+	UserAgent           string            `yaml:"userAgent"`
+	HTTPProxy           string            `yaml:"httpProxy" secret:"userinfo"` // Proxy URL of the crawler's requests (default: $HTTPS_PROXY)
+	ListFetchTimeout    time.Duration     `yaml:"listFetchTimeout"`            // Timeout of a news list page request
+	ArticleFetchTimeout time.Duration     `yaml:"articleFetchTimeout"`         // Timeout of an article page (or image) request
+	ArticleFetchRetries int               `yaml:"articleFetchRetries"`         // Attempts per article page on network errors
+	ArticleRetryDelay   time.Duration     `yaml:"articleRetryDelay"`           // Delay before the second attempt, growing linearly
+	ArticleDelay        time.Duration     `yaml:"articleDelay"`                // Minimum pause between articles (plus up to 500ms)
+	PageDelay           time.Duration     `yaml:"pageDelay"`                   // Minimum pause between list pages (plus up to 2s)
+	MaxResponseBytes    int64             `yaml:"maxResponseBytes"`            // Larger responses are rejected
+	HTTPFixtureMode     string            `yaml:"httpFixtureMode"`             // "", "record" or "replay" (see RecordingTransport)
+	HTTPFixtureDir      string            `yaml:"httpFixtureDir"`
+	HTTPTransport       http.RoundTripper `yaml:"-"` // Transport used by the crawler's Fetcher (nil means http.DefaultTransport)
+
+	// Parser health
+	ParserHealthThresholds ParserHealthThresholds `yaml:"parserHealth"`
+	ParserSampleDir        string                 `yaml:"parserSampleDir"` // Directory receiving the first failing HTML sample of a run
+
+	// Media
+	MediaStore     string `yaml:"mediaStore"`     // Lead image / thumbnail storage: "" (disabled) or "local"
+	MediaDir       string `yaml:"mediaDir"`       // Directory of the local media store
+	MediaBaseURL   string `yaml:"mediaBaseUrl"`   // URL prefix under which the local media store is served
+	ThumbnailWidth int    `yaml:"thumbnailWidth"` // Thumbnail width in pixels
+	MaxImageBytes  int    `yaml:"maxImageBytes"`  // Larger lead images are not stored
+
+	// Notifications
+	WebhookMaxAttempts int    `yaml:"webhookMaxAttempts"` // Delivery attempts before a webhook delivery is marked failed
+	WatchlistsFile     string `yaml:"watchlistsFile"`     // Watchlist rules and notification channels (YAML/JSON); alerts are off when missing
+
+	// Scheduled crawls
+	Schedules         []ScheduleConfig `yaml:"schedules"`
+	ScheduleTimeZone  string           `yaml:"scheduleTimeZone"`  // Time zone of the cron expressions
+	ScheduleStateFile string           `yaml:"scheduleStateFile"` // Last run times, to detect the runs missed while stopped
+
+	// HTTP API
+	Port                 string        `yaml:"port"`
+	CORSAllowOrigins     []string      `yaml:"corsAllowOrigins"` // Origins allowed to call the API from a browser ("*" for any)
+	Auth                 AuthConfig    `yaml:"auth"`
+	ShutdownTimeout      time.Duration `yaml:"shutdownTimeout"`      // Time a graceful shutdown may take (Cloud Run kills 10s after SIGTERM)
+	ShutdownDrainTimeout time.Duration `yaml:"shutdownDrainTimeout"` // Part of it given to running crawls to finish before they are interrupted
 }
 
-// LoadConfig loads configurations from environment variables or defaults.
-func LoadConfig() *Config {
-	keyPath := os.Getenv("FIREBASE_SERVICE_ACCOUNT_KEY_PATH")
-	if keyPath == "" {
-		// Default value for development environment (change to your actual path)
-		// For example, if you place serviceAccountKey.json in the project root
-		keyPath = "firebase-service-account-key.json"
-		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-			// If the file is not found, print a more specific error message and exit
-			log.Fatalf("Environment variable FIREBASE_SERVICE_ACCOUNT_KEY_PATH is not set, and default file %s was not found. Please set the correct path to your Firebase service account key file.", keyPath)
-		}
-	}
+// AuthConfig configures the authentication of the HTTP API (see Auth).
+type AuthConfig struct {
+	AnonymousScopes []string        `yaml:"anonymousScopes"` // Scopes of requests without credentials
+	APIKeys         []APIKeyConfig  `yaml:"apiKeys"`
+	HMACKeys        []HMACKeyConfig `yaml:"hmacKeys"`
+	OIDC            OIDCConfig      `yaml:"oidc"`
+}
 
-	// Default User-Agent if not set
-	userAgent := os.Getenv("USER_AGENT")
-	if userAgent == "" {
-		userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
-	}
+// APIKeyConfig is a static API key, sent in the X-API-Key header.
+type APIKeyConfig struct {
+	Name   string   `yaml:"name"`
+	Key    string   `yaml:"key" secret:"true"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// HMACKeyConfig is a shared secret signing requests (see HMACAuthenticator).
+type HMACKeyConfig struct {
+	ID     string   `yaml:"id"`
+	Secret string   `yaml:"secret" secret:"true"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// OIDCConfig accepts OIDC ID tokens, such as those Cloud Scheduler and other Google service
+// accounts send, from the listed accounts.
+type OIDCConfig struct {
+	Audience        string              `yaml:"audience"` // Expected aud claim, e.g. the Cloud Run service URL
+	Issuers         []string            `yaml:"issuers"`
+	JWKSURL         string              `yaml:"jwksUrl"` // Signing keys of the issuer
+	ServiceAccounts []OIDCAccountConfig `yaml:"serviceAccounts"`
+}
+
+// OIDCAccountConfig grants scopes to the tokens of a service account, by verified email.
+type OIDCAccountConfig struct {
+	Email  string   `yaml:"email"`
+	Scopes []string `yaml:"scopes"`
+}
 
+// TracingConfig configures the OpenTelemetry traces of HTTP requests and crawl runs (see setupTracing).
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`    // "" (disabled) or TracingExporterOTLP
+	Endpoint    string  `yaml:"endpoint"`    // OTLP/HTTP collector URL, e.g. http://localhost:4318 (default: $OTEL_EXPORTER_OTLP_ENDPOINT)
+	SampleRatio float64 `yaml:"sampleRatio"` // Share of the traces started here that are recorded; an incoming traceparent decides for its trace
+	ServiceName string  `yaml:"serviceName"` // service.name resource attribute
+}
+
+// ScheduleConfig is a recurring crawl.
+type ScheduleConfig struct {
+	Name       string        `yaml:"name"`
+	Cron       string        `yaml:"cron"`       // Standard 5-field cron expression, or a descriptor like @hourly
+	Source     string        `yaml:"source"`     // Declarative source ID; empty for the Naver Finance main news
+	Pages      int           `yaml:"pages"`      // List pages per run (default 1)
+	Jitter     time.Duration `yaml:"jitter"`     // Random delay of up to Jitter before each run
+	MissedRuns string        `yaml:"missedRuns"` // MissedRunsSkip (default) or MissedRunsRunOnce
+}
+
+// DefaultConfigFile is read when no config file is named; it is optional.
+const DefaultConfigFile = "config.yaml"
+
+// DefaultConfig returns the built-in configuration, the first layer of LoadConfig.
+func DefaultConfig() *Config {
 	return &Config{
-		FirebaseServiceAccountKeyPath: keyPath,
+		ReloadInterval:                10 * time.Second,
+		LogFormat:                     LogFormatJSON,
+		LogLevel:                      "info",
+		LogMaxFieldLength:             2048,
+		Tracing:                       TracingConfig{SampleRatio: 1, ServiceName: "news-crawler"},
+		ArticleStore:                  "firestore",
+		FirebaseServiceAccountKeyPath: "firebase-service-account-key.json",
+		EventReplaySize:               500,
 		NaverFinanceBaseURL:           "https://finance.naver.com/news/mainnews.naver",
 		NaverArticleBaseURL:           "https://n.news.naver.com/mnews/article",
-		UserAgent:                     userAgent,
+		SourcesDir:                    "sources",
+		UserAgent:                     "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+		ListFetchTimeout:              10 * time.Second,
+		ArticleFetchTimeout:           20 * time.Second,
+		ArticleFetchRetries:           3,
+		ArticleRetryDelay:             time.Second,
+		ArticleDelay:                  200 * time.Millisecond,
+		PageDelay:                     2 * time.Second,
+		MaxResponseBytes:              20 << 20,
+		HTTPFixtureDir:                "testdata/fixtures",
+		ParserHealthThresholds: ParserHealthThresholds{
+			MinItems:             5,
+			MaxMissingFieldRatio: 0.2,
+			MaxBodyFallbackRatio: 0.3,
+			MinMedianBodyLength:  200,
+		},
+		ParserSampleDir:      "parser-samples",
+		MediaDir:             "media",
+		MediaBaseURL:         "/media",
+		ThumbnailWidth:       320,
+		MaxImageBytes:        10 << 20,
+		WebhookMaxAttempts:   8,
+		WatchlistsFile:       "watchlists.yaml",
+		ScheduleTimeZone:     "Asia/Seoul",
+		ScheduleStateFile:    "schedule-state.json",
+		Port:                 "8888",
+		CORSAllowOrigins:     []string{"*"},
+		ShutdownTimeout:      9 * time.Second,
+		ShutdownDrainTimeout: 5 * time.Second,
+		Auth: AuthConfig{
+			AnonymousScopes: []string{ScopeRead},
+			OIDC: OIDCConfig{
+				Issuers: []string{"https://accounts.google.com", "accounts.google.com"},
+				JWKSURL: "https://www.googleapis.com/oauth2/v3/certs",
+			},
+		},
+	}
+}
+
+// configEnvVars maps environment variables to config keys.
+var configEnvVars = []struct {
+	name string
+	key  string
+	list bool // Comma-separated values
+}{
+	{"CONFIG_RELOAD_INTERVAL", "reloadInterval", false},
+	{"LOG_FORMAT", "logFormat", false},
+	{"LOG_LEVEL", "logLevel", false},
+	{"LOG_MAX_FIELD_LENGTH", "logMaxFieldLength", false},
+	{"TRACING_EXPORTER", "tracing.exporter", false},
+	{"TRACING_ENDPOINT", "tracing.endpoint", false},
+	{"TRACING_SAMPLE_RATIO", "tracing.sampleRatio", false},
+	{"OTEL_SERVICE_NAME", "tracing.serviceName", false},
+	{"ARTICLE_STORE", "articleStore", false},
+	{"FIREBASE_SERVICE_ACCOUNT_KEY_PATH", "firebaseServiceAccountKeyPath", false},
+	{"EVENT_REPLAY_SIZE", "eventReplaySize", false},
+	{"NAVER_FINANCE_BASE_URL", "naverFinanceBaseUrl", false},
+	{"NAVER_ARTICLE_BASE_URL", "naverArticleBaseUrl", false},
+	{"SOURCES_DIR", "sourcesDir", false},
+	{"USER_AGENT", "userAgent", false},
+	{"CRAWLER_HTTP_PROXY", "httpProxy", false},
+	{"LIST_FETCH_TIMEOUT", "listFetchTimeout", false},
+	{"ARTICLE_FETCH_TIMEOUT", "articleFetchTimeout", false},
+	{"ARTICLE_FETCH_RETRIES", "articleFetchRetries", false},
+	{"ARTICLE_RETRY_DELAY", "articleRetryDelay", false},
+	{"ARTICLE_DELAY", "articleDelay", false},
+	{"PAGE_DELAY", "pageDelay", false},
+	{"MAX_RESPONSE_BYTES", "maxResponseBytes", false},
+	{"HTTP_FIXTURE_MODE", "httpFixtureMode", false},
+	{"HTTP_FIXTURE_DIR", "httpFixtureDir", false},
+	{"PARSER_MIN_ITEMS", "parserHealth.minItems", false},
+	{"PARSER_MAX_MISSING_RATIO", "parserHealth.maxMissingFieldRatio", false},
+	{"PARSER_MAX_FALLBACK_RATIO", "parserHealth.maxBodyFallbackRatio", false},
+	{"PARSER_MIN_MEDIAN_BODY_LENGTH", "parserHealth.minMedianBodyLength", false},
+	{"PARSER_SAMPLE_DIR", "parserSampleDir", false},
+	{"MEDIA_STORE", "mediaStore", false},
+	{"MEDIA_DIR", "mediaDir", false},
+	{"MEDIA_BASE_URL", "mediaBaseUrl", false},
+	{"THUMBNAIL_WIDTH", "thumbnailWidth", false},
+	{"MAX_IMAGE_BYTES", "maxImageBytes", false},
+	{"WEBHOOK_MAX_ATTEMPTS", "webhookMaxAttempts", false},
+	{"WATCHLISTS_FILE", "watchlistsFile", false},
+	{"SCHEDULE_TIME_ZONE", "scheduleTimeZone", false},
+	{"SCHEDULE_STATE_FILE", "scheduleStateFile", false},
+	{"PORT", "port", false},
+	{"SHUTDOWN_TIMEOUT", "shutdownTimeout", false},
+	{"SHUTDOWN_DRAIN_TIMEOUT", "shutdownDrainTimeout", false},
+	{"CORS_ALLOW_ORIGINS", "corsAllowOrigins", true},
+	{"AUTH_ANONYMOUS_SCOPES", "auth.anonymousScopes", true},
+	{"OIDC_AUDIENCE", "auth.oidc.audience", false},
+}
+
+// ConfigSource names the layers above the defaults: the config file (empty for $CONFIG_FILE,
+// else the optional DefaultConfigFile) and the -set overrides ("key=value", the value in YAML).
+type ConfigSource struct {
+	File      string
+	Overrides []string
+}
+
+// path returns the config file to read, and whether it must exist.
+func (src ConfigSource) path() (string, bool) {
+	if src.File != "" {
+		return src.File, true
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path, true
+	}
+	return DefaultConfigFile, false
+}
+
+// configFlags registers -config and -set on fs.
+func configFlags(fs *flag.FlagSet) *ConfigSource {
+	src := &ConfigSource{}
+	fs.StringVar(&src.File, "config", "", "YAML config file (default $CONFIG_FILE, else "+DefaultConfigFile+" if present)")
+	fs.Func("set", "override a config key, e.g. -set articleStore=memory (repeatable)", func(value string) error {
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("expected key=value")
+		}
+		src.Overrides = append(src.Overrides, value)
+		return nil
+	})
+	return src
+}
+
+// LoadConfig builds the configuration from its layers and validates it. All the invalid
+// values are reported together, in a joined error.
+func LoadConfig(src ConfigSource) (*Config, error) {
+	cfg, err := loadConfigLayers(src)
+	if cfg == nil {
+		return nil, err
+	}
+	if err := errors.Join(err, cfg.Validate()); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPTransport, err = cfg.buildTransport(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadConfigLayers merges the configuration layers without validating the result. The config
+// is nil only when the file cannot be read; invalid environment or -set values are returned
+// as errors next to it.
+func loadConfigLayers(src ConfigSource) (*Config, error) {
+	cfg := DefaultConfig()
+	path, required := src.path()
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		// ${VAR} references are read from the environment, so that secrets stay out of the file.
+		data = []byte(os.Expand(string(data), os.Getenv))
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true) // Report misspelled keys
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			var errs []error
+			for _, line := range yamlErrorLines(err) {
+				errs = append(errs, fmt.Errorf("%s: %s", path, line))
+			}
+			return nil, errors.Join(errs...)
+		}
+	case !os.IsNotExist(err) || required:
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	var errs []error
+	for _, env := range configEnvVars {
+		value, ok := os.LookupEnv(env.name)
+		if !ok || value == "" {
+			continue
+		}
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if env.list {
+			node = &yaml.Node{Kind: yaml.SequenceNode}
+			for _, item := range strings.Split(value, ",") {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(item)})
+			}
+		}
+		if err := cfg.set(env.key, node); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", env.name, err))
+		}
+	}
+	for _, override := range src.Overrides {
+		key, value, _ := strings.Cut(override, "=")
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &node); err != nil {
+			errs = append(errs, fmt.Errorf("-set %s: %s", key, yamlLinePrefix.ReplaceAllString(strings.Join(yamlErrorLines(err), "; "), "")))
+			continue
+		}
+		if len(node.Content) == 0 { // Empty value
+			node.Content = []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str"}}
+		}
+		if err := cfg.set(strings.TrimSpace(key), node.Content[0]); err != nil {
+			errs = append(errs, fmt.Errorf("-set %s: %v", key, err))
+		}
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// set decodes value into the config key, a dotted path of yaml keys (e.g. parserHealth.minItems).
+func (c *Config) set(key string, value *yaml.Node) error {
+	if !configKeyExists(reflect.TypeOf(*c), strings.Split(key, ".")) {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	node := value
+	parts := strings.Split(key, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		node = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: parts[i]}, node}}
+	}
+	if err := node.Decode(c); err != nil {
+		// Line numbers are meaningless for a single value.
+		msg := strings.Join(yamlErrorLines(err), "; ")
+		return errors.New(yamlLinePrefix.ReplaceAllString(msg, ""))
+	}
+	return nil
+}
+
+var yamlLinePrefix = regexp.MustCompile(`line \d+: `)
+
+// yamlErrorLines splits a yaml error into its messages, without the package prefix.
+func yamlErrorLines(err error) []string {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	msg = strings.TrimPrefix(msg, "unmarshal errors:\n")
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func configKeyExists(t reflect.Type, path []string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return true
+		}
+		if field.Type.Kind() == reflect.Map {
+			return len(path) == 2 // logLevels.<component>
+		}
+		return field.Type.Kind() == reflect.Struct && configKeyExists(field.Type, path[1:])
+	}
+	return false
+}
+
+// Validate checks the configuration and returns all its errors, joined.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.ReloadInterval >= 0, "reloadInterval: must not be negative (0 for SIGHUP only)")
+	errs = append(errs, validateLogConfig(c)...)
+	errs = append(errs, validateTracingConfig(c.Tracing)...)
+	check(c.ArticleStore == "firestore" || c.ArticleStore == "memory", "articleStore: %q is not supported (firestore, memory)", c.ArticleStore)
+	if c.ArticleStore == "firestore" {
+		// Only Firestore needs the key file.
+		_, err := os.Stat(c.FirebaseServiceAccountKeyPath)
+		check(err == nil, "firebaseServiceAccountKeyPath: %s was not found; set FIREBASE_SERVICE_ACCOUNT_KEY_PATH to your Firebase service account key file", c.FirebaseServiceAccountKeyPath)
+	}
+	check(c.EventReplaySize >= 0, "eventReplaySize: must not be negative")
+
+	for key, value := range map[string]string{"naverFinanceBaseUrl": c.NaverFinanceBaseURL, "naverArticleBaseUrl": c.NaverArticleBaseURL} {
+		u, err := url.Parse(value)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "%s: %q is not an http(s) URL", key, value)
+	}
+	check(c.SourcesDir != "", "sourcesDir: must be set")
+
+	check(c.UserAgent != "", "userAgent: must be set")
+	if c.HTTPProxy != "" {
+		u, err := url.Parse(c.HTTPProxy)
+		check(err == nil && u.Scheme != "" && u.Host != "", "httpProxy: not a URL")
+	}
+	check(c.ListFetchTimeout > 0, "listFetchTimeout: must be positive")
+	check(c.ArticleFetchTimeout > 0, "articleFetchTimeout: must be positive")
+	check(c.ArticleFetchRetries >= 1, "articleFetchRetries: must be at least 1")
+	check(c.ArticleRetryDelay >= 0 && c.ArticleDelay >= 0 && c.PageDelay >= 0, "articleRetryDelay, articleDelay, pageDelay: must not be negative")
+	check(c.MaxResponseBytes >= 0, "maxResponseBytes: must not be negative (0 for no limit)")
+	check(c.HTTPFixtureMode == "" || c.HTTPFixtureMode == FixtureModeRecord || c.HTTPFixtureMode == FixtureModeReplay,
+		"httpFixtureMode: %q is not supported (%s, %s)", c.HTTPFixtureMode, FixtureModeRecord, FixtureModeReplay)
+
+	t := c.ParserHealthThresholds
+	check(t.MinItems >= 0 && t.MinMedianBodyLength >= 0, "parserHealth: minItems and minMedianBodyLength must not be negative")
+	check(t.MaxMissingFieldRatio >= 0 && t.MaxMissingFieldRatio <= 1 && t.MaxBodyFallbackRatio >= 0 && t.MaxBodyFallbackRatio <= 1,
+		"parserHealth: ratios must be between 0 and 1")
+
+	check(c.MediaStore == "" || c.MediaStore == "local", "mediaStore: %q is not supported (local)", c.MediaStore)
+	check(strings.HasPrefix(c.MediaBaseURL, "/"), "mediaBaseUrl: must be a path starting with /")
+	check(c.ThumbnailWidth > 0, "thumbnailWidth: must be positive")
+	check(c.MaxImageBytes >= 0, "maxImageBytes: must not be negative (0 for no limit)")
+
+	check(c.WebhookMaxAttempts >= 1, "webhookMaxAttempts: must be at least 1")
+
+	names := map[string]bool{}
+	for i, schedule := range c.Schedules {
+		check(schedule.Name != "" && !names[schedule.Name], "schedules[%d]: a unique name is required", i)
+		names[schedule.Name] = true
+		if _, err := cronParser.Parse(schedule.Cron); err != nil {
+			errs = append(errs, fmt.Errorf("schedules[%d] (%s): invalid cron expression %q: %v", i, schedule.Name, schedule.Cron, err))
+		}
+		check(schedule.Pages >= 0 && schedule.Pages <= 10, "schedules[%d] (%s): pages must be between 1 and 10", i, schedule.Name)
+		check(schedule.Jitter >= 0, "schedules[%d] (%s): jitter must not be negative", i, schedule.Name)
+		check(schedule.MissedRuns == "" || schedule.MissedRuns == MissedRunsSkip || schedule.MissedRuns == MissedRunsRunOnce,
+			"schedules[%d] (%s): missedRuns %q is not supported (%s, %s)", i, schedule.Name, schedule.MissedRuns, MissedRunsSkip, MissedRunsRunOnce)
+	}
+	_, err := loadScheduleLocation(c.ScheduleTimeZone)
+	check(err == nil, "scheduleTimeZone: unknown time zone %q", c.ScheduleTimeZone)
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port < 65536, "port: %q is not a TCP port", c.Port)
+	check(len(c.CORSAllowOrigins) > 0, "corsAllowOrigins: must list at least one origin (\"*\" for any)")
+	for _, origin := range c.CORSAllowOrigins {
+		u, err := url.Parse(origin)
+		check(origin == "*" || (err == nil && u.Scheme != "" && u.Host != "" && u.Path == ""),
+			"corsAllowOrigins: %q is not an origin (scheme://host[:port])", origin)
+	}
+	errs = append(errs, c.Auth.validate()...)
+	check(c.ShutdownTimeout > 0, "shutdownTimeout: must be positive")
+	check(c.ShutdownDrainTimeout >= 0 && c.ShutdownDrainTimeout <= c.ShutdownTimeout, "shutdownDrainTimeout: must be between 0 and shutdownTimeout")
+	return errors.Join(errs...)
+}
+
+// validate checks the authentication settings.
+func (a *AuthConfig) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	checkScopes := func(name string, scopes []string) {
+		for _, scope := range scopes {
+			check(scope == ScopeRead || scope == ScopeCrawl || scope == ScopeAdmin, "%s: unknown scope %q (%s, %s, %s)", name, scope, ScopeRead, ScopeCrawl, ScopeAdmin)
+		}
+	}
+
+	checkScopes("auth.anonymousScopes", a.AnonymousScopes)
+	names := map[string]bool{}
+	for i, key := range a.APIKeys {
+		name := fmt.Sprintf("auth.apiKeys[%d] (%s)", i, key.Name)
+		check(key.Name != "" && !names[key.Name], "%s: a unique name is required", name)
+		names[key.Name] = true
+		check(len(key.Key) >= minSecretLength, "%s: key must have at least %d characters", name, minSecretLength)
+		check(len(key.Scopes) > 0, "%s: scopes must be set", name)
+		checkScopes(name, key.Scopes)
+	}
+	ids := map[string]bool{}
+	for i, key := range a.HMACKeys {
+		name := fmt.Sprintf("auth.hmacKeys[%d] (%s)", i, key.ID)
+		check(key.ID != "" && !ids[key.ID], "%s: a unique id is required", name)
+		ids[key.ID] = true
+		check(len(key.Secret) >= minSecretLength, "%s: secret must have at least %d characters", name, minSecretLength)
+		check(len(key.Scopes) > 0, "%s: scopes must be set", name)
+		checkScopes(name, key.Scopes)
+	}
+	if len(a.OIDC.ServiceAccounts) > 0 {
+		check(a.OIDC.Audience != "", "auth.oidc.audience: must be set to accept ID tokens")
+		check(len(a.OIDC.Issuers) > 0, "auth.oidc.issuers: must list at least one issuer")
+		u, err := url.Parse(a.OIDC.JWKSURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "auth.oidc.jwksUrl: %q is not an http(s) URL", a.OIDC.JWKSURL)
+	}
+	for i, account := range a.OIDC.ServiceAccounts {
+		name := fmt.Sprintf("auth.oidc.serviceAccounts[%d] (%s)", i, account.Email)
+		check(strings.Contains(account.Email, "@"), "%s: email must be set", name)
+		check(len(account.Scopes) > 0, "%s: scopes must be set", name)
+		checkScopes(name, account.Scopes)
+	}
+	return errs
+}
+
+// buildTransport returns the crawler's HTTP transport: the proxy, then the optional
+// record/replay of HTTP exchanges (see RecordingTransport).
+func (c *Config) buildTransport() (http.RoundTripper, error) {
+	var transport http.RoundTripper
+	if c.HTTPProxy != "" {
+		proxy, err := url.Parse(c.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("httpProxy: %v", err)
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = http.ProxyURL(proxy)
+		transport = t
+	}
+	if c.HTTPFixtureMode != "" {
+		recorder, err := NewRecordingTransport(c.HTTPFixtureDir, c.HTTPFixtureMode, transport)
+		if err != nil {
+			return nil, fmt.Errorf("httpFixtureMode: %v", err)
+		}
+		componentLogger(LogComponentFetcher).Info("HTTP exchanges use fixtures", "mode", c.HTTPFixtureMode, "dir", c.HTTPFixtureDir)
+		transport = recorder
+	}
+	return transport, nil
+}
+
+// configMap returns the configuration keyed like the config file, for JSON output.
+func configMap(c *Config) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Redacted returns a copy of the configuration safe to print: fields tagged secret are
+// replaced (secret:"userinfo" only masks the password of a URL), in nested settings too.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redactSecrets(reflect.ValueOf(&redacted).Elem())
+	return &redacted
+}
+
+// redactSecrets replaces the secret fields of a struct copy. Slices are copied before their
+// elements are changed, as the copy shares them with the original.
+func redactSecrets(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		kind := v.Type().Field(i).Tag.Get("secret")
+		switch value := v.Field(i); value.Kind() {
+		case reflect.String:
+			if kind == "" || value.String() == "" {
+				continue
+			}
+			if u, err := url.Parse(value.String()); kind == "userinfo" && err == nil {
+				value.SetString(u.Redacted())
+			} else {
+				value.SetString("REDACTED")
+			}
+		case reflect.Struct:
+			redactSecrets(value)
+		case reflect.Slice:
+			elem := value.Type().Elem().Kind()
+			if value.Len() == 0 || (elem != reflect.Struct && (elem != reflect.String || kind == "")) {
+				continue
+			}
+			copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+			reflect.Copy(copied, value)
+			for j := 0; j < copied.Len(); j++ {
+				if elem == reflect.Struct {
+					redactSecrets(copied.Index(j))
+				} else {
+					copied.Index(j).SetString("REDACTED")
+				}
+			}
+			value.Set(copied)
+		}
 	}
 }
@@ -1,51 +1,79 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"math/rand"
-	"net/http"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
-	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/text/encoding/htmlindex"
-	"golang.org/x/text/transform"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // NewsArticle struct represents a news article.
 type NewsArticle struct {
-	Title             string    `firestore:"title"`
-	Summary           string    `firestore:"summary"`
-	Content           string    `firestore:"content"`   // Original content
-	AISummary         string    `firestore:"aiSummary"` // AI summary (filled by summarization server)
-	Source            string    `firestore:"source"`
-	URL               string    `firestore:"url"`
-	CollectedAt       time.Time `firestore:"collectedAt"`
-	SummaryRetryCount int       `firestore:"summaryRetryCount"`
+	Title             string          `firestore:"title" json:"title"`
+	Summary           string          `firestore:"summary" json:"summary"`
+	Content           string          `firestore:"content" json:"content"`     // Original content (plain text, paragraphs separated by blank lines)
+	AISummary         string          `firestore:"aiSummary" json:"aiSummary"` // AI summary (filled by summarization server)
+	Source            string          `firestore:"source" json:"source"`
+	URL               string          `firestore:"url" json:"url"`
+	CollectedAt       time.Time       `firestore:"collectedAt" json:"collectedAt"`
+	SummaryRetryCount int             `firestore:"summaryRetryCount" json:"summaryRetryCount"`
+	SourceID          string          `firestore:"sourceId" json:"sourceId"`                           // Crawl source the article came from (e.g. naver-mainnews)
+	OfficeID          string          `firestore:"officeId,omitempty" json:"officeId,omitempty"`       // Naver press office ID (e.g. 015), when known
+	PublishedAt       time.Time       `firestore:"publishedAt,omitempty" json:"publishedAt,omitempty"` // Publication time, when the source exposes it
+	Author            string          `firestore:"author,omitempty" json:"author,omitempty"`
+	Categories        []string        `firestore:"categories,omitempty" json:"categories,omitempty"`
+	Tickers           []string        `firestore:"tickers,omitempty" json:"tickers,omitempty"`                 // KRX stock codes mentioned by the article
+	ExtractionMethod  string          `firestore:"extractionMethod" json:"extractionMethod"`                   // How Content was obtained (ExtractionSelector, ExtractionReadability, ExtractionSummary)
+	ContentBlocks     []ContentBlock  `firestore:"contentBlocks,omitempty" json:"contentBlocks,omitempty"`     // Structured body (paragraphs, headings, quotes, captions, tables)
+	ContentMarkdown   string          `firestore:"contentMarkdown,omitempty" json:"contentMarkdown,omitempty"` // Sanitized Markdown rendition of the body
+	Images            []ArticleImage  `firestore:"images,omitempty" json:"images,omitempty"`                   // Body images with their captions, in reading order
+	LeadImageURL      string          `firestore:"leadImageUrl,omitempty" json:"leadImageUrl,omitempty"`       // First body image, else og:image, else the list thumbnail
+	Figures           []ArticleFigure `firestore:"figures,omitempty" json:"figures,omitempty"`                 // Numeric figures (amounts, ratios) with their context
+	Sentiment         *float64        `firestore:"sentiment,omitempty" json:"sentiment,omitempty"`             // Tone from -1 (negative) to 1 (positive), see scoreSentiment; nil until scored
+
+	// Filled by the media pipeline (see MediaPipeline), when enabled.
+	LeadImageStoredURL string `firestore:"leadImageStoredUrl,omitempty" json:"leadImageStoredUrl,omitempty"`
+	LeadImageWidth     int    `firestore:"leadImageWidth,omitempty" json:"leadImageWidth,omitempty"`
+	LeadImageHeight    int    `firestore:"leadImageHeight,omitempty" json:"leadImageHeight,omitempty"`
+	ThumbnailURL       string `firestore:"thumbnailUrl,omitempty" json:"thumbnailUrl,omitempty"`
 }
 
+// MarshalJSON omits a zero PublishedAt, which the omitempty option cannot do for a time.Time.
+func (a NewsArticle) MarshalJSON() ([]byte, error) {
+	type article NewsArticle // Without this method
+	var publishedAt *time.Time
+	if !a.PublishedAt.IsZero() {
+		publishedAt = &a.PublishedAt
+	}
+	return json.Marshal(struct {
+		article
+		PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	}{article(a), publishedAt})
+}
+
+// errNewsListNotFound is recorded when the list selector matches nothing on a list page.
+var errNewsListNotFound = errors.New("news list not found (list selector matched nothing)")
+
 // Firestore client instance
 var firestoreApp *firebase.App
 
-// Constants related to crawling
-const (
-	MAX_ARTICLE_FETCH_RETRIES    = 3
-	ARTICLE_FETCH_RETRY_DELAY_MS = 1000
-	ARTICLE_FETCH_TIMEOUT_MS     = 20 * time.Second
-)
-
 // InitializeFirestoreClient initializes the Firebase Firestore client.
 func InitializeFirestoreClient(serviceAccountKeyPath string) error {
 	ctx := context.Background()
@@ -55,141 +83,212 @@ func InitializeFirestoreClient(serviceAccountKeyPath string) error {
 		return fmt.Errorf("error initializing Firebase app: %v", err)
 	}
 	firestoreApp = app
-	log.Println("Firebase Firestore client initialized successfully.")
+	componentLogger(LogComponentStore).Info("Firebase Firestore client initialized")
 	return nil
 }
 
 // NewsCrawlerService struct holds the configurations and performs crawling.
 type NewsCrawlerService struct {
-	Config *Config
+	Config     *Config
+	Fetcher    *Fetcher
+	Sources    map[string]*SourceDefinition // Declarative sources by ID (see LoadSourceDefinitions)
+	Media      *MediaPipeline               // Lead image / thumbnail storage; nil when disabled
+	Store      ArticleStore                 // Article persistence (Firestore by default)
+	Events     *EventBus                    // Newly saved articles, for live subscribers
+	Webhooks   *WebhookDispatcher           // Outbound article webhooks; nil when disabled
+	Watchlists *Watchlists                  // Watchlist alerts; nil when no watchlist file is configured
+	AlertDedup AlertDedupStore              // Stories already alerted, kept across watchlist reloads
+	Runs       RunStore                     // Crawl run history; nil when disabled
+	Log        *slog.Logger                 // Logger of the crawl runs; nil for the crawler component logger (see Job)
+
+	reloadMu         sync.RWMutex        // Guards Config, Fetcher, Media, Sources and Watchlists against Reload
+	parent           *NewsCrawlerService // Service a snapshot was taken from (see Snapshot)
+	ctx              context.Context     // Context of a job's crawl runs, carrying its trace (see Job)
+	job              JobInfo
+	lastRun          *CrawlRun // Record of the job's last finished run
+	healthMu         sync.Mutex
+	lastParserHealth map[string]*ParserHealth // Report of the last finished crawl, by source
+	alerts           sync.WaitGroup           // Watchlist alerts being sent
+	crawlingMu       sync.Mutex
+	crawling         map[string]bool    // Sources being crawled (see beginCrawl)
+	crawls           sync.WaitGroup     // Crawls between beginCrawl and end
+	stopping         bool               // Shutdown started: beginCrawl refuses new crawls
+	interrupt        context.Context    // Done when Shutdown interrupts the running crawls
+	interruptCrawls  context.CancelFunc // Cancels interrupt
 }
 
 // NewNewsCrawlerService creates a new NewsCrawlerService instance.
 func NewNewsCrawlerService(cfg *Config) *NewsCrawlerService {
 	return &NewsCrawlerService{
-		Config: cfg,
+		Config:     cfg,
+		Fetcher:    &Fetcher{Transport: cfg.HTTPTransport, UserAgent: cfg.UserAgent, MaxResponseBytes: cfg.MaxResponseBytes},
+		Sources:    map[string]*SourceDefinition{},
+		Store:      &FirestoreArticleStore{},
+		Events:     NewEventBus(cfg.EventReplaySize),
+		AlertDedup: NewMemoryAlertDedupStore(),
 	}
 }
 
-// articleExistsInFirestore checks if an article with the given URL exists in Firestore.
-// It returns true if the article exists, the existing NewsArticle object (if found), and an error.
-func (s *NewsCrawlerService) articleExistsInFirestore(url string) (bool, *NewsArticle, error) {
-	if firestoreApp == nil {
-		return false, nil, fmt.Errorf("Firestore client not initialized")
-	}
-	ctx := context.Background()
-	client, err := firestoreApp.Firestore(ctx)
-	if err != nil {
-		return false, nil, fmt.Errorf("error getting Firestore client: %v", err)
+// Snapshot returns a view of the service whose reloadable settings (configuration, fetcher,
+// sources and watchlists) stay fixed, so that a crawl keeps the settings it started with
+// when the configuration is reloaded. Parser health and pending alerts remain shared.
+func (s *NewsCrawlerService) Snapshot() *NewsCrawlerService {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return &NewsCrawlerService{
+		Config:     s.Config,
+		Fetcher:    s.Fetcher,
+		Sources:    s.Sources,
+		Media:      s.Media,
+		Store:      s.Store,
+		Events:     s.Events,
+		Webhooks:   s.Webhooks,
+		Watchlists: s.Watchlists,
+		AlertDedup: s.AlertDedup,
+		Runs:       s.Runs,
+		Log:        s.Log,
+		parent:     s.root(),
+		ctx:        s.ctx,
+		job:        s.job,
 	}
-	defer client.Close()
+}
 
-	docID := strings.ReplaceAll(url, "/", "_")
-	docID = strings.ReplaceAll(docID, ":", "_")
-	docID = strings.ReplaceAll(docID, "?", "_")
-	docID = strings.ReplaceAll(docID, "&", "_")
-	docID = strings.ReplaceAll(docID, "=", "_")
-	docID = strings.ReplaceAll(docID, "#", "_")
-	docID = strings.ReplaceAll(docID, "%", "_")
-	docID = strings.ReplaceAll(docID, ".", "_")
+// Job returns a snapshot of the service for a crawl job, whose logs, spans and run records
+// carry the job's ID and trigger. The spans of its runs are children of the span of ctx, if
+// any (e.g. the HTTP request).
+func (s *NewsCrawlerService) Job(ctx context.Context, info JobInfo) *NewsCrawlerService {
+	job := s.Snapshot()
+	job.Log = job.logger().With(LogKeyJobID, info.ID)
+	job.ctx, job.job = ctx, info
+	return job
+}
 
-	if len(docID) > 500 {
-		docID = docID[:500]
-	}
+// LastRun returns the record of the last run finished by the job, or nil.
+func (s *NewsCrawlerService) LastRun() *CrawlRun {
+	return s.lastRun
+}
 
-	docRef := client.Collection("newsArticles").Doc(docID)
-	docSnap, err := docRef.Get(ctx)
-	if err != nil {
-		if status.Code(err) == codes.NotFound {
-			return false, nil, nil // Document not found
-		}
-		return false, nil, fmt.Errorf("error checking if article exists in Firestore: %v", err)
+// jobContext returns the context of the crawl runs.
+func (s *NewsCrawlerService) jobContext() context.Context {
+	if s.ctx != nil {
+		return s.ctx
 	}
+	return context.Background()
+}
 
-	if docSnap.Exists() {
-		var existingArticle NewsArticle
-		if err := docSnap.DataTo(&existingArticle); err != nil {
-			log.Printf("Warning: Failed to convert existing Firestore document data to NewsArticle: %v", err)
-			return true, nil, fmt.Errorf("failed to convert existing article data")
-		}
-		return true, &existingArticle, nil
+// startCrawl starts the span of a crawl run of source. The returned context carries the job and
+// source log fields, and is canceled when Shutdown interrupts the running crawls; end must be
+// called when the run is done.
+func (s *NewsCrawlerService) startCrawl(source string) (ctx context.Context, end func()) {
+	attrs := []attribute.KeyValue{attribute.String(TraceKeySource, source)}
+	ctx = withLogFields(s.jobContext(), LogKeySource, source)
+	if s.job.ID != "" {
+		attrs = append(attrs, attribute.String(TraceKeyJobID, s.job.ID))
+		ctx = withLogFields(ctx, LogKeyJobID, s.job.ID)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.interruption(), cancel)
+	ctx, span := tracer.Start(ctx, "crawl "+source, trace.WithAttributes(attrs...))
+	return ctx, func() {
+		span.End()
+		stop()
+		cancel()
 	}
-	return false, nil, nil
 }
 
-// updateArticleAISummaryToEmpty updates an existing article's AISummary field to an empty string.
-func (s *NewsCrawlerService) updateArticleAISummaryToEmpty(url string) error {
-	if firestoreApp == nil {
-		return fmt.Errorf("Firestore client not initialized")
+// interruption returns the context Shutdown cancels to interrupt the running crawls.
+func (s *NewsCrawlerService) interruption() context.Context {
+	root := s.root()
+	root.crawlingMu.Lock()
+	defer root.crawlingMu.Unlock()
+	if root.interrupt == nil {
+		root.interrupt, root.interruptCrawls = context.WithCancel(context.Background())
 	}
-	ctx := context.Background()
-	client, err := firestoreApp.Firestore(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting Firestore client: %v", err)
+	return root.interrupt
+}
+
+// logger returns the logger of the crawl runs.
+func (s *NewsCrawlerService) logger() *slog.Logger {
+	if s.Log != nil {
+		return s.Log
 	}
-	defer client.Close()
+	return componentLogger(LogComponentCrawler)
+}
 
-	docID := strings.ReplaceAll(url, "/", "_")
-	docID = strings.ReplaceAll(docID, ":", "_")
-	docID = strings.ReplaceAll(docID, "?", "_")
-	docID = strings.ReplaceAll(docID, "&", "_")
-	docID = strings.ReplaceAll(docID, "=", "_")
-	docID = strings.ReplaceAll(docID, "#", "_")
-	docID = strings.ReplaceAll(docID, "%", "_")
-	docID = strings.ReplaceAll(docID, ".", "_")
+// root returns the service holding the state shared by its snapshots.
+func (s *NewsCrawlerService) root() *NewsCrawlerService {
+	if s.parent != nil {
+		return s.parent
+	}
+	return s
+}
 
-	if len(docID) > 500 {
-		docID = docID[:500]
+// Reload swaps the settings used by subsequent crawls: the configuration (timeouts, delays,
+// fetcher limits), the declarative sources and the watchlists. Crawls already running keep
+// their snapshot. Callers must use Snapshot to read the settings while reloads may happen.
+func (s *NewsCrawlerService) Reload(cfg *Config, sources []*SourceDefinition, watchlists *Watchlists) {
+	bySourceID := make(map[string]*SourceDefinition, len(sources))
+	for _, def := range sources {
+		bySourceID[def.ID] = def
 	}
+	fetcher := &Fetcher{Transport: cfg.HTTPTransport, UserAgent: cfg.UserAgent, MaxResponseBytes: cfg.MaxResponseBytes}
 
-	_, err = client.Collection("newsArticles").Doc(docID).Update(ctx, []firestore.Update{
-		{Path: "aiSummary", Value: ""},
-	})
-	if err != nil {
-		return fmt.Errorf("error updating existing article's AISummary to empty: %v", err)
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if watchlists != nil {
+		watchlists.Dedup = s.AlertDedup // So that reloading the watchlists does not repeat alerts
 	}
-	log.Printf("Updated existing article's AISummary to empty: %s", url)
-	return nil
+	s.Config = cfg
+	s.Fetcher = fetcher
+	if s.Media != nil {
+		media := *s.Media // The media settings themselves need a restart
+		media.Fetcher = fetcher
+		s.Media = &media
+	}
+	s.Sources = bySourceID
+	s.Watchlists = watchlists
 }
 
-// saveArticleToFirestore saves a NewsArticle to Firestore.
-func (s *NewsCrawlerService) saveArticleToFirestore(article NewsArticle) error {
-	if firestoreApp == nil {
-		return fmt.Errorf("Firestore client not initialized")
+// beginCrawl marks a source ("" for the Naver Finance main news) as being crawled, so that
+// two crawls of the same source never overlap and Shutdown can wait for it. It returns
+// errSourceBusy when the source is already being crawled and errShuttingDown once Shutdown
+// has started; otherwise the caller must call end when the crawl is done.
+func (s *NewsCrawlerService) beginCrawl(sourceID string) (end func(), err error) {
+	if sourceID == "" {
+		sourceID = NaverMainNewsSourceID
 	}
-	ctx := context.Background()
-	client, err := firestoreApp.Firestore(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting Firestore client: %v", err)
+	root := s.root()
+	root.crawlingMu.Lock()
+	defer root.crawlingMu.Unlock()
+	if root.stopping {
+		return nil, errShuttingDown
 	}
-	defer client.Close()
-
-	docID := strings.ReplaceAll(article.URL, "/", "_")
-	docID = strings.ReplaceAll(docID, ":", "_")
-	docID = strings.ReplaceAll(docID, "?", "_")
-	docID = strings.ReplaceAll(docID, "&", "_")
-	docID = strings.ReplaceAll(docID, "=", "_")
-	docID = strings.ReplaceAll(docID, "#", "_")
-	docID = strings.ReplaceAll(docID, "%", "_")
-	docID = strings.ReplaceAll(docID, ".", "_")
-
-	if len(docID) > 500 {
-		docID = docID[:500]
+	if root.crawling[sourceID] {
+		return nil, errSourceBusy
+	}
+	if root.crawling == nil {
+		root.crawling = map[string]bool{}
 	}
+	root.crawling[sourceID] = true
+	root.crawls.Add(1)
+	return func() {
+		root.crawlingMu.Lock()
+		delete(root.crawling, sourceID)
+		root.crawlingMu.Unlock()
+		root.crawls.Done()
+	}, nil
+}
 
-	_, err = client.Collection("newsArticles").Doc(docID).Set(ctx, article)
-	if err != nil {
-		log.Printf("Firestore save attempt failed: %s. Original error: %v", article.Title, err)
-		contentPreviewLength := 100
-		if len(article.Content) < contentPreviewLength {
-			contentPreviewLength = len(article.Content)
-		}
-		log.Printf("Potential invalid UTF-8 string detected: Title='%s', Summary='%s', Content (partial)='%s', Source='%s'",
-			article.Title, article.Summary, article.Content[:contentPreviewLength], article.Source)
-		return fmt.Errorf("error saving article to Firestore: %v", err)
+var (
+	errSourceBusy   = errors.New("the source is already being crawled")
+	errShuttingDown = errors.New("the service is shutting down")
+)
+
+// AddSources registers declarative source definitions, replacing any with the same ID.
+func (s *NewsCrawlerService) AddSources(defs []*SourceDefinition) {
+	for _, def := range defs {
+		s.Sources[def.ID] = def
 	}
-	log.Printf("Article saved to Firestore: %s", article.Title)
-	return nil
 }
 
 // cleanUTF8String ensures the string contains only valid UTF-8 characters.
@@ -223,7 +322,7 @@ func (s *NewsCrawlerService) SearchNewsArticles(ctx context.Context, keyword str
 	defer client.Close()
 
 	var results []NewsArticle
-	iter := client.Collection("newsArticles").Documents(ctx)
+	iter := client.Collection(articlesCollection).Documents(ctx)
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
@@ -235,7 +334,7 @@ func (s *NewsCrawlerService) SearchNewsArticles(ctx context.Context, keyword str
 
 		var article NewsArticle
 		if err := doc.DataTo(&article); err != nil {
-			log.Printf("Warning: Failed to convert Firestore document data to NewsArticle: %v", err)
+			componentLogger(LogComponentStore).Warn("Failed to convert Firestore document data to NewsArticle", "doc_id", doc.Ref.ID, LogKeyError, err)
 			continue
 		}
 
@@ -250,260 +349,317 @@ func (s *NewsCrawlerService) SearchNewsArticles(ctx context.Context, keyword str
 	return results, nil
 }
 
-// CrawlNaverFinanceNews performs the crawling operation.
-func (s *NewsCrawlerService) CrawlNaverFinanceNews(pages int) ([]NewsArticle, error) {
-	allNews := []NewsArticle{}
-	log.Printf("Starting Naver Finance news collection for %d pages...", pages)
+// LastParserHealth returns the parser health of the most recently finished crawl of source,
+// or nil.
+func (s *NewsCrawlerService) LastParserHealth(source string) *ParserHealth {
+	s = s.root()
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	return s.lastParserHealth[source]
+}
 
-	articleIDPattern := regexp.MustCompile(`article_id=(\d+)`)
-	officeIDPattern := regexp.MustCompile(`office_id=(\d+)`)
+// ParserHealthReports returns the parser health of the most recently finished crawl of every
+// source, sorted by source.
+func (s *NewsCrawlerService) ParserHealthReports() []*ParserHealth {
+	s = s.root()
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	reports := make([]*ParserHealth, 0, len(s.lastParserHealth))
+	for _, health := range s.lastParserHealth {
+		reports = append(reports, health)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Source < reports[j].Source })
+	return reports
+}
 
-	for pageNum := 1; pageNum <= pages; pageNum++ {
-		pageURL := fmt.Sprintf("%s?page=%d", s.Config.NaverFinanceBaseURL, pageNum)
-		req, err := http.NewRequest("GET", pageURL, nil)
-		if err != nil {
-			log.Printf("Error creating request for page %d: %v", pageNum, err)
-			return allNews, err
-		}
-		req.Header.Set("User-Agent", s.Config.UserAgent)
+// newParserHealth starts collecting the parser health of a crawl run of source. Its logger
+// is the run's logger, with the source.
+func (s *NewsCrawlerService) newParserHealth(source string) *ParserHealth {
+	health := NewParserHealth(s.Config.ParserHealthThresholds, s.Config.ParserSampleDir)
+	health.Source = source
+	health.log = s.logger().With(LogKeySource, source)
+	return health
+}
 
-		client := &http.Client{Timeout: 10 * time.Second} // Main page timeout 10 seconds
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("Error requesting page %d: %v", pageNum, err)
-			log.Println("Network issue or site blocking possible. Retrying later or consider changing IP.")
-			break // Error, stop crawling
-		}
-		defer resp.Body.Close()
+// finishCrawl evaluates the run's parser health, keeps it as the latest report and records
+// the run in the metrics, in the crawl span of ctx and in the run history. It returns a
+// *ParserDegradedError when the run is degraded, else fetchErr.
+func (s *NewsCrawlerService) finishCrawl(ctx context.Context, health *ParserHealth, run *CrawlRun, fetchErr error) error {
+	interrupted := ctx.Err() != nil
+	ctx = context.WithoutCancel(ctx) // The record of an interrupted run must still be saved
+	degraded := health.Finish()
+	root := s.root()
+	root.healthMu.Lock()
+	if root.lastParserHealth == nil {
+		root.lastParserHealth = map[string]*ParserHealth{}
+	}
+	root.lastParserHealth[health.Source] = health
+	root.healthMu.Unlock()
+	err := fetchErr
+	logger := health.logger()
+	switch {
+	case interrupted:
+		// The health of a partial run says nothing about the selectors.
+		err = errCrawlInterrupted
+	case degraded:
+		logger.Warn("Parser health degraded", "reasons", strings.Join(health.Reasons, "; "))
+		err = &ParserDegradedError{Health: health}
+	}
+	observeCrawl(health, err)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("crawler.parser_status", health.Status), attribute.Int("crawler.items", health.ItemsSeen))
+	if err != nil {
+		logger = logger.With(LogKeyError, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	run.finish(health, err)
+	s.saveRun(ctx, run)
+	s.lastRun = run
+	logger.Info("Crawl finished", LogKeyDuration, health.FinishedAt.Sub(health.StartedAt), "parser_status", health.Status, "items", health.ItemsSeen,
+		"run_id", run.ID, "outcome", run.Outcome, "new", run.New)
+	return err
+}
 
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("HTTP status code error for page %d: %d", pageNum, resp.StatusCode)
-			break // HTTP error, stop crawling
-		}
+// CrawlNaverFinanceNews performs the crawling operation.
+// A run whose parser health crosses the configured thresholds returns a *ParserDegradedError
+// along with the articles it saved; a run that cannot fetch the first list page fails.
+func (s *NewsCrawlerService) CrawlNaverFinanceNews(pages int) ([]NewsArticle, error) {
+	s.logger().Info("Starting Naver Finance news collection", LogKeySource, NaverMainNewsSourceID, "pages", pages)
+	return s.crawlNaverPages(RunParams{Pages: pages}, func(pageNum int) string {
+		return fmt.Sprintf("%s?page=%d", s.Config.NaverFinanceBaseURL, pageNum)
+	})
+}
 
-		// --- Explicitly decode response body based on charset ---
-		// Read the entire body first
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading response body for page %d: %v", pageNum, err)
-			break
-		}
+// CrawlNaverFinanceNewsOn crawls the Naver Finance main news of a past day (in Asia/Seoul),
+// for backfills. It reports like CrawlNaverFinanceNews.
+func (s *NewsCrawlerService) CrawlNaverFinanceNewsOn(date time.Time, pages int) ([]NewsArticle, error) {
+	day := date.In(seoulLocation()).Format("2006-01-02")
+	s.logger().Info("Starting Naver Finance news collection", LogKeySource, NaverMainNewsSourceID, "day", day, "pages", pages)
+	return s.crawlNaverPages(RunParams{Pages: pages, Day: day}, func(pageNum int) string {
+		return fmt.Sprintf("%s?date=%s&page=%d", s.Config.NaverFinanceBaseURL, day, pageNum)
+	})
+}
 
-		// Determine charset from Content-Type header
-		contentType := resp.Header.Get("Content-Type")
-		charset := "utf-8" // Default to UTF-8
-		if strings.Contains(contentType, "charset=") {
-			parts := strings.Split(contentType, "charset=")
-			if len(parts) > 1 {
-				charset = strings.ToLower(strings.TrimSpace(parts[1]))
+func (s *NewsCrawlerService) crawlNaverPages(params RunParams, pageURLOf func(pageNum int) string) ([]NewsArticle, error) {
+	ctx, end := s.startCrawl(NaverMainNewsSourceID)
+	defer end()
+	allNews := []NewsArticle{}
+	health := s.newParserHealth(NaverMainNewsSourceID)
+	run := s.startRun(ctx, health, params)
+	var fetchErr error
+
+	for pageNum := 1; pageNum <= params.Pages && ctx.Err() == nil; pageNum++ {
+		pageURL := pageURLOf(pageNum)
+		logger := health.logger().With(LogKeyPage, pageNum)
+		pageCtx, pageSpan := tracer.Start(ctx, "list page", trace.WithAttributes(attribute.Int(TraceKeyPage, pageNum), semconv.URLFull(pageURL)))
+		doc, err := s.Fetcher.FetchDocumentContext(pageCtx, pageURL, s.Config.ListFetchTimeout)
+		run.startPage(strconv.Itoa(pageNum), pageURL, err)
+		if err != nil {
+			logger.Error("Error requesting list page", LogKeyStage, StageList, "url", pageURL, LogKeyError, err)
+			if isRetryableFetchError(err) {
+				logger.Warn("Network issue or site blocking possible. Retrying later or consider changing IP.")
 			}
-		}
-
-		var reader io.Reader = bytes.NewReader(bodyBytes)
-		if charset != "utf-8" && charset != "" {
-			e, err := htmlindex.Get(charset)
-			if err == nil && e != nil {
-				reader = transform.NewReader(bytes.NewReader(bodyBytes), e.NewDecoder())
-				log.Printf("Page %d: Attempting to convert using %s encoding.", pageNum, charset)
-			} else {
-				log.Printf("Page %d: Could not find or error with %s encoding decoder (%v). Processing as UTF-8.", pageNum, charset, err)
+			if pageNum == 1 {
+				fetchErr = fmt.Errorf("error requesting %s: %v", pageURL, err)
 			}
-		}
-		// --- End of explicit decoding ---
-
-		doc, err := goquery.NewDocumentFromReader(reader)
-		if err != nil {
-			log.Printf("HTML parsing error for page %d: %v", pageNum, err)
-			break // Parsing error, stop crawling
+			endSpan(pageSpan, err)
+			break // Error, stop crawling
 		}
 
-		newsItems := doc.Find("ul.newsList li")
-		if newsItems.Length() == 0 {
-			log.Printf("Could not find news list (ul.newsList li) on page %d. Stopping crawl.", pageNum)
+		if doc.Find("ul.newsList li").Length() == 0 {
+			health.RecordMissingList(pageURL, doc)
+			logger.Warn("Could not find news list (ul.newsList li). Stopping crawl.", LogKeyStage, StageParse)
+			run.recordError(StageParse, pageURL, errNewsListNotFound)
+			endSpan(pageSpan, errNewsListNotFound)
 			break
 		}
 
-		s_crawler := s
-
-		newsItems.Each(func(i int, s_item *goquery.Selection) {
-			select {
-			case <-context.Background().Done():
-				return
-			default:
+		_, parseSpan := tracer.Start(pageCtx, "parse list")
+		list := parseNaverNewsList(doc, s.Config.NaverArticleBaseURL, health)
+		parseSpan.SetAttributes(attribute.Int("crawler.items", len(list)))
+		parseSpan.End()
+		run.countArticles(ArticleOutcomeDiscovered, len(list))
+		for _, newsArticle := range list {
+			if ctx.Err() != nil {
+				break // Interrupted: the remaining articles are collected by the next run
+			}
+			if s.articleAlreadyKnown(pageCtx, newsArticle.URL, health) {
+				run.countArticles(ArticleOutcomeSkipped, 1)
+				continue
 			}
 
-			// Extract data from each news item
-			titleTag := s_item.Find("dd.articleSubject a")
-			summaryDdTag := s_item.Find("dd.articleSummary")
-
-			title := strings.TrimSpace(titleTag.Text())
-			originalLink, _ := titleTag.Attr("href")
-
-			var summaryText string
-			var sourceText string
-
-			if summaryDdTag.Length() > 0 {
-				sourceSpan := summaryDdTag.Find("span.press")
-				if sourceSpan.Length() > 0 {
-					sourceText = strings.TrimSpace(sourceSpan.Text())
-					sourceSpan.Remove()
-				}
-
-				wdateSpan := summaryDdTag.Find("span.wdate")
-				if wdateSpan.Length() > 0 {
-					wdateSpan.Remove()
-				}
-
-				barSpan := summaryDdTag.Find("span.bar")
-				if barSpan.Length() > 0 {
-					barSpan.Remove()
+			// Fetch full article content; the summary is kept as content if it cannot be extracted.
+			if err := s.fetchArticleContent(pageCtx, &newsArticle, parseNaverArticleBody, health); err != nil {
+				if ctx.Err() != nil {
+					break
 				}
-
-				summaryText = strings.TrimSpace(summaryDdTag.Text())
+				run.recordError(StageArticle, newsArticle.URL, err)
 			}
+			newsArticle.SourceID = NaverMainNewsSourceID
 
-			// Validate extracted data
-			if title == "" || summaryText == "" || sourceText == "" || originalLink == "" {
-				itemHtml, _ := goquery.OuterHtml(s_item)
-				log.Printf("Warning: Missing required news elements (title, summary, source, link). News item HTML:\n%s", itemHtml)
-				return
+			if err := s.saveCollectedArticle(pageCtx, &newsArticle); err != nil {
+				logger.Error("Article store save error", LogKeyStage, StageStore, LogKeyArticleURL, newsArticle.URL, LogKeyError, err)
+				run.recordError(StageStore, newsArticle.URL, err)
+				run.countArticles(ArticleOutcomeFailed, 1)
+				continue
 			}
+			run.countArticles(ArticleOutcomeNew, 1)
+			allNews = append(allNews, newsArticle)
 
-			// --- Reconstruct URL for full article content ---
-			var fullArticleURL string
-			articleIDMatch := articleIDPattern.FindStringSubmatch(originalLink)
-			officeIDMatch := officeIDPattern.FindStringSubmatch(originalLink)
+			s.pauseBetweenArticles(ctx)
+		}
 
-			if len(articleIDMatch) > 1 && len(officeIDMatch) > 1 {
-				fullArticleURL = fmt.Sprintf("%s/%s/%s", s_crawler.Config.NaverArticleBaseURL, officeIDMatch[1], articleIDMatch[1])
-			} else {
-				log.Printf("Warning: Could not extract article_id or office_id. Original link: %s", originalLink)
-				fullArticleURL = "https://finance.naver.com" + originalLink
-			}
+		logger.Info("Page collection complete", LogKeyStage, StageList, "listed", len(list), "saved_so_far", len(allNews))
+		pageSpan.End()
+		s.saveRun(ctx, run) // Progress, shown by the admin dashboard
+		s.pauseBetweenPages(ctx)
+	}
+	return allNews, s.finishCrawl(ctx, health, run, fetchErr)
+}
 
-			// Check for existence in Firestore to prevent duplicates
-			exists, existingArticle, err := s_crawler.articleExistsInFirestore(fullArticleURL)
-			if err != nil {
-				log.Printf("Firestore existence check error: %v", err)
-				return
-			}
-			if exists {
-				// If article exists, check if AISummary is missing or empty.
-				// If AISummary is missing or empty, update it to "".
-				if existingArticle != nil && existingArticle.AISummary == "" {
-					err := s_crawler.updateArticleAISummaryToEmpty(fullArticleURL)
-					if err != nil {
-						log.Printf("Warning: Failed to update existing article's AISummary to empty: %v", err)
-					}
-				}
-				log.Printf("Info: Article already exists. Skipping new save for: %s", fullArticleURL)
-				return
-			}
+// articleAlreadyKnown checks Firestore for an article to prevent duplicates.
+// Known articles still waiting for an AI summary get their AISummary reset to "".
+// It returns true when the article must be skipped (already stored, or the check failed).
+func (s *NewsCrawlerService) articleAlreadyKnown(ctx context.Context, articleURL string, health *ParserHealth) bool {
+	logger := health.logger().With(LogKeyStage, StageStore, LogKeyArticleURL, articleURL)
+	existingArticle, err := s.Store.GetArticle(ctx, articleURL)
+	if err != nil {
+		logger.Error("Article store existence check error", LogKeyError, err)
+		return true
+	}
+	if existingArticle == nil {
+		return false
+	}
+	// If article exists, check if AISummary is missing or empty.
+	// If AISummary is missing or empty, update it to "".
+	if existingArticle.AISummary == "" {
+		changed, err := s.Store.ResetAISummary(ctx, articleURL)
+		if err != nil {
+			logger.Warn("Failed to update existing article's AISummary to empty", LogKeyError, err)
+		} else if changed {
+			s.notifyWebhooks(ctx, WebhookEventArticleUpdated, *existingArticle)
+		}
+	}
+	logger.Debug("Article already exists. Skipping new save.")
+	return true
+}
 
-			// --- Fetch full article content with retries ---
-			fullContent := summaryText
-			if fullArticleURL != "" {
-				for retry := 0; retry < 3; retry++ {
-					reqArticle, err := http.NewRequest("GET", fullArticleURL, nil)
-					if err != nil {
-						log.Printf("Error creating article content request: %v", err)
-						break
-					}
-					reqArticle.Header.Set("User-Agent", s_crawler.Config.UserAgent)
-
-					articleClient := &http.Client{Timeout: ARTICLE_FETCH_TIMEOUT_MS}
-					respArticle, err := articleClient.Do(reqArticle)
-					if err != nil {
-						log.Printf("Error loading article content (retry %d/3): %v - %s", retry+1, fullArticleURL, err)
-						if retry < 2 {
-							time.Sleep(time.Duration(1+retry) * time.Second)
-						}
-						continue
-					}
-					defer respArticle.Body.Close()
-
-					if respArticle.StatusCode != http.StatusOK {
-						log.Printf("Article content HTTP status code error: %d - %s", respArticle.StatusCode, fullArticleURL)
-						break
-					}
-
-					// --- Explicitly decode article response body ---
-					articleBodyBytes, err := io.ReadAll(respArticle.Body)
-					if err != nil {
-						log.Printf("Error reading article response body: %v", err)
-						break
-					}
-
-					articleContentType := respArticle.Header.Get("Content-Type")
-					articleCharset := "utf-8" // Default to UTF-8
-					if strings.Contains(articleContentType, "charset=") {
-						parts := strings.Split(articleContentType, "charset=")
-						if len(parts) > 1 {
-							articleCharset = strings.ToLower(strings.TrimSpace(parts[1]))
-						}
-					}
-
-					var articleReader io.Reader = bytes.NewReader(articleBodyBytes)
-					if articleCharset != "utf-8" && articleCharset != "" {
-						e, err := htmlindex.Get(articleCharset)
-						if err == nil && e != nil {
-							articleReader = transform.NewReader(bytes.NewReader(articleBodyBytes), e.NewDecoder())
-							log.Printf("Article content: Attempting to convert using %s encoding.", articleCharset)
-						} else {
-							log.Printf("Article content: Could not find or error with %s encoding decoder (%v). Processing as UTF-8.", articleCharset, err)
-						}
-					}
-					// --- End of explicit decoding for article body ---
-
-					articleDoc, err := goquery.NewDocumentFromReader(articleReader)
-					if err != nil {
-						log.Printf("Article content HTML parsing error: %v - %s", err, fullArticleURL)
-						break
-					}
-
-					contentDiv := articleDoc.Find("article#dic_area")
-					if contentDiv.Length() > 0 {
-						contentDiv.Find("script, iframe, a, strong, em, br, .end_photo_org, .link_text, .byline, .reporter_area, .nbd_im_w, .img_desc").Remove()
-						fullContent = strings.TrimSpace(contentDiv.Text())
-						break
-					} else {
-						log.Printf("Warning: Could not find article body div (article#dic_area): %s (reconstructed URL)", fullArticleURL)
-						break
-					}
-				}
-			}
+// saveCollectedArticle stamps a newly collected article with the crawler defaults and saves it.
+func (s *NewsCrawlerService) saveCollectedArticle(ctx context.Context, article *NewsArticle) error {
+	article.AISummary = "" // Crawler explicitly sets AI summary to empty.
+	article.CollectedAt = time.Now()
+	article.SummaryRetryCount = 0 // 기본값 0으로 설정
+	article.OfficeID = officeIDFromURL(article.URL)
+	article.Tickers = extractTickers(article.Title + "\n" + article.Summary + "\n" + article.ContentMarkdown)
+	article.Sentiment = scoreSentiment(article)
+	s.processMedia(ctx, article)
+	if err := s.Store.SaveArticle(ctx, *article); err != nil {
+		return err
+	}
+	s.Events.Publish(*article)
+	s.notifyWebhooks(ctx, WebhookEventArticleCreated, *article)
+	s.checkWatchlists(ctx, *article)
+	return nil
+}
 
-			// Clean all extracted strings for valid UTF-8 before saving to Firestore
-			title = cleanUTF8String(title)
-			summaryText = cleanUTF8String(summaryText)
-			fullContent = cleanUTF8String(fullContent)
-			sourceText = cleanUTF8String(sourceText)
-			fullArticleURL = cleanUTF8String(fullArticleURL)
-
-			newsArticle := NewsArticle{
-				Title:             title,
-				Summary:           summaryText,
-				Content:           fullContent,
-				AISummary:         "", // Crawler explicitly sets AI summary to empty.
-				Source:            sourceText,
-				URL:               fullArticleURL,
-				CollectedAt:       time.Now(),
-				SummaryRetryCount: 0, // 기본값 0으로 설정
-			}
+// pauseBetweenArticles waits politely between two article requests, unless ctx is done.
+func (s *NewsCrawlerService) pauseBetweenArticles(ctx context.Context) {
+	pause(ctx, s.Config.ArticleDelay+time.Duration(rand.Intn(500))*time.Millisecond)
+}
 
-			err = s_crawler.saveArticleToFirestore(newsArticle)
-			if err != nil {
-				log.Printf("Firestore save error: %v", err)
-				return
-			}
-			allNews = append(allNews, newsArticle)
+// pauseBetweenPages waits politely between two list pages, unless ctx is done.
+func (s *NewsCrawlerService) pauseBetweenPages(ctx context.Context) {
+	pause(ctx, s.Config.PageDelay+time.Duration(rand.Intn(3))*time.Second)
+}
 
-			time.Sleep(time.Duration(rand.Intn(500)+200) * time.Millisecond)
-		})
+// pause waits for d, or until ctx is done.
+func pause(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
 
-		log.Printf("Page %d collection complete. %d articles collected and saved to Firestore so far.", pageNum, len(allNews))
-		time.Sleep(time.Duration(rand.Intn(3)+2) * time.Second)
+// fetchArticleDocument fetches an article page, retrying on network errors; the retries are
+// events of the span of ctx. It returns the last error when the page could not be obtained;
+// the failure is recorded in health.
+func (s *NewsCrawlerService) fetchArticleDocument(ctx context.Context, articleURL string, health *ParserHealth) (*goquery.Document, error) {
+	retries := s.Config.ArticleFetchRetries
+	logger := health.logger().With(LogKeyStage, StageArticle, LogKeyArticleURL, articleURL)
+	span := trace.SpanFromContext(ctx)
+	var err error
+	for retry := 0; retry < retries; retry++ {
+		started := time.Now()
+		var articleDoc *goquery.Document
+		articleDoc, err = s.Fetcher.FetchDocumentContext(ctx, articleURL, s.Config.ArticleFetchTimeout)
+		if err == nil {
+			logger.Debug("Article fetched", LogKeyDuration, time.Since(started))
+			return articleDoc, nil
+		}
+		if ctx.Err() != nil {
+			break // Interrupted: no retry
+		}
+		if !isRetryableFetchError(err) {
+			logger.Error("Error loading article content", LogKeyError, err)
+			break
+		}
+		logger.Warn("Error loading article content", "attempt", retry+1, "attempts", retries, LogKeyError, err)
+		if retry < retries-1 {
+			fetchRetriesMetric.WithLabelValues(metricHost(articleURL)).Inc()
+			span.AddEvent("retry", trace.WithAttributes(attribute.Int(TraceKeyAttempt, retry+2), attribute.String(LogKeyError, err.Error())))
+			pause(ctx, time.Duration(1+retry)*s.Config.ArticleRetryDelay)
+		}
 	}
-	log.Println("News collection complete.")
-	return allNews, nil
+	health.RecordFetchFailure()
+	return nil, err
+}
+
+// fetchArticleContent fetches an article page and extracts its body into article with the
+// site-specific extractor, falling back to the generic main-content extractor when the selector
+// finds nothing; with a nil extract, the generic extractor is used alone and is no fallback.
+// The page's og:image replaces the list thumbnail as lead image unless the body has images.
+// When no body can be obtained the article keeps its summary; the error is returned when the
+// page could not be fetched, while extraction failures are recorded in health only.
+func (s *NewsCrawlerService) fetchArticleContent(ctx context.Context, article *NewsArticle, extract func(*goquery.Document) ([]ContentBlock, bool), health *ParserHealth) error {
+	ctx, span := tracer.Start(ctx, "article", trace.WithAttributes(attribute.String(TraceKeyArticleURL, article.URL)))
+	defer span.End()
+	article.ExtractionMethod = ExtractionSummary
+	articleDoc, err := s.fetchArticleDocument(ctx, article.URL, health)
+	if err != nil {
+		span.SetStatus(codes.Error, "article page could not be fetched")
+		return err
+	}
+	_, parseSpan := tracer.Start(ctx, "parse article")
+	defer func() {
+		parseSpan.SetAttributes(attribute.String("crawler.extraction_method", article.ExtractionMethod))
+		parseSpan.End()
+	}()
+	if image := metaImageURL(articleDoc, article.URL); image != "" {
+		article.LeadImageURL = image
+	}
+	if extract != nil {
+		if blocks, ok := extract(articleDoc); ok {
+			health.RecordBody(blocksToText(blocks), ExtractionSelector)
+			setArticleBlocks(article, blocks)
+			article.ExtractionMethod = ExtractionSelector
+			return nil
+		}
+		health.RecordBodyFallback(article.URL, articleDoc)
+	}
+
+	blocks, ok := extractMainContent(articleDoc)
+	if !ok {
+		health.logger().Warn("Could not extract article body", LogKeyStage, StageParse, LogKeyArticleURL, article.URL)
+		return nil
+	}
+	if extract != nil {
+		health.logger().Info("Article body extracted by the generic extractor (site selector failed)", LogKeyStage, StageParse, LogKeyArticleURL, article.URL)
+	}
+	health.RecordBody(blocksToText(blocks), ExtractionReadability)
+	setArticleBlocks(article, blocks)
+	article.ExtractionMethod = ExtractionReadability
+	return nil
 }
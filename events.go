@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArticleEvent is a newly saved article published on the EventBus.
+type ArticleEvent struct {
+	ID      uint64
+	Article NewsArticle
+}
+
+// ArticleFilter selects the articles of a subscription. Zero fields do not filter.
+type ArticleFilter struct {
+	Keyword  string // Case-insensitive match on title, summary and content
+	Ticker   string
+	SourceID string
+}
+
+// Matches reports whether article passes the filter.
+func (f ArticleFilter) Matches(article *NewsArticle) bool {
+	if f.SourceID != "" && article.SourceID != f.SourceID {
+		return false
+	}
+	if f.Ticker != "" && !containsString(article.Tickers, f.Ticker) {
+		return false
+	}
+	if f.Keyword != "" {
+		keyword := strings.ToLower(f.Keyword)
+		if !strings.Contains(strings.ToLower(article.Title), keyword) &&
+			!strings.Contains(strings.ToLower(article.Summary), keyword) &&
+			!strings.Contains(strings.ToLower(article.Content), keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// subscriberBufferSize is the number of events a subscriber may lag behind before it is
+// disconnected (it can then resume with Last-Event-ID from the replay buffer).
+const subscriberBufferSize = 64
+
+// EventBus is an in-process publish/subscribe bus for newly saved articles. It keeps the
+// most recent events in a bounded ring buffer so that subscribers can resume after a reconnect.
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	replay      []ArticleEvent // Ring buffer of the latest events
+	replayStart int            // Index of the oldest event in replay
+	replaySize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events matching its filter.
+type Subscription struct {
+	bus    *EventBus
+	filter ArticleFilter
+	events chan ArticleEvent
+	closed bool
+}
+
+// NewEventBus creates an EventBus keeping the last replaySize events for resumption.
+// Event IDs start at the current Unix time in milliseconds, so that IDs handed out
+// before a restart are recognized as too old rather than mistaken for new ones.
+func NewEventBus(replaySize int) *EventBus {
+	if replaySize < 1 {
+		replaySize = 1
+	}
+	return &EventBus{
+		nextID:      uint64(time.Now().UnixMilli()),
+		replaySize:  replaySize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish assigns an ID to a newly saved article and delivers it to the subscribers.
+// Subscribers that cannot keep up are disconnected instead of blocking the crawler.
+func (b *EventBus) Publish(article NewsArticle) ArticleEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := ArticleEvent{ID: b.nextID, Article: article}
+	b.nextID++
+	if len(b.replay) < b.replaySize {
+		b.replay = append(b.replay, event)
+	} else {
+		b.replay[b.replayStart] = event
+		b.replayStart = (b.replayStart + 1) % b.replaySize
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(&event.Article) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.closeLocked(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscriber. When lastEventID is non-zero, the buffered events after it
+// are returned for replay; complete is false when events after lastEventID were already evicted
+// from the buffer (or the ID is unknown), so the client must catch up through the listing API.
+func (b *EventBus) Subscribe(filter ArticleFilter, lastEventID uint64) (sub *Subscription, replay []ArticleEvent, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastEventID != 0 {
+		ordered := b.orderedLocked()
+		if lastEventID >= b.nextID || (len(ordered) > 0 && lastEventID+1 < ordered[0].ID) || (len(ordered) == 0 && lastEventID+1 < b.nextID) {
+			complete = false
+		}
+		for _, event := range ordered {
+			if event.ID > lastEventID && filter.Matches(&event.Article) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub = &Subscription{bus: b, filter: filter, events: make(chan ArticleEvent, subscriberBufferSize)}
+	b.subscribers[sub] = struct{}{}
+	return sub, replay, complete
+}
+
+// orderedLocked returns the buffered events, oldest first.
+func (b *EventBus) orderedLocked() []ArticleEvent {
+	ordered := make([]ArticleEvent, 0, len(b.replay))
+	ordered = append(ordered, b.replay[b.replayStart:]...)
+	return append(ordered, b.replay[:b.replayStart]...)
+}
+
+// SubscriberCount returns the number of active subscribers.
+func (b *EventBus) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// CloseAll ends every subscription, so that the streams end and their clients reconnect
+// (to another instance) with Last-Event-ID. The graceful shutdown uses it.
+func (b *EventBus) CloseAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		b.closeLocked(sub)
+	}
+}
+
+func (b *EventBus) closeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+// Events returns the channel of events; it is closed when the subscription ends
+// (Close, or the subscriber lagged too far behind).
+func (s *Subscription) Events() <-chan ArticleEvent {
+	return s.events
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.closeLocked(s)
+}
+
+// parseLastEventID parses the Last-Event-ID header (or lastEventId query parameter).
+func parseLastEventID(value string) uint64 {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// writeArticleEvent writes an event in the Server-Sent Events format.
+func writeArticleEvent(w *bufio.Writer, event ArticleEvent) error {
+	data, err := json.Marshal(event.Article)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: article\ndata: %s\n\n", event.ID, data)
+	return err
+}
+
+// streamHeartbeatInterval is the interval of the keep-alive comments sent on idle streams,
+// which also detect disconnected clients.
+const streamHeartbeatInterval = 15 * time.Second
+
+// streamArticleEvents writes the replayed events, then the live events of sub, until the
+// client disconnects or the subscription ends. A "reset" event tells clients whose
+// Last-Event-ID could not be fully resumed to reload through the listing API.
+func streamArticleEvents(w *bufio.Writer, sub *Subscription, replay []ArticleEvent, complete bool) {
+	defer sub.Close()
+
+	fmt.Fprintf(w, "retry: 5000\n\n")
+	if !complete {
+		fmt.Fprintf(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		if err := writeArticleEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return // Lagged behind; the client reconnects with Last-Event-ID
+			}
+			if err := writeArticleEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+		}
+		if err := w.Flush(); err != nil {
+			return // Client disconnected
+		}
+	}
+}
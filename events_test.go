@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEventBusDeliversFilteredEvents(t *testing.T) {
+	bus := NewEventBus(10)
+	sub, replay, complete := bus.Subscribe(ArticleFilter{Ticker: "005930"}, 0)
+	defer sub.Close()
+	if len(replay) != 0 || !complete {
+		t.Fatalf("fresh subscription: replay=%v complete=%v", replay, complete)
+	}
+
+	bus.Publish(NewsArticle{Title: "코스피 마감"})
+	published := bus.Publish(NewsArticle{Title: "삼성전자 실적", Tickers: []string{"005930"}})
+
+	select {
+	case event := <-sub.Events():
+		if event.ID != published.ID || event.Article.Title != "삼성전자 실적" {
+			t.Errorf("got event %d %q", event.ID, event.Article.Title)
+		}
+	default:
+		t.Fatal("matching event was not delivered")
+	}
+	select {
+	case event := <-sub.Events():
+		t.Errorf("unexpected event %q", event.Article.Title)
+	default:
+	}
+}
+
+func TestEventBusReplay(t *testing.T) {
+	bus := NewEventBus(3)
+	var ids []uint64
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		ids = append(ids, bus.Publish(NewsArticle{Title: title}).ID)
+	}
+
+	// Only "c", "d" and "e" are still buffered: resuming after "b" is complete.
+	sub, replay, complete := bus.Subscribe(ArticleFilter{}, ids[1])
+	sub.Close()
+	if !complete || len(replay) != 3 || replay[0].Article.Title != "c" || replay[2].Article.Title != "e" {
+		t.Errorf("resume after b: complete=%v replay=%v", complete, replay)
+	}
+
+	// "b" was evicted: resuming after "a" is incomplete.
+	sub, replay, complete = bus.Subscribe(ArticleFilter{}, ids[0])
+	sub.Close()
+	if complete || len(replay) != 3 {
+		t.Errorf("resume after a: complete=%v replay=%d events", complete, len(replay))
+	}
+
+	// IDs from another process (or the future) cannot be resumed.
+	sub, _, complete = bus.Subscribe(ArticleFilter{}, ids[4]+100)
+	sub.Close()
+	if complete {
+		t.Error("unknown future ID reported as complete")
+	}
+}
+
+func TestEventBusDropsLaggingSubscribers(t *testing.T) {
+	bus := NewEventBus(10)
+	sub, _, _ := bus.Subscribe(ArticleFilter{}, 0)
+	for i := 0; i <= subscriberBufferSize; i++ {
+		bus.Publish(NewsArticle{Title: "x"})
+	}
+	if n := bus.SubscriberCount(); n != 0 {
+		t.Fatalf("SubscriberCount = %d, want 0", n)
+	}
+	count := 0
+	for range sub.Events() {
+		count++
+	}
+	if count != subscriberBufferSize {
+		t.Errorf("drained %d events, want %d", count, subscriberBufferSize)
+	}
+	sub.Close() // Closing twice is harmless
+}
+
+func TestStreamArticleEventsFormat(t *testing.T) {
+	bus := NewEventBus(10)
+	first := bus.Publish(NewsArticle{Title: "첫 기사", URL: "https://example.com/1"})
+	bus.Publish(NewsArticle{Title: "둘째 기사", URL: "https://example.com/2"})
+
+	sub, replay, complete := bus.Subscribe(ArticleFilter{}, first.ID)
+	sub.Close() // The stream ends after the replay
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	streamArticleEvents(w, sub, replay, complete)
+	w.Flush()
+
+	out := buf.String()
+	if strings.Contains(out, "첫 기사") || !strings.Contains(out, fmt.Sprintf("id: %d\nevent: article\ndata: {\"title\":\"둘째 기사\"", first.ID+1)) {
+		t.Errorf("unexpected stream:\n%s", out)
+	}
+	if strings.Contains(out, "event: reset") {
+		t.Errorf("complete resumption must not send a reset:\n%s", out)
+	}
+}
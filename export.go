@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	ExportNDJSON  = "ndjson"  // One NewsArticle JSON object per line
+	ExportCSV     = "csv"     // UTF-8 with a byte order mark, so that Excel detects the encoding
+	ExportParquet = "parquet" // Typed columns for data analysis tools
+)
+
+// exportContentTypes are the HTTP content types of the export formats.
+var exportContentTypes = map[string]string{
+	ExportNDJSON:  "application/x-ndjson",
+	ExportCSV:     "text/csv; charset=utf-8",
+	ExportParquet: "application/vnd.apache.parquet",
+}
+
+// parseExportFormat normalizes an export format name; jsonl is accepted for ndjson.
+func parseExportFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", ExportNDJSON, "jsonl":
+		return ExportNDJSON, nil
+	case ExportCSV:
+		return ExportCSV, nil
+	case ExportParquet:
+		return ExportParquet, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (expected ndjson, csv or parquet)", format)
+}
+
+// exportFormatFromPath guesses the format of an export file from its extension.
+func exportFormatFromPath(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".csv"):
+		return ExportCSV
+	case strings.HasSuffix(path, ".parquet"):
+		return ExportParquet
+	}
+	return ExportNDJSON
+}
+
+// exportColumn is a column of the CSV and Parquet exports. Structured fields (content blocks,
+// images, figures) are carried as JSON, so that an import restores the articles completely.
+type exportColumn struct {
+	parquetColumn
+	get func(a *NewsArticle) interface{}          // int32, int64, float64, string, or nil (optional columns)
+	set func(a *NewsArticle, v interface{}) error // v is of the same type
+}
+
+// csvListSeparator joins list values (categories, tickers) in a cell.
+const csvListSeparator = "|"
+
+func textColumn(name string, field func(a *NewsArticle) *string) exportColumn {
+	return exportColumn{
+		parquetColumn: parquetColumn{Name: name, Type: parquetByteArray, ConvertedType: parquetUTF8},
+		get:           func(a *NewsArticle) interface{} { return *field(a) },
+		set:           func(a *NewsArticle, v interface{}) error { *field(a) = v.(string); return nil },
+	}
+}
+
+func intColumn(name string, field func(a *NewsArticle) *int) exportColumn {
+	return exportColumn{
+		parquetColumn: parquetColumn{Name: name, Type: parquetInt32, ConvertedType: parquetNoConvertedType},
+		get:           func(a *NewsArticle) interface{} { return int32(*field(a)) },
+		set:           func(a *NewsArticle, v interface{}) error { *field(a) = int(v.(int32)); return nil },
+	}
+}
+
+func timeColumn(name string, optional bool, field func(a *NewsArticle) *time.Time) exportColumn {
+	return exportColumn{
+		parquetColumn: parquetColumn{Name: name, Type: parquetInt64, ConvertedType: parquetTimestampMillis, Optional: optional},
+		get: func(a *NewsArticle) interface{} {
+			if optional && field(a).IsZero() {
+				return nil
+			}
+			return field(a).UnixMilli()
+		},
+		set: func(a *NewsArticle, v interface{}) error { *field(a) = time.UnixMilli(v.(int64)).UTC(); return nil },
+	}
+}
+
+func listColumn(name string, field func(a *NewsArticle) *[]string) exportColumn {
+	return exportColumn{
+		parquetColumn: parquetColumn{Name: name, Type: parquetByteArray, ConvertedType: parquetUTF8},
+		get:           func(a *NewsArticle) interface{} { return strings.Join(*field(a), csvListSeparator) },
+		set: func(a *NewsArticle, v interface{}) error {
+			if v.(string) != "" {
+				*field(a) = strings.Split(v.(string), csvListSeparator)
+			}
+			return nil
+		},
+	}
+}
+
+func jsonColumn(name string, field func(a *NewsArticle) interface{}) exportColumn {
+	return exportColumn{
+		parquetColumn: parquetColumn{Name: name, Type: parquetByteArray, ConvertedType: parquetUTF8},
+		get: func(a *NewsArticle) interface{} {
+			data, err := json.Marshal(field(a))
+			if err != nil || string(data) == "null" {
+				return ""
+			}
+			return string(data)
+		},
+		set: func(a *NewsArticle, v interface{}) error {
+			if v.(string) == "" {
+				return nil
+			}
+			if err := json.Unmarshal([]byte(v.(string)), field(a)); err != nil {
+				return fmt.Errorf("invalid %s: %v", name, err)
+			}
+			return nil
+		},
+	}
+}
+
+// exportColumns are the columns of the CSV and Parquet exports, in order.
+var exportColumns = []exportColumn{
+	textColumn("url", func(a *NewsArticle) *string { return &a.URL }),
+	textColumn("title", func(a *NewsArticle) *string { return &a.Title }),
+	textColumn("source", func(a *NewsArticle) *string { return &a.Source }),
+	textColumn("sourceId", func(a *NewsArticle) *string { return &a.SourceID }),
+	textColumn("officeId", func(a *NewsArticle) *string { return &a.OfficeID }),
+	timeColumn("publishedAt", true, func(a *NewsArticle) *time.Time { return &a.PublishedAt }),
+	timeColumn("collectedAt", false, func(a *NewsArticle) *time.Time { return &a.CollectedAt }),
+	textColumn("author", func(a *NewsArticle) *string { return &a.Author }),
+	listColumn("categories", func(a *NewsArticle) *[]string { return &a.Categories }),
+	listColumn("tickers", func(a *NewsArticle) *[]string { return &a.Tickers }),
+	{
+		parquetColumn: parquetColumn{Name: "sentiment", Type: parquetDouble, ConvertedType: parquetNoConvertedType, Optional: true},
+		get: func(a *NewsArticle) interface{} {
+			if a.Sentiment == nil {
+				return nil
+			}
+			return *a.Sentiment
+		},
+		set: func(a *NewsArticle, v interface{}) error { score := v.(float64); a.Sentiment = &score; return nil },
+	},
+	textColumn("summary", func(a *NewsArticle) *string { return &a.Summary }),
+	textColumn("aiSummary", func(a *NewsArticle) *string { return &a.AISummary }),
+	intColumn("summaryRetryCount", func(a *NewsArticle) *int { return &a.SummaryRetryCount }),
+	textColumn("content", func(a *NewsArticle) *string { return &a.Content }),
+	textColumn("contentMarkdown", func(a *NewsArticle) *string { return &a.ContentMarkdown }),
+	textColumn("extractionMethod", func(a *NewsArticle) *string { return &a.ExtractionMethod }),
+	textColumn("leadImageUrl", func(a *NewsArticle) *string { return &a.LeadImageURL }),
+	textColumn("leadImageStoredUrl", func(a *NewsArticle) *string { return &a.LeadImageStoredURL }),
+	intColumn("leadImageWidth", func(a *NewsArticle) *int { return &a.LeadImageWidth }),
+	intColumn("leadImageHeight", func(a *NewsArticle) *int { return &a.LeadImageHeight }),
+	textColumn("thumbnailUrl", func(a *NewsArticle) *string { return &a.ThumbnailURL }),
+	jsonColumn("contentBlocks", func(a *NewsArticle) interface{} { return &a.ContentBlocks }),
+	jsonColumn("images", func(a *NewsArticle) interface{} { return &a.Images }),
+	jsonColumn("figures", func(a *NewsArticle) interface{} { return &a.Figures }),
+}
+
+// formatCSVValue renders a column value in a CSV cell. Times are RFC 3339, and strings a
+// spreadsheet would evaluate as a formula are prefixed with a quote.
+func formatCSVValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case int32:
+		return strconv.Itoa(int(v))
+	case int64: // Timestamps
+		return time.UnixMilli(v).UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		if v != "" && strings.ContainsRune(csvFormulaPrefixes, rune(v[0])) {
+			return "'" + v // Shown as text by spreadsheets; removed by parseCSVValue
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// csvFormulaPrefixes are the first characters that make spreadsheets evaluate a cell as a
+// formula (scraped titles could inject one), and the quote escaping them.
+const csvFormulaPrefixes = "=+-@\t\r'"
+
+// parseCSVValue parses a CSV cell into a column value (nil for an empty optional cell).
+func parseCSVValue(column *exportColumn, cell string) (interface{}, error) {
+	switch column.Type {
+	case parquetByteArray:
+		return strings.TrimPrefix(cell, "'"), nil // See formatCSVValue
+	case parquetInt32:
+		if cell == "" {
+			return int32(0), nil
+		}
+		n, err := strconv.ParseInt(cell, 10, 32)
+		return int32(n), err
+	case parquetDouble:
+		if cell == "" {
+			if column.Optional {
+				return nil, nil
+			}
+			return 0.0, nil
+		}
+		return strconv.ParseFloat(cell, 64)
+	case parquetInt64:
+		if cell == "" {
+			if column.Optional {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("value required")
+		}
+		t, err := time.Parse(time.RFC3339, cell)
+		if err != nil {
+			return nil, fmt.Errorf("expected an RFC 3339 time, got %q", cell)
+		}
+		return t.UnixMilli(), nil
+	}
+	return nil, fmt.Errorf("unsupported column type")
+}
+
+// setExportValue stores a decoded value into the article; nil leaves the zero value.
+func setExportValue(a *NewsArticle, column *exportColumn, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	return column.set(a, v)
+}
+
+// articleEncoder writes articles in an export format.
+type articleEncoder interface {
+	Encode(article *NewsArticle) error
+	Close() error // Flushes buffered rows (and writes the Parquet footer)
+}
+
+// newArticleEncoder returns an encoder writing format to w.
+func newArticleEncoder(format string, w io.Writer) (articleEncoder, error) {
+	switch format {
+	case ExportNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	case ExportCSV:
+		if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+			return nil, err
+		}
+		cw := csv.NewWriter(w)
+		header := make([]string, len(exportColumns))
+		for i := range exportColumns {
+			header[i] = exportColumns[i].Name
+		}
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{w: cw}, nil
+	case ExportParquet:
+		columns := make([]parquetColumn, len(exportColumns))
+		for i := range exportColumns {
+			columns[i] = exportColumns[i].parquetColumn
+		}
+		pw, err := newParquetWriter(w, columns)
+		if err != nil {
+			return nil, err
+		}
+		pw.RowGroupSize = 2000 // Article bodies are large: keep row groups (buffered in memory) small
+		return &parquetEncoder{w: pw}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+type ndjsonEncoder struct{ enc *json.Encoder }
+
+func (e *ndjsonEncoder) Encode(article *NewsArticle) error { return e.enc.Encode(article) }
+func (e *ndjsonEncoder) Close() error                      { return nil }
+
+type csvEncoder struct{ w *csv.Writer }
+
+func (e *csvEncoder) Encode(article *NewsArticle) error {
+	row := make([]string, len(exportColumns))
+	for i := range exportColumns {
+		row[i] = formatCSVValue(exportColumns[i].get(article))
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type parquetEncoder struct{ w *parquetWriter }
+
+func (e *parquetEncoder) Encode(article *NewsArticle) error {
+	row := make([]interface{}, len(exportColumns))
+	for i := range exportColumns {
+		row[i] = exportColumns[i].get(article)
+	}
+	return e.w.Write(row)
+}
+
+func (e *parquetEncoder) Close() error { return e.w.Close() }
+
+// ExportArticles writes the articles matching q (pagination aside), newest first, to w.
+// max limits the number of articles (0 exports all). It returns the number of articles written.
+func ExportArticles(ctx context.Context, store ArticleStore, q ArticleQuery, max int, format string, w io.Writer) (int, error) {
+	enc, err := newArticleEncoder(format, w)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = walkArticles(ctx, store, q, func(article *NewsArticle) (bool, error) {
+		if max > 0 && count >= max {
+			return false, nil
+		}
+		if err := enc.Encode(article); err != nil {
+			return false, fmt.Errorf("error writing article %s: %v", article.URL, err)
+		}
+		count++
+		return true, nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, enc.Close()
+}
+
+// ImportResult summarizes an import.
+type ImportResult struct {
+	Imported int
+	Skipped  int // Already stored and not overwritten
+}
+
+// ImportArticles loads an export file (any format) into store. Articles already stored
+// are skipped unless overwrite is set.
+func ImportArticles(ctx context.Context, store ArticleStore, path, format string, overwrite bool) (ImportResult, error) {
+	var result ImportResult
+	save := func(article NewsArticle) error {
+		if article.URL == "" {
+			return fmt.Errorf("article without url (title %q)", article.Title)
+		}
+		if !overwrite {
+			existing, err := store.GetArticle(ctx, article.URL)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				result.Skipped++
+				return nil
+			}
+		}
+		if err := store.SaveArticle(ctx, article); err != nil {
+			return fmt.Errorf("error saving %s: %v", article.URL, err)
+		}
+		result.Imported++
+		return nil
+	}
+
+	var err error
+	switch format {
+	case ExportNDJSON:
+		err = importNDJSON(path, save)
+	case ExportCSV:
+		err = importCSV(path, save)
+	case ExportParquet:
+		err = importParquet(path, save)
+	default:
+		err = fmt.Errorf("unknown export format %q", format)
+	}
+	return result, err
+}
+
+func importNDJSON(path string, save func(NewsArticle) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20) // Article bodies can be long
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var article NewsArticle
+		if err := json.Unmarshal(scanner.Bytes(), &article); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if err := save(article); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func importCSV(path string, save func(NewsArticle) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if bom, _ := br.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("%s: error reading CSV header: %v", path, err)
+	}
+	// Columns are matched by name: unknown ones are ignored and missing ones stay empty,
+	// so that spreadsheets edited by hand can be imported.
+	columns := make([]*exportColumn, len(header))
+	hasURL := false
+	for i, name := range header {
+		for j := range exportColumns {
+			if exportColumns[j].Name == strings.TrimSpace(name) {
+				columns[i] = &exportColumns[j]
+				hasURL = hasURL || exportColumns[j].Name == "url"
+			}
+		}
+	}
+	if !hasURL {
+		return fmt.Errorf("%s: the url column is required", path)
+	}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		var article NewsArticle
+		for i, cell := range row {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			v, err := parseCSVValue(columns[i], cell)
+			if err == nil {
+				err = setExportValue(&article, columns[i], v)
+			}
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s: %v", path, line, columns[i].Name, err)
+			}
+		}
+		if err := save(article); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+	}
+}
+
+func importParquet(path string, save func(NewsArticle) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	pr, err := openParquet(f, info.Size())
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Columns are matched by name, as for CSV.
+	columns := make([]*exportColumn, len(pr.Columns))
+	for i, pc := range pr.Columns {
+		for j := range exportColumns {
+			if exportColumns[j].Name == pc.Name {
+				if exportColumns[j].Type != pc.Type {
+					return fmt.Errorf("%s: column %s has an unexpected type", path, pc.Name)
+				}
+				columns[i] = &exportColumns[j]
+			}
+		}
+	}
+	rowNumber := 0
+	return pr.ReadRows(func(row []interface{}) error {
+		rowNumber++
+		var article NewsArticle
+		for i, v := range row {
+			if columns[i] == nil {
+				continue
+			}
+			if err := setExportValue(&article, columns[i], v); err != nil {
+				return fmt.Errorf("%s: row %d: %s: %v", path, rowNumber, columns[i].Name, err)
+			}
+		}
+		if err := save(article); err != nil {
+			return fmt.Errorf("%s: row %d: %v", path, rowNumber, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	parquetbuffer "github.com/xitongsys/parquet-go-source/buffer"
+	parquetreader "github.com/xitongsys/parquet-go/reader"
+)
+
+// exportTestArticle uses every field carried by the exports.
+func exportTestArticle() NewsArticle {
+	sentiment := 0.5
+	return NewsArticle{
+		Title:              "삼성전자, \"1분기\" 영업이익 6.6조원",
+		Summary:            "=HYPERLINK(\"https://evil.example\", \"요약\"), 쉼표 포함", // Formula injection
+		Content:            "첫 문단.\n\n둘째 문단.",
+		AISummary:          "AI 요약",
+		Source:             "한국경제",
+		URL:                "https://www.hankyung.com/article/2024051012345",
+		CollectedAt:        time.Date(2024, 5, 10, 20, 0, 0, 0, time.UTC),
+		SummaryRetryCount:  1,
+		SourceID:           "korean-business-feeds",
+		OfficeID:           "015",
+		PublishedAt:        time.Date(2024, 5, 10, 6, 30, 0, 0, time.UTC),
+		Author:             "'홍길동' 기자",
+		Categories:         []string{"증권", "반도체"},
+		Tickers:            []string{"005930", "000660"},
+		ExtractionMethod:   ExtractionSelector,
+		ContentBlocks:      []ContentBlock{{Type: "paragraph", Text: "첫 문단."}, {Type: "heading", Text: "소제목", Level: 2}},
+		ContentMarkdown:    "첫 문단.\n\n## 소제목",
+		Images:             []ArticleImage{{URL: "https://img.example.com/1.jpg", Caption: "사진", Position: 1}},
+		LeadImageURL:       "https://img.example.com/1.jpg",
+		Figures:            []ArticleFigure{{Text: "6.6조원", Value: 6.6e12, Unit: "KRW"}},
+		Sentiment:          &sentiment,
+		LeadImageStoredURL: "/media/lead.jpg",
+		LeadImageWidth:     800,
+		LeadImageHeight:    600,
+		ThumbnailURL:       "/media/thumb.jpg",
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	source := seedMemoryStore(t)
+	full := exportTestArticle()
+	if err := source.SaveArticle(context.Background(), full); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{ExportNDJSON, ExportCSV, ExportParquet} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			count, err := ExportArticles(context.Background(), source, ArticleQuery{}, 0, format, &buf)
+			if err != nil || count != 8 {
+				t.Fatalf("exported %d: %v", count, err)
+			}
+			if format == ExportCSV && !strings.HasPrefix(buf.String(), "\xef\xbb\xbfurl,title,") {
+				t.Errorf("CSV does not start with a BOM and the header: %q", buf.String()[:20])
+			}
+			if format == ExportCSV && (strings.Contains(buf.String(), `"=HYPERLINK`) || !strings.Contains(buf.String(), `"'=HYPERLINK`)) {
+				t.Error("CSV cell starting with = is not escaped")
+			}
+			path := filepath.Join(t.TempDir(), "articles."+format)
+			if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			target := NewMemoryArticleStore()
+			result, err := ImportArticles(context.Background(), target, path, exportFormatFromPath(path), false)
+			if err != nil || result.Imported != 8 || result.Skipped != 0 {
+				t.Fatalf("import: %+v, %v", result, err)
+			}
+			got, _ := target.GetArticle(context.Background(), full.URL)
+			if got == nil || !reflect.DeepEqual(*got, full) {
+				t.Errorf("round trip changed the article:\n got %+v\nwant %+v", got, full)
+			}
+			// Zero values survive too: no publication time, no lists.
+			want, _ := source.GetArticle(context.Background(), "https://n.news.naver.com/mnews/article/015/0000000001")
+			got, _ = target.GetArticle(context.Background(), want.URL)
+			if got == nil || !reflect.DeepEqual(*got, *want) {
+				t.Errorf("round trip changed the article:\n got %+v\nwant %+v", got, want)
+			}
+
+			// A second import skips the stored articles, unless overwriting.
+			if result, err := ImportArticles(context.Background(), target, path, format, false); err != nil || result.Imported != 0 || result.Skipped != 8 {
+				t.Errorf("re-import: %+v, %v", result, err)
+			}
+			if result, err := ImportArticles(context.Background(), target, path, format, true); err != nil || result.Imported != 8 {
+				t.Errorf("overwrite: %+v, %v", result, err)
+			}
+		})
+	}
+}
+
+func TestExportFiltersAndLimit(t *testing.T) {
+	store := seedMemoryStore(t)
+	var buf bytes.Buffer
+	count, err := ExportArticles(context.Background(), store, ArticleQuery{Publisher: "015"}, 0, ExportNDJSON, &buf)
+	if err != nil || count != 6 || strings.Count(buf.String(), "\n") != 6 {
+		t.Errorf("publisher filter: %d, %v", count, err)
+	}
+	buf.Reset()
+	count, err = ExportArticles(context.Background(), store, ArticleQuery{}, 3, ExportNDJSON, &buf)
+	if err != nil || count != 3 || !strings.HasPrefix(buf.String(), `{"title":"article 6"`) {
+		t.Errorf("limit: %d, %v\n%s", count, err, buf.String())
+	}
+
+	// A shutdown cancels the exports in progress.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buf.Reset()
+	if count, err = ExportArticles(ctx, store, ArticleQuery{}, 0, ExportNDJSON, &buf); !errors.Is(err, context.Canceled) || count != 0 {
+		t.Errorf("cancelled export: %d, %v", count, err)
+	}
+}
+
+func TestParquetCodec(t *testing.T) {
+	columns := []parquetColumn{
+		{Name: "id", Type: parquetInt32, ConvertedType: parquetNoConvertedType},
+		{Name: "at", Type: parquetInt64, ConvertedType: parquetTimestampMillis, Optional: true},
+		{Name: "score", Type: parquetDouble, ConvertedType: parquetNoConvertedType},
+		{Name: "name", Type: parquetByteArray, ConvertedType: parquetUTF8, Optional: true},
+	}
+	var buf bytes.Buffer
+	pw, err := newParquetWriter(&buf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.RowGroupSize = 4 // Several row groups, the last one partial
+	var want [][]interface{}
+	for i := 0; i < 10; i++ {
+		row := []interface{}{int32(i), int64(i) * 1000, float64(i) / 4, strings.Repeat("가", i)}
+		if i%3 == 0 {
+			row[1], row[3] = nil, nil
+		}
+		want = append(want, row)
+		if err := pw.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Write([]interface{}{nil, nil, 0.0, nil}); err == nil {
+		t.Error("null in a required column accepted")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing magic")
+	}
+
+	pr, err := openParquet(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pr.Columns, columns) {
+		t.Errorf("schema = %+v", pr.Columns)
+	}
+	var got [][]interface{}
+	if err := pr.ReadRows(func(row []interface{}) error {
+		got = append(got, append([]interface{}(nil), row...))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v\nwant %v", got, want)
+	}
+
+	if _, err := openParquet(bytes.NewReader(data[:len(data)-1]), int64(len(data)-1)); err == nil {
+		t.Error("truncated file accepted")
+	}
+}
+
+// TestParquetExportInterop reads an export with an independent Parquet implementation.
+func TestParquetExportInterop(t *testing.T) {
+	store := seedMemoryStore(t)
+	full := exportTestArticle()
+	if err := store.SaveArticle(context.Background(), full); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc, err := newArticleEncoder(ExportParquet, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.(*parquetEncoder).w.RowGroupSize = 3 // Several row groups, the last one partial
+	var articles []*NewsArticle
+	if err := walkArticles(context.Background(), store, ArticleQuery{}, func(article *NewsArticle) (bool, error) {
+		articles = append(articles, article)
+		return true, enc.Encode(article)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquetbuffer.NewBufferFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := parquetreader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if pr.GetNumRows() != int64(len(articles)) || len(pr.Footer.RowGroups) != 3 {
+		t.Fatalf("%d rows in %d row groups, want %d in 3", pr.GetNumRows(), len(pr.Footer.RowGroups), len(articles))
+	}
+	if len(pr.SchemaHandler.Infos) != len(exportColumns)+1 {
+		t.Fatalf("%d schema elements, want the root and %d columns", len(pr.SchemaHandler.Infos), len(exportColumns))
+	}
+	for i, column := range exportColumns {
+		if name := pr.SchemaHandler.Infos[i+1].ExName; name != column.Name {
+			t.Errorf("column %d is %s, want %s", i, name, column.Name)
+			continue
+		}
+		values, _, _, err := pr.ReadColumnByIndex(int64(i), pr.GetNumRows())
+		if err != nil {
+			t.Fatalf("column %s: %v", column.Name, err)
+		}
+		for j, article := range articles {
+			if want := column.get(article); j >= len(values) || !reflect.DeepEqual(values[j], want) {
+				t.Errorf("column %s of %s = %v, want %v", column.Name, article.URL, values, want)
+				break
+			}
+		}
+	}
+}
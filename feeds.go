@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FeedConfig describes a single RSS 2.0 / Atom feed of a feed source.
+type FeedConfig struct {
+	URL       string `json:"url" yaml:"url"`
+	Publisher string `json:"publisher" yaml:"publisher"` // Stored as the article Source (press name)
+
+	// Body optionally fetches the full text of every item from its link.
+	// FullText fetches it with the generic main-content extractor alone.
+	// Without either, the item description is kept as content.
+	Body     FieldSelector `json:"body,omitempty" yaml:"body,omitempty"`
+	FullText bool          `json:"fullText,omitempty" yaml:"fullText,omitempty"`
+}
+
+// rssDocument is an RSS 2.0 document (<rss><channel><item>).
+type rssDocument struct {
+	Items []rssItem `xml:"channel>item"`
+}
+
+// rdfDocument is an RSS 1.0 document (<rdf:RDF><item>).
+type rdfDocument struct {
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string `xml:"category"`
+	Enclosures  []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	MediaContent []struct {
+		URL    string `xml:"url,attr"`
+		Medium string `xml:"medium,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnail struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+	ID        string `xml:"id"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Categories []struct {
+		Term  string `xml:"term,attr"`
+		Label string `xml:"label,attr"`
+	} `xml:"category"`
+}
+
+var xmlEncodingPattern = regexp.MustCompile(`^\s*<\?xml[^>]*encoding=["']([A-Za-z0-9._-]+)["']`)
+
+// parseFeed parses an RSS 2.0, RSS 1.0 or Atom document into articles.
+// The body is converted to UTF-8 using the XML declaration, or else the Content-Type charset.
+func parseFeed(body []byte, contentType string, feed FeedConfig) ([]NewsArticle, error) {
+	if match := xmlEncodingPattern.FindSubmatch(body); match != nil {
+		contentType = "charset=" + string(match[1])
+	}
+	utf8Body, err := io.ReadAll(decodeBody(body, contentType))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding feed %s: %v", feed.URL, err)
+	}
+
+	root, err := feedRootElement(utf8Body)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing feed %s: %v", feed.URL, err)
+	}
+
+	var articles []NewsArticle
+	switch root {
+	case "rss", "RDF":
+		var items []rssItem
+		if root == "rss" {
+			var doc rssDocument
+			err = newFeedDecoder(utf8Body).Decode(&doc)
+			items = doc.Items
+		} else {
+			var doc rdfDocument
+			err = newFeedDecoder(utf8Body).Decode(&doc)
+			items = doc.Items
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing RSS feed %s: %v", feed.URL, err)
+		}
+		for _, item := range items {
+			articles = append(articles, item.toArticle(feed))
+		}
+	case "feed":
+		var doc atomFeed
+		if err := newFeedDecoder(utf8Body).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("error parsing Atom feed %s: %v", feed.URL, err)
+		}
+		for _, entry := range doc.Entries {
+			articles = append(articles, entry.toArticle(feed))
+		}
+	default:
+		return nil, fmt.Errorf("feed %s has unsupported root element <%s>", feed.URL, root)
+	}
+
+	valid := articles[:0]
+	for _, article := range articles {
+		if article.Title == "" || article.URL == "" {
+			componentLogger(LogComponentCrawler).Warn("Feed item without title or link. Skipping.", LogKeyStage, StageParse, LogKeyPage, feed.URL, "title", article.Title)
+			continue
+		}
+		valid = append(valid, article)
+	}
+	return valid, nil
+}
+
+// newFeedDecoder returns a lenient XML decoder for an already UTF-8 encoded feed.
+func newFeedDecoder(utf8Body []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(utf8Body))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	// The body was converted to UTF-8 already; ignore the declared encoding.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+// feedRootElement returns the local name of the document's root element.
+func feedRootElement(utf8Body []byte) (string, error) {
+	decoder := newFeedDecoder(utf8Body)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func (item rssItem) toArticle(feed FeedConfig) NewsArticle {
+	link := strings.TrimSpace(item.Link)
+	if link == "" && strings.HasPrefix(strings.TrimSpace(item.GUID), "http") {
+		link = strings.TrimSpace(item.GUID)
+	}
+	author := item.Creator
+	if author == "" {
+		author = item.Author
+	}
+	date := item.PubDate
+	if date == "" {
+		date = item.Date
+	}
+	summary := htmlToText(item.Description)
+	return NewsArticle{
+		LeadImageURL: item.imageURL(),
+		Title:        cleanUTF8String(htmlToText(item.Title)),
+		Summary:      cleanUTF8String(summary),
+		Content:      cleanUTF8String(summary),
+		Source:       feed.Publisher,
+		URL:          cleanUTF8String(link),
+		PublishedAt:  parseFeedDate(date),
+		Author:       cleanUTF8String(strings.TrimSpace(author)),
+		Categories:   trimAll(item.Categories),
+	}
+}
+
+// imageURL returns the item's image: an image enclosure, Media RSS content or thumbnail.
+func (item rssItem) imageURL() string {
+	for _, enclosure := range item.Enclosures {
+		if strings.HasPrefix(enclosure.Type, "image/") {
+			return strings.TrimSpace(enclosure.URL)
+		}
+	}
+	for _, content := range item.MediaContent {
+		if content.Medium == "image" || strings.HasPrefix(content.Type, "image/") {
+			return strings.TrimSpace(content.URL)
+		}
+	}
+	return strings.TrimSpace(item.MediaThumbnail.URL)
+}
+
+func (entry atomEntry) toArticle(feed FeedConfig) NewsArticle {
+	var link, image string
+	for _, l := range entry.Links {
+		switch {
+		case link == "" && (l.Rel == "" || l.Rel == "alternate"):
+			link = strings.TrimSpace(l.Href)
+		case image == "" && l.Rel == "enclosure" && strings.HasPrefix(l.Type, "image/"):
+			image = strings.TrimSpace(l.Href)
+		}
+	}
+	summary := htmlToText(entry.Summary)
+	if summary == "" {
+		summary = htmlToText(entry.Content)
+	}
+	date := entry.Published
+	if date == "" {
+		date = entry.Updated
+	}
+	var authors, categories []string
+	for _, a := range entry.Authors {
+		authors = append(authors, a.Name)
+	}
+	for _, c := range entry.Categories {
+		if c.Label != "" {
+			categories = append(categories, c.Label)
+		} else {
+			categories = append(categories, c.Term)
+		}
+	}
+	return NewsArticle{
+		Title:        cleanUTF8String(htmlToText(entry.Title)),
+		Summary:      cleanUTF8String(summary),
+		Content:      cleanUTF8String(summary),
+		Source:       feed.Publisher,
+		URL:          cleanUTF8String(link),
+		PublishedAt:  parseFeedDate(date),
+		Author:       cleanUTF8String(strings.Join(trimAll(authors), ", ")),
+		Categories:   trimAll(categories),
+		LeadImageURL: image,
+	}
+}
+
+// htmlToText strips the markup that feeds commonly embed in titles and descriptions.
+func htmlToText(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "<") && !strings.Contains(s, "&") {
+		return s
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+// trimAll trims every value and drops the empty ones.
+func trimAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, cleanUTF8String(v))
+		}
+	}
+	return out
+}
+
+// feedDateLayouts lists the date formats seen in RSS/Atom feeds, most common first.
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006.01.02 15:04:05",
+	"2006.01.02 15:04",
+	"2006-01-02",
+}
+
+var feedDateReplacer = strings.NewReplacer("KST", "+0900", "GMT+09:00", "+0900", "UTC", "+0000", "GMT", "+0000")
+
+// parseFeedDate parses a feed date leniently. Dates without a zone are taken as Asia/Seoul;
+// unparseable dates are logged and yield the zero time.
+func parseFeedDate(value string) time.Time {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return time.Time{}
+	}
+	normalized := feedDateReplacer.Replace(value)
+	seoul, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		seoul = time.FixedZone("KST", 9*60*60)
+	}
+	for _, layout := range feedDateLayouts {
+		if t, err := time.ParseInLocation(layout, normalized, seoul); err == nil {
+			return t
+		}
+	}
+	componentLogger(LogComponentCrawler).Warn("Could not parse feed date", LogKeyStage, StageParse, "date", value)
+	return time.Time{}
+}
+
+// crawlFeeds reads every feed of a feed source and saves the new items,
+// fetching their full text when the feed has a body selector.
+func (s *NewsCrawlerService) crawlFeeds(def *SourceDefinition) ([]NewsArticle, error) {
+	ctx, end := s.startCrawl(def.ID)
+	defer end()
+	allNews := []NewsArticle{}
+	health := s.newParserHealth(def.ID)
+	health.logger().Info("Starting collection of feed source", "feeds", len(def.Feeds))
+	run := s.startRun(ctx, health, RunParams{})
+	var fetchErr error
+	fetched := 0
+
+	for _, feed := range def.Feeds {
+		if ctx.Err() != nil {
+			break // Interrupted: the remaining feeds are read by the next run
+		}
+		logger := health.logger().With(LogKeyPage, feed.URL)
+		pageCtx, pageSpan := tracer.Start(ctx, "feed", trace.WithAttributes(attribute.String(TraceKeyPage, feed.URL)))
+		body, contentType, err := s.Fetcher.fetch(pageCtx, feed.URL, s.Config.ListFetchTimeout)
+		run.startPage(feed.URL, feed.URL, err)
+		if err != nil {
+			logger.Error("Error requesting feed", LogKeyStage, StageList, LogKeyError, err)
+			fetchErr = fmt.Errorf("error requesting feed %s: %v", feed.URL, err)
+			endSpan(pageSpan, err)
+			continue // One broken feed must not stop the others
+		}
+		fetched++
+		_, parseSpan := tracer.Start(pageCtx, "parse feed")
+		items, err := parseFeed(body, contentType, feed)
+		endSpan(parseSpan, err)
+		if err != nil {
+			logger.Error("Error parsing feed", LogKeyStage, StageParse, LogKeyError, err)
+			run.recordError(StageParse, feed.URL, err)
+			endSpan(pageSpan, err)
+			continue
+		}
+
+		run.countArticles(ArticleOutcomeDiscovered, len(items))
+		for _, newsArticle := range items {
+			if ctx.Err() != nil {
+				break
+			}
+			health.RecordItem(nil, false, false, false, false)
+			if s.articleAlreadyKnown(pageCtx, newsArticle.URL, health) {
+				run.countArticles(ArticleOutcomeSkipped, 1)
+				continue
+			}
+
+			newsArticle.ExtractionMethod = ExtractionSummary
+			if feed.FullText || feed.Body.Selector != "" {
+				var extract func(*goquery.Document) ([]ContentBlock, bool) // FullText: generic extractor alone
+				if feed.Body.Selector != "" {
+					extract = func(doc *goquery.Document) ([]ContentBlock, bool) { return extractBody(feed.Body, doc) }
+				}
+				if err := s.fetchArticleContent(pageCtx, &newsArticle, extract, health); err != nil {
+					if ctx.Err() != nil {
+						break
+					}
+					run.recordError(StageArticle, newsArticle.URL, err)
+				}
+				s.pauseBetweenArticles(ctx)
+			}
+			newsArticle.SourceID = def.ID
+
+			if err := s.saveCollectedArticle(pageCtx, &newsArticle); err != nil {
+				logger.Error("Article store save error", LogKeyStage, StageStore, LogKeyArticleURL, newsArticle.URL, LogKeyError, err)
+				run.recordError(StageStore, newsArticle.URL, err)
+				run.countArticles(ArticleOutcomeFailed, 1)
+				continue
+			}
+			run.countArticles(ArticleOutcomeNew, 1)
+			allNews = append(allNews, newsArticle)
+		}
+		logger.Info("Feed collection complete", LogKeyStage, StageList, "listed", len(items), "saved_so_far", len(allNews))
+		pageSpan.End()
+		s.saveRun(ctx, run) // Progress, shown by the admin dashboard
+	}
+	if fetched > 0 {
+		fetchErr = nil // Only a run where every feed failed is a failure
+	}
+	return allNews, s.finishCrawl(ctx, health, run, fetchErr)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFeedGolden(t *testing.T) {
+	cases := []struct {
+		golden      string
+		file        string
+		contentType string
+		publisher   string
+	}{
+		{"feed_mk_stock_euckr", "mk_stock_euckr.xml", "application/rss+xml", "매일경제"},
+		{"feed_yna_economy_atom", "yna_economy_atom.xml", "application/atom+xml; charset=utf-8", "연합뉴스"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.golden, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "feeds", tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			articles, err := parseFeed(body, tc.contentType, FeedConfig{URL: tc.file, Publisher: tc.publisher})
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			assertGolden(t, tc.golden, articles)
+		})
+	}
+}
+
+func TestParseFeedDate(t *testing.T) {
+	want := time.Date(2024, 5, 10, 6, 40, 0, 0, time.UTC)
+	for _, value := range []string{
+		"Fri, 10 May 2024 15:40:00 +0900",
+		"Fri, 10 May 2024 06:40:00 GMT",
+		"Fri, 10 May 2024 15:40:00 KST",
+		"2024-05-10T15:40:00+09:00",
+		"2024-05-10 15:40",
+		" 2024.05.10   15:40 ",
+	} {
+		if got := parseFeedDate(value); !got.Equal(want) {
+			t.Errorf("parseFeedDate(%q) = %v, want %v", value, got, want)
+		}
+	}
+	if got := parseFeedDate("어제 오후"); !got.IsZero() {
+		t.Errorf("expected the zero time for a malformed date, got %v", got)
+	}
+}
+
+func TestFullTextFeedStaysHealthy(t *testing.T) {
+	paragraph := strings.Repeat("정부는 반도체 산업의 경쟁력을 높이기 위한 지원 방안을 발표했다. ", 8)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed" {
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>test</title>`)
+			for i := 1; i <= 3; i++ {
+				fmt.Fprintf(w, `<item><title>기사 %[2]d</title><link>http://%[1]s/a/%[2]d</link><description>요약 %[2]d</description></item>`, r.Host, i)
+			}
+			fmt.Fprint(w, `</channel></rss>`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><body><nav><a href="/">홈</a></nav><div class="story"><p>%[1]s</p><p>%[1]s</p><p>%[1]s</p></div></body></html>`, paragraph)
+	}))
+	defer site.Close()
+
+	cfg := DefaultConfig()
+	cfg.ArticleStore = "memory"
+	cfg.ArticleDelay, cfg.ArticleRetryDelay = 0, 0
+	s := NewNewsCrawlerService(cfg)
+	s.Store = NewMemoryArticleStore()
+	s.Runs = NewMemoryRunStore()
+	def := &SourceDefinition{ID: "fulltext-test", Type: SourceTypeFeed, Feeds: []FeedConfig{
+		{URL: site.URL + "/feed", Publisher: "테스트", FullText: true},
+	}}
+
+	articles, err := s.CrawlSource(def, 1)
+	if err != nil {
+		t.Fatalf("CrawlSource: %v", err)
+	}
+	if len(articles) != 3 {
+		t.Fatalf("%d articles, want 3", len(articles))
+	}
+	for _, article := range articles {
+		if article.ExtractionMethod != ExtractionReadability {
+			t.Errorf("%s extracted with %q, want %q", article.URL, article.ExtractionMethod, ExtractionReadability)
+		}
+	}
+	if health := s.LastParserHealth(def.ID); health.Status != ParserStatusHealthy || health.FallbackRatio != 0 {
+		t.Errorf("parser health = %s, fallback ratio %v: %v", health.Status, health.FallbackRatio, health.Reasons)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// HTTPStatusError is returned by the Fetcher when the server answers with a non-200 status code.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status code %d for %s", e.StatusCode, e.URL)
+}
+
+// Fetcher performs the crawler's HTTP requests and decodes the responses into HTML documents.
+type Fetcher struct {
+	Transport        http.RoundTripper // nil means http.DefaultTransport
+	UserAgent        string
+	MaxResponseBytes int64 // Larger responses fail; 0 means no limit
+	Unobserved       bool  // Leaves the requests out of the fetch metrics (and the per-host stats)
+}
+
+// NewFetcher creates a new Fetcher sending the given User-Agent through the given transport.
+func NewFetcher(userAgent string, transport http.RoundTripper) *Fetcher {
+	return &Fetcher{
+		Transport: transport,
+		UserAgent: userAgent,
+	}
+}
+
+// FetchDocument requests pageURL and parses the response body as HTML,
+// converting it to UTF-8 according to the charset announced in the Content-Type header.
+func (f *Fetcher) FetchDocument(pageURL string, timeout time.Duration) (*goquery.Document, error) {
+	return f.FetchDocumentContext(context.Background(), pageURL, timeout)
+}
+
+// FetchDocumentContext is FetchDocument with a context, which carries the trace of the request.
+func (f *Fetcher) FetchDocumentContext(ctx context.Context, pageURL string, timeout time.Duration) (*goquery.Document, error) {
+	body, contentType, err := f.fetch(ctx, pageURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(decodeBody(body, contentType))
+	if err != nil {
+		return nil, fmt.Errorf("HTML parsing error for %s: %v", pageURL, err)
+	}
+	return doc, nil
+}
+
+// fetch performs a GET request and returns the raw response body and its Content-Type.
+// The request is a span of the trace of ctx; the trace context is not sent to the sites.
+func (f *Fetcher) fetch(ctx context.Context, pageURL string, timeout time.Duration) (body []byte, contentType string, err error) {
+	ctx, span := tracer.Start(ctx, "HTTP GET", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodGet, semconv.URLFull(pageURL)))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating request for %s: %v", pageURL, err)
+	}
+	span.SetAttributes(semconv.ServerAddress(req.URL.Hostname()))
+	req.Header.Set("User-Agent", f.UserAgent)
+
+	client := &http.Client{Transport: f.Transport, Timeout: timeout}
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		f.observe(pageURL, started, 0)
+		componentLogger(LogComponentFetcher).Debug("HTTP request failed", "url", pageURL, LogKeyDuration, time.Since(started), LogKeyError, err)
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	f.observe(pageURL, started, resp.StatusCode)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	componentLogger(LogComponentFetcher).Debug("HTTP request", "url", pageURL, "status", resp.StatusCode, LogKeyDuration, time.Since(started))
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", &HTTPStatusError{URL: pageURL, StatusCode: resp.StatusCode}
+	}
+
+	var reader io.Reader = resp.Body
+	if f.MaxResponseBytes > 0 {
+		reader = io.LimitReader(resp.Body, f.MaxResponseBytes+1)
+	}
+	body, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading response body for %s: %v", pageURL, err)
+	}
+	if f.MaxResponseBytes > 0 && int64(len(body)) > f.MaxResponseBytes {
+		return nil, "", fmt.Errorf("response for %s exceeds %d bytes", pageURL, f.MaxResponseBytes)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// observe records a request in the fetch metrics, unless the Fetcher is Unobserved.
+func (f *Fetcher) observe(pageURL string, started time.Time, statusCode int) {
+	if !f.Unobserved {
+		observeFetch(pageURL, started, statusCode)
+	}
+}
+
+// isRetryableFetchError reports whether a fetch error was caused by the transport
+// (network failure, timeout) rather than by the response itself.
+func isRetryableFetchError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// charsetFromContentType extracts the lower-cased charset parameter of a Content-Type header.
+// It defaults to utf-8 when no charset is announced.
+func charsetFromContentType(contentType string) string {
+	charset := "utf-8"
+	if strings.Contains(contentType, "charset=") {
+		parts := strings.Split(contentType, "charset=")
+		if len(parts) > 1 {
+			charset = strings.ToLower(strings.Trim(strings.TrimSpace(parts[1]), `"`))
+		}
+	}
+	return charset
+}
+
+// decodeBody wraps body in a reader converting it from the announced charset to UTF-8.
+// Unknown charsets are processed as UTF-8.
+func decodeBody(body []byte, contentType string) io.Reader {
+	charset := charsetFromContentType(contentType)
+	if charset == "utf-8" || charset == "" {
+		return bytes.NewReader(body)
+	}
+	e, err := htmlindex.Get(charset)
+	if err != nil || e == nil {
+		componentLogger(LogComponentFetcher).Warn("Unknown response charset. Processing as UTF-8.", "charset", charset, LogKeyError, err)
+		return bytes.NewReader(body)
+	}
+	return transform.NewReader(bytes.NewReader(body), e.NewDecoder())
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Figure kinds recorded on ArticleFigure.Kind.
+const (
+	FigureAmount = "amount" // Monetary amount (Unit is a currency code)
+	FigureRatio  = "ratio"  // Percentage, percentage points or basis points
+	FigureIndex  = "index"  // Index points (e.g. KOSPI 2700포인트)
+	FigureNumber = "number" // Scaled number without a unit (e.g. 500만, table cells)
+)
+
+// ArticleFigure is a numeric expression of an article normalized to a typed value.
+type ArticleFigure struct {
+	Text          string   `firestore:"text" json:"text"`                                       // Expression as written (e.g. "1조2000억원")
+	Value         float64  `firestore:"value" json:"value"`                                     // Value in base units (won, dollars, percent)
+	Unit          string   `firestore:"unit,omitempty" json:"unit,omitempty"`                   // KRW, USD, JPY, CNY, EUR, %, %p, bp, pt
+	Kind          string   `firestore:"kind" json:"kind"`                                       // FigureAmount, FigureRatio, FigureIndex or FigureNumber
+	Label         string   `firestore:"label,omitempty" json:"label,omitempty"`                 // Metric the figure refers to (e.g. 영업이익)
+	Direction     string   `firestore:"direction,omitempty" json:"direction,omitempty"`         // "up" or "down" when stated (▲/△, 증가/감소...)
+	ChangePercent *float64 `firestore:"changePercent,omitempty" json:"changePercent,omitempty"` // Change stated next to an amount, e.g. (+35%)
+	Context       string   `firestore:"context" json:"context"`                                 // Sentence (or table row) containing the figure
+	Position      int      `firestore:"position" json:"position"`                               // Index of the block in ContentBlocks
+}
+
+const figureNumber = `\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?`
+
+var (
+	// A number with optional Korean magnitude words (조/억/만/천) and a unit.
+	figurePattern = regexp.MustCompile(
+		`([+\-−△▲▼↑↓]\s?)?` +
+			`((?:` + figureNumber + `)(?:\s?(?:조|억|만|천)(?:\s?(?:` + figureNumber + `))?)*)` +
+			`\s?(%p|%포인트|%|％|퍼센트|bp|포인트|원|달러|엔|위안|유로)?`)
+	figureTokenPattern = regexp.MustCompile(`(` + figureNumber + `)?\s?(조|억|만|천)?`)
+
+	// "(단위: 억원)" in table captions and notes.
+	tableUnitPattern = regexp.MustCompile(`단위\s*[:：]?\s*(조|억|만|천)?\s*(원|달러|엔|위안|유로|%)`)
+
+	figureUpWords   = []string{"증가", "상승", "늘", "올랐", "오른", "급등", "개선", "확대", "성장", "반등"}
+	figureDownWords = []string{"감소", "하락", "줄", "내렸", "내린", "떨어", "급락", "악화", "축소", "둔화"}
+
+	// Words skipped when looking back for the label of a figure.
+	figureLabelSkipWords = map[string]bool{
+		"약": true, "총": true, "각각": true, "대비": true, "전년": true, "동기": true, "전년동기": true,
+		"전분기": true, "전월": true, "전일": true, "전일대비": true, "무려": true, "모두": true, "한때": true, "최대": true, "최소": true,
+	}
+	figureParticles = []string{"으로", "에서", "이며", "은", "는", "이", "가", "을", "를", "도", "의", "로", "에"}
+)
+
+var figureMagnitudes = map[string]float64{"조": 1e12, "억": 1e8, "만": 1e4, "천": 1e3}
+
+var figureUnits = map[string]struct{ unit, kind string }{
+	"원": {"KRW", FigureAmount}, "달러": {"USD", FigureAmount}, "엔": {"JPY", FigureAmount},
+	"위안": {"CNY", FigureAmount}, "유로": {"EUR", FigureAmount},
+	"%": {"%", FigureRatio}, "％": {"%", FigureRatio}, "퍼센트": {"%", FigureRatio},
+	"%p": {"%p", FigureRatio}, "%포인트": {"%p", FigureRatio}, "bp": {"bp", FigureRatio},
+	"포인트": {"pt", FigureIndex},
+}
+
+// extractFigures finds the numeric figures of the text blocks and table cells of an article.
+// Bare numbers in text (years, dates, counts) are ignored; only figures with a magnitude
+// word or a unit are kept.
+func extractFigures(blocks []ContentBlock) []ArticleFigure {
+	var figures []ArticleFigure
+	for i, block := range blocks {
+		switch block.Type {
+		case BlockTable:
+			unit := tableUnit(block.Text)
+			if unit == "" && i > 0 {
+				unit = tableUnit(blocks[i-1].Text) // Units are often given in the line above the table
+			}
+			figures = append(figures, tableFigures(block, unit, i)...)
+		case BlockImage:
+		default:
+			figures = append(figures, textFigures(block.Text, i)...)
+		}
+	}
+	return figures
+}
+
+// textFigures extracts the figures of a text block.
+func textFigures(text string, position int) []ArticleFigure {
+	var figures []ArticleFigure
+	prevEnd := -1
+	for _, m := range figurePattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		sign := ""
+		if m[2] >= 0 {
+			sign = strings.TrimSpace(text[m[2]:m[3]])
+			// A minus glued to a word is a hyphen ("KOSPI-200"), not a sign.
+			if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				sign, start = "", m[4]
+			}
+		}
+		if start > 0 {
+			// Ignore digits inside a longer token (e.g. ticker A005930).
+			if r, _ := utf8.DecodeLastRuneInString(text[:start]); unicode.IsDigit(r) || r == '.' || (r < utf8.RuneSelf && unicode.IsLetter(r)) {
+				continue
+			}
+		}
+		number := text[m[4]:m[5]]
+		unitWord := ""
+		if m[6] >= 0 {
+			unitWord = text[m[6]:m[7]]
+		}
+		figure, ok := newFigure(strings.TrimSpace(text[start:end]), sign, number, unitWord, false)
+		if !ok {
+			continue
+		}
+		figure.Position = position
+		figure.Context = figureSentence(text, start, end)
+		figure.Label = figureLabel(text[:start])
+		if figure.Direction == "" {
+			figure.Direction = figureDirection(text[end:])
+		}
+
+		// "영업이익 1조2000억원(+35%)": the ratio in parentheses is the change of the amount.
+		if n := len(figures); n > 0 && figure.Unit == "%" && figures[n-1].Kind == FigureAmount &&
+			figures[n-1].ChangePercent == nil && strings.TrimSpace(text[prevEnd:start]) == "(" {
+			change := figure.Value
+			figures[n-1].ChangePercent = &change
+			if figures[n-1].Direction == "" {
+				figures[n-1].Direction = figure.Direction
+			}
+			prevEnd = end
+			continue
+		}
+		figures = append(figures, figure)
+		prevEnd = end
+	}
+	return figures
+}
+
+// tableFigures extracts the figures of the data cells of a table. Cells are labeled with their
+// row and column headers; bare numbers take the table's unit (see tableUnit).
+func tableFigures(block ContentBlock, unit string, position int) []ArticleFigure {
+	var header []string
+	if block.HeaderRows > 0 {
+		header = block.Rows[block.HeaderRows-1].Cells
+	}
+	var figures []ArticleFigure
+	for _, row := range block.Rows[block.HeaderRows:] {
+		for j, cell := range row.Cells {
+			if j == 0 {
+				continue // Row label
+			}
+			m := figurePattern.FindStringSubmatchIndex(cell)
+			if m == nil || m[0] != 0 || m[1] != len(cell) {
+				continue
+			}
+			sign := ""
+			if m[2] >= 0 {
+				sign = strings.TrimSpace(cell[m[2]:m[3]])
+			}
+			unitWord := ""
+			if m[6] >= 0 {
+				unitWord = cell[m[6]:m[7]]
+			}
+			number := cell[m[4]:m[5]]
+			if unitWord == "" && unit != "" {
+				number, unitWord = applyTableUnit(number, unit)
+			}
+			figure, ok := newFigure(cell, sign, number, unitWord, true)
+			if !ok {
+				continue
+			}
+			label := row.Cells[0]
+			if j < len(header) && header[j] != "" && header[j] != label {
+				label += " " + header[j]
+			}
+			figure.Label = label
+			figure.Context = strings.Join(row.Cells, " | ")
+			figure.Position = position
+			figures = append(figures, figure)
+		}
+	}
+	return figures
+}
+
+// newFigure builds a figure from the parts of a figurePattern match. Unless bare is set,
+// numbers without magnitude word and unit are rejected.
+func newFigure(text, sign, number, unitWord string, bare bool) (ArticleFigure, bool) {
+	value, scaled, ok := parseKoreanNumber(number)
+	if !ok || (!scaled && unitWord == "" && !bare) {
+		return ArticleFigure{}, false
+	}
+	figure := ArticleFigure{Text: text, Kind: FigureNumber}
+	if unit, ok := figureUnits[unitWord]; ok {
+		figure.Unit, figure.Kind = unit.unit, unit.kind
+	}
+	switch sign {
+	case "-", "−", "△", "▼", "↓":
+		value = -value
+		figure.Direction = "down"
+	case "+", "▲", "↑":
+		figure.Direction = "up"
+	}
+	figure.Value = value
+	return figure, true
+}
+
+// parseKoreanNumber parses numbers written with Korean magnitude words, e.g. "1조2000억",
+// "3.5조", "1천억", "1,360". scaled reports whether a magnitude word was used.
+func parseKoreanNumber(s string) (value float64, scaled bool, ok bool) {
+	var total, section float64 // section accumulates the part below the next 만/억/조
+	for _, m := range figureTokenPattern.FindAllStringSubmatch(s, -1) {
+		numText, magnitude := m[1], m[2]
+		if numText == "" && magnitude == "" {
+			continue
+		}
+		n := 1.0
+		if numText != "" {
+			parsed, err := strconv.ParseFloat(strings.ReplaceAll(numText, ",", ""), 64)
+			if err != nil {
+				return 0, false, false
+			}
+			n = parsed
+			ok = true
+		}
+		switch magnitude {
+		case "":
+			section += n
+		case "천":
+			section += n * 1e3
+			scaled = true
+		default:
+			if numText != "" || section == 0 {
+				section += n
+			}
+			total += section * figureMagnitudes[magnitude]
+			section = 0
+			scaled = true
+		}
+	}
+	return total + section, scaled, ok
+}
+
+// tableUnit returns the unit declared in a table caption or note ("단위: 억원"), e.g. "억원".
+func tableUnit(text string) string {
+	if m := tableUnitPattern.FindStringSubmatch(text); m != nil {
+		return m[1] + m[2]
+	}
+	return ""
+}
+
+// applyTableUnit appends a table unit such as "억원" to a bare number.
+func applyTableUnit(number, unit string) (string, string) {
+	for magnitude := range figureMagnitudes {
+		if strings.HasPrefix(unit, magnitude) {
+			return number + magnitude, strings.TrimPrefix(unit, magnitude)
+		}
+	}
+	return number, unit
+}
+
+// figureSentence returns the sentence of text containing text[start:end].
+func figureSentence(text string, start, end int) string {
+	from := 0
+	for _, sep := range []string{". ", "? ", "! "} {
+		if i := strings.LastIndex(text[:start], sep); i >= 0 && i+len(sep) > from {
+			from = i + len(sep)
+		}
+	}
+	to := len(text)
+	for _, sep := range []string{". ", "? ", "! "} {
+		if i := strings.Index(text[end:], sep); i >= 0 && end+i+1 < to {
+			to = end + i + 1
+		}
+	}
+	return strings.TrimSpace(text[from:to])
+}
+
+// figureLabel guesses the metric a figure refers to from the words before it,
+// e.g. "영업이익은 전년 대비" -> "영업이익".
+func figureLabel(before string) string {
+	words := strings.FieldsFunc(before, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(",()[]·\"'“”‘’:", r)
+	})
+	for i := len(words) - 1; i >= 0 && i >= len(words)-4; i-- {
+		word := words[i]
+		if figureLabelSkipWords[word] || isModifierWord(word) {
+			continue
+		}
+		if !isLabelWord(word) {
+			return ""
+		}
+		for _, particle := range figureParticles {
+			if trimmed := strings.TrimSuffix(word, particle); trimmed != word && utf8.RuneCountInString(trimmed) >= 2 {
+				word = trimmed
+				break
+			}
+		}
+		if figureLabelSkipWords[word] {
+			continue
+		}
+		return word
+	}
+	return ""
+}
+
+// isLabelWord reports whether word is made of letters only (no digits or symbols).
+func isLabelWord(word string) bool {
+	for _, r := range word {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return word != ""
+}
+
+// isModifierWord reports whether word is an adnominal verb form ("증가한", "집계된"),
+// which describes the figure rather than naming it.
+func isModifierWord(word string) bool {
+	for _, suffix := range []string{"한", "된", "던", "난", "린"} {
+		if strings.HasSuffix(word, suffix) && utf8.RuneCountInString(word) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// figureDirection reads a change direction stated right after a figure ("35% 증가").
+func figureDirection(after string) string {
+	after = strings.TrimSpace(after)
+	for _, word := range figureUpWords {
+		if strings.HasPrefix(after, word) {
+			return "up"
+		}
+	}
+	for _, word := range figureDownWords {
+		if strings.HasPrefix(after, word) {
+			return "down"
+		}
+	}
+	return ""
+}
+
+// tickerPatterns find KRX stock codes: Naver Finance item links (code=005930),
+// codes in parentheses after a company name ("삼성전자(005930)") and A-prefixed codes (A005930).
+var tickerPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[?&]code=(\d{6})\b`),
+	regexp.MustCompile(`\((\d{6})\)`),
+	regexp.MustCompile(`\bA(\d{6})\b`),
+}
+
+// extractTickers returns the distinct stock codes mentioned in text, in order of appearance.
+func extractTickers(text string) []string {
+	type hit struct {
+		pos  int
+		code string
+	}
+	var hits []hit
+	for _, pattern := range tickerPatterns {
+		for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			hits = append(hits, hit{m[2], text[m[2]:m[3]]})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].pos < hits[j].pos })
+	var tickers []string
+	seen := map[string]bool{}
+	for _, h := range hits {
+		if !seen[h.code] {
+			seen[h.code] = true
+			tickers = append(tickers, h.code)
+		}
+	}
+	return tickers
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseKoreanNumber(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   float64
+		scaled bool
+	}{
+		{"1조2000억", 1.2e12, true},
+		{"6조6000억", 6.6e12, true},
+		{"3.5조", 3.5e12, true},
+		{"1천억", 1e11, true},
+		{"2조 3,500억", 2.35e12, true},
+		{"500만", 5e6, true},
+		{"1,360", 1360, false},
+		{"71.9", 71.9, false},
+	}
+	for _, tt := range tests {
+		got, scaled, ok := parseKoreanNumber(tt.in)
+		if !ok || math.Abs(got-tt.want) > 1e-3 || scaled != tt.scaled {
+			t.Errorf("parseKoreanNumber(%q) = %v, %v, %v; want %v, %v", tt.in, got, scaled, ok, tt.want, tt.scaled)
+		}
+	}
+}
+
+func TestTextFigures(t *testing.T) {
+	text := "삼성전자의 1분기 영업이익 1조2000억원(+35%)을 기록했다. 매출은 전년 대비 12.5% 증가한 71조9000억원이다. " +
+		"2024년 5월 10일 환율은 1,360원으로 마감했고 코스피는 2700포인트를 회복했다. 종목코드 A005930의 주가는 △2.1% 하락했다."
+	figures := textFigures(text, 3)
+
+	type want struct {
+		text, unit, kind, label, direction string
+		value                              float64
+		change                             float64
+	}
+	wants := []want{
+		{"1조2000억원", "KRW", FigureAmount, "영업이익", "up", 1.2e12, 35},
+		{"12.5%", "%", FigureRatio, "매출", "up", 12.5, math.NaN()},
+		{"71조9000억원", "KRW", FigureAmount, "", "", 7.19e13, math.NaN()},
+		{"1,360원", "KRW", FigureAmount, "환율", "", 1360, math.NaN()},
+		{"2700포인트", "pt", FigureIndex, "코스피", "", 2700, math.NaN()},
+		{"△2.1%", "%", FigureRatio, "주가", "down", -2.1, math.NaN()},
+	}
+	if len(figures) != len(wants) {
+		for _, f := range figures {
+			t.Logf("%+v", f)
+		}
+		t.Fatalf("got %d figures, want %d", len(figures), len(wants))
+	}
+	for i, w := range wants {
+		f := figures[i]
+		if f.Text != w.text || f.Unit != w.unit || f.Kind != w.kind || f.Label != w.label || f.Direction != w.direction ||
+			math.Abs(f.Value-w.value) > 1e-3 || f.Position != 3 {
+			t.Errorf("figure %d = %+v, want %+v", i, f, w)
+		}
+		if math.IsNaN(w.change) != (f.ChangePercent == nil) || (f.ChangePercent != nil && *f.ChangePercent != w.change) {
+			t.Errorf("figure %d change = %v, want %v", i, f.ChangePercent, w.change)
+		}
+	}
+	if want := "삼성전자의 1분기 영업이익 1조2000억원(+35%)을 기록했다."; figures[0].Context != want {
+		t.Errorf("context = %q, want %q", figures[0].Context, want)
+	}
+}
+
+func TestTableFigures(t *testing.T) {
+	const body = `<div id="body">
+<p>삼성전자 실적 추이 (단위: 억원)</p>
+<table>
+<thead><tr><th rowspan="2">구분</th><th colspan="2">2024년</th></tr><tr><th>1Q</th><th>2Q</th></tr></thead>
+<tbody>
+<tr><td>매출액</td><td>719,156</td><td>740,683</td></tr>
+<tr><td>영업이익</td><td>66,060</td><td>△1,200</td></tr>
+<tr><td>영업이익률</td><td>9.2%</td><td>-</td></tr>
+</tbody>
+</table>
+</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := extractBlocks(doc.Find("#body"))
+	if len(blocks) != 2 || blocks[1].Type != BlockTable {
+		t.Fatalf("blocks = %+v", blocks)
+	}
+	table := blocks[1]
+	if table.HeaderRows != 2 {
+		t.Errorf("HeaderRows = %d, want 2", table.HeaderRows)
+	}
+	if got := strings.Join(table.Rows[1].Cells, ","); got != "구분,1Q,2Q" {
+		t.Errorf("rowspan not expanded: second header row = %q", got)
+	}
+	if got := strings.Join(table.Rows[0].Cells, ","); got != "구분,2024년,2024년" {
+		t.Errorf("colspan not expanded: first header row = %q", got)
+	}
+
+	figures := extractFigures(blocks)
+	want := map[string]float64{
+		"매출액 1Q":   719156e8,
+		"매출액 2Q":   740683e8,
+		"영업이익 1Q":  66060e8,
+		"영업이익 2Q":  -1200e8,
+		"영업이익률 1Q": 9.2,
+	}
+	if len(figures) != len(want) {
+		t.Fatalf("got %d figures, want %d: %+v", len(figures), len(want), figures)
+	}
+	for _, f := range figures {
+		value, ok := want[f.Label]
+		if !ok || math.Abs(f.Value-value) > 1 {
+			t.Errorf("figure %+v: want value %v for label %q", f, value, f.Label)
+		}
+		if f.Position != 1 {
+			t.Errorf("figure %q position = %d, want 1", f.Label, f.Position)
+		}
+	}
+}
@@ -4,15 +4,27 @@ go 1.21
 
 require (
 	firebase.google.com/go/v4 v4.14.0 // Firebase Admin SDK (Firestore)
+	github.com/MicahParks/keyfunc v1.9.0 // OIDC ID 토큰 검증 키 (JWKS)
 	github.com/PuerkitoBio/goquery v1.8.1 // HTML 파싱
 	github.com/gofiber/fiber/v2 v2.52.4 // 웹 프레임워크 (Fiber 사용)
+	github.com/golang-jwt/jwt/v4 v4.5.0 // OIDC ID 토큰 (JWT) 검증
+	github.com/prometheus/client_golang v1.19.0 // 메트릭 (/metrics)
+	github.com/robfig/cron/v3 v3.0.1 // 크롤링 스케줄러 (cron 표현식)
+	github.com/xitongsys/parquet-go v1.6.2 // Parquet 내보내기 호환성 테스트 (독립 리더)
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0 // 위 테스트의 메모리 파일 소스
+	go.opentelemetry.io/otel v1.24.0 // 분산 트레이싱 (OpenTelemetry)
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.24.0 // 트레이스 내보내기 (OTLP/HTTP)
+	go.opentelemetry.io/otel/sdk v1.24.0 // 트레이스 수집 SDK
+	go.opentelemetry.io/otel/trace v1.24.0 // 트레이스 API (span)
 	google.golang.org/api v0.170.0 // Google Cloud API (Firebase SDK 내부 사용)
+	golang.org/x/image v0.18.0 // 썸네일 생성 (이미지 축소, WebP 디코딩)
+	gopkg.in/yaml.v3 v3.0.1 // 소스 정의 파일 (YAML)
 )
 
 require (
 	cloud.google.com/go/compute v1.24.0 // indirect
 	cloud.google.com/go/compute/metadata v0.2.3 // indirect
-	cloud.google.com/go/firestore v1.15.0 // indirect
+	cloud.google.com/go/firestore v1.15.0
 	cloud.google.com/go/longrunning v0.5.5 // indirect
 	github.com/andybalholm/brotli v1.0.5 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
@@ -31,11 +43,11 @@ require (
 	github.com/valyala/tcplisten v1.0.0 // indirect
 	go.opencensus.io v0.24.0 // indirect
 	golang.org/x/crypto v0.21.0 // indirect
-	golang.org/x/net v0.23.0 // indirect
+	golang.org/x/net v0.23.0
 	golang.org/x/oauth2 v0.18.0 // indirect
-	golang.org/x/sync v0.6.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/text v0.16.0
 	golang.org/x/time v0.5.0 // indirect
 	// golang.org/x/xerrors v0.0.0-20231012003039-44458f17e7f2 // indirect
 	// google.golang.org/genproto v0.0.0-20240311132316-a218d6a849ce // indirect
@@ -49,17 +61,27 @@ require (
 	cloud.google.com/go v0.112.1 // indirect
 	cloud.google.com/go/iam v1.1.7 // indirect
 	cloud.google.com/go/storage v1.40.0 // indirect
-	github.com/MicahParks/keyfunc v1.9.0 // indirect
 	github.com/andybalholm/cascadia v1.3.1 // indirect
+	github.com/apache/arrow/go/arrow v0.0.0-20200730104253-651201b0f516 // indirect
+	github.com/apache/thrift v0.14.2 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cenkalti/backoff/v4 v4.2.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/felixge/httpsnoop v1.0.4 // indirect
 	github.com/go-logr/logr v1.4.1 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
-	github.com/golang-jwt/jwt/v4 v4.5.0 // indirect
+	github.com/golang/snappy v0.0.4 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.19.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.8 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
 	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.49.0 // indirect
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.49.0 // indirect
-	go.opentelemetry.io/otel v1.24.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.24.0 // indirect
 	go.opentelemetry.io/otel/metric v1.24.0 // indirect
-	go.opentelemetry.io/otel/trace v1.24.0 // indirect
+	go.opentelemetry.io/proto/otlp v1.1.0 // indirect
+	golang.org/x/xerrors v0.0.0-20231012003039-104605ab7028 // indirect
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/appengine/v2 v2.0.2 // indirect
 	google.golang.org/genproto v0.0.0-20240213162025-012b6fc9bca9 // indirect
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Parser health statuses reported by ParserHealth.
+const (
+	ParserStatusHealthy  = "healthy"
+	ParserStatusDegraded = "degraded"
+)
+
+// ParserHealthThresholds define when a crawl run is considered degraded.
+type ParserHealthThresholds struct {
+	MinItems             int     `yaml:"minItems"`             // Ratios are only evaluated once this many list items were seen
+	MaxMissingFieldRatio float64 `yaml:"maxMissingFieldRatio"` // Maximum share of list items missing a title, summary, source or link
+	MaxBodyFallbackRatio float64 `yaml:"maxBodyFallbackRatio"` // Maximum share of articles whose site-specific body selector found nothing
+	MinMedianBodyLength  int     `yaml:"minMedianBodyLength"`  // Minimum median length (in runes) of extracted bodies
+}
+
+// BodyLengthStats summarizes the distribution of extracted body lengths (in runes).
+type BodyLengthStats struct {
+	Count  int `json:"count"`
+	Min    int `json:"min"`
+	Median int `json:"median"`
+	P90    int `json:"p90"`
+	Max    int `json:"max"`
+}
+
+// ParserHealth collects per-run parser metrics used to detect selector drift.
+type ParserHealth struct {
+	mu          sync.Mutex
+	thresholds  ParserHealthThresholds
+	sampleDir   string
+	bodyLengths []int
+	log         *slog.Logger // Logger of the crawl run
+
+	Source            string          `json:"source,omitempty"` // ID of the crawled source
+	RunID             string          `json:"runId,omitempty"`  // See CrawlRun
+	Status            string          `json:"status"`
+	Reasons           []string        `json:"reasons,omitempty"`
+	StartedAt         time.Time       `json:"startedAt"`
+	FinishedAt        time.Time       `json:"finishedAt,omitempty"`
+	PagesWithoutList  int             `json:"pagesWithoutList"`
+	ItemsSeen         int             `json:"itemsSeen"`
+	InvalidItems      int             `json:"invalidItems"` // Items missing at least one required field
+	MissingTitle      int             `json:"missingTitle"`
+	MissingSummary    int             `json:"missingSummary"`
+	MissingSource     int             `json:"missingSource"`
+	MissingLink       int             `json:"missingLink"`
+	BodiesParsed      int             `json:"bodiesParsed"`
+	BodyFallbacks     int             `json:"bodyFallbacks"`
+	FetchFailures     int             `json:"fetchFailures"`
+	ExtractionMethods map[string]int  `json:"extractionMethods,omitempty"` // Bodies per extraction strategy
+	MissingItemRatio  float64         `json:"missingItemRatio"`
+	FallbackRatio     float64         `json:"fallbackRatio"`
+	BodyLength        BodyLengthStats `json:"bodyLength"`
+	SamplePath        string          `json:"samplePath,omitempty"` // First failing HTML sample of the run
+}
+
+// NewParserHealth starts collecting parser health for a new run.
+// Failing HTML samples are written to sampleDir (disabled when empty).
+func NewParserHealth(thresholds ParserHealthThresholds, sampleDir string) *ParserHealth {
+	return &ParserHealth{
+		thresholds: thresholds,
+		sampleDir:  sampleDir,
+		Status:     ParserStatusHealthy,
+		StartedAt:  time.Now(),
+	}
+}
+
+// logger returns the logger of the crawl run.
+func (h *ParserHealth) logger() *slog.Logger {
+	if h == nil || h.log == nil {
+		return componentLogger(LogComponentCrawler)
+	}
+	return h.log
+}
+
+// RecordMissingList records a list page on which the item selector matched nothing.
+func (h *ParserHealth) RecordMissingList(pageURL string, doc *goquery.Document) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.PagesWithoutList++
+	h.saveSample("list", pageURL, doc.Selection)
+}
+
+// RecordItem records a parsed list item and which of its required fields were missing.
+func (h *ParserHealth) RecordItem(item *goquery.Selection, missingTitle, missingSummary, missingSource, missingLink bool) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ItemsSeen++
+	if missingTitle {
+		h.MissingTitle++
+	}
+	if missingSummary {
+		h.MissingSummary++
+	}
+	if missingSource {
+		h.MissingSource++
+	}
+	if missingLink {
+		h.MissingLink++
+	}
+	if missingTitle || missingSummary || missingSource || missingLink {
+		h.InvalidItems++
+		h.saveSample("item", "", item)
+	}
+}
+
+// RecordBody records the length of an article body extracted with the given strategy.
+// Only bodies found by the site-specific selector count towards BodiesParsed.
+func (h *ParserHealth) RecordBody(content, method string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if method == ExtractionSelector {
+		h.BodiesParsed++
+	}
+	if h.ExtractionMethods == nil {
+		h.ExtractionMethods = map[string]int{}
+	}
+	h.ExtractionMethods[method]++
+	h.bodyLengths = append(h.bodyLengths, len([]rune(content)))
+}
+
+// RecordBodyFallback records an article page on which the site-specific body selector
+// matched nothing, so that the generic extractor or the list summary had to be used.
+func (h *ParserHealth) RecordBodyFallback(articleURL string, doc *goquery.Document) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.BodyFallbacks++
+	h.saveSample("article", articleURL, doc.Selection)
+}
+
+// RecordFetchFailure records an article that could not be fetched at all.
+// Fetch failures are reported but do not count as parser fallbacks.
+func (h *ParserHealth) RecordFetchFailure() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.FetchFailures++
+}
+
+// Finish computes the run's ratios and distribution and evaluates the thresholds.
+// It returns true when the run is degraded.
+func (h *ParserHealth) Finish() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.FinishedAt = time.Now()
+	h.Reasons = nil
+
+	if h.ItemsSeen > 0 {
+		h.MissingItemRatio = float64(h.InvalidItems) / float64(h.ItemsSeen)
+	}
+	if attempts := h.BodiesParsed + h.BodyFallbacks; attempts > 0 {
+		h.FallbackRatio = float64(h.BodyFallbacks) / float64(attempts)
+	}
+	h.BodyLength = bodyLengthStats(h.bodyLengths)
+
+	if h.PagesWithoutList > 0 {
+		h.Reasons = append(h.Reasons, fmt.Sprintf("news list selector matched nothing on %d page(s)", h.PagesWithoutList))
+	}
+	if h.ItemsSeen >= h.thresholds.MinItems {
+		if h.MissingItemRatio > h.thresholds.MaxMissingFieldRatio {
+			h.Reasons = append(h.Reasons, fmt.Sprintf("%.0f%% of list items are missing required fields (max %.0f%%)",
+				h.MissingItemRatio*100, h.thresholds.MaxMissingFieldRatio*100))
+		}
+		if h.FallbackRatio > h.thresholds.MaxBodyFallbackRatio {
+			h.Reasons = append(h.Reasons, fmt.Sprintf("body selector found nothing on %.0f%% of articles (max %.0f%%)",
+				h.FallbackRatio*100, h.thresholds.MaxBodyFallbackRatio*100))
+		}
+		if h.BodyLength.Count > 0 && h.BodyLength.Median < h.thresholds.MinMedianBodyLength {
+			h.Reasons = append(h.Reasons, fmt.Sprintf("median body length is %d characters (min %d)",
+				h.BodyLength.Median, h.thresholds.MinMedianBodyLength))
+		}
+	}
+
+	if len(h.Reasons) > 0 {
+		h.Status = ParserStatusDegraded
+	} else {
+		h.Status = ParserStatusHealthy
+	}
+	return h.Status == ParserStatusDegraded
+}
+
+// saveSample persists the first failing HTML sample of the run. The caller holds h.mu.
+func (h *ParserHealth) saveSample(kind, pageURL string, sel *goquery.Selection) {
+	if h.SamplePath != "" || h.sampleDir == "" || sel == nil {
+		return
+	}
+	html, err := goquery.OuterHtml(sel)
+	if err != nil {
+		h.logger().Warn("Failed to render parser failure sample", LogKeyStage, StageParse, LogKeyError, err)
+		return
+	}
+	if err := os.MkdirAll(h.sampleDir, 0o755); err != nil {
+		h.logger().Warn("Failed to create parser sample directory", LogKeyStage, StageParse, "dir", h.sampleDir, LogKeyError, err)
+		return
+	}
+	// Runs of several sources may start in the same second
+	name := []string{h.StartedAt.Format("20060102T150405")}
+	for _, part := range []string{h.Source, h.RunID, kind} {
+		if part != "" {
+			name = append(name, strings.Map(sampleNameRune, part))
+		}
+	}
+	path := filepath.Join(h.sampleDir, strings.Join(name, "-")+".html")
+	if pageURL != "" {
+		html = fmt.Sprintf("<!-- %s -->\n%s", strings.ReplaceAll(pageURL, "--", "%2D%2D"), html)
+	}
+	if err := os.WriteFile(path, []byte(html), 0o644); err != nil {
+		h.logger().Warn("Failed to write parser failure sample", LogKeyStage, StageParse, "path", path, LogKeyError, err)
+		return
+	}
+	h.SamplePath = path
+	h.logger().Info("Parser failure sample saved", LogKeyStage, StageParse, "path", path, LogKeyArticleURL, pageURL)
+}
+
+// sampleNameRune keeps the letters, digits, '-' and '_' of a sample file name.
+func sampleNameRune(r rune) rune {
+	if r == '-' || r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+		return r
+	}
+	return '_'
+}
+
+// bodyLengthStats computes the distribution of the given lengths.
+func bodyLengthStats(lengths []int) BodyLengthStats {
+	if len(lengths) == 0 {
+		return BodyLengthStats{}
+	}
+	sorted := append([]int(nil), lengths...)
+	sort.Ints(sorted)
+	return BodyLengthStats{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Median: sorted[len(sorted)/2],
+		P90:    sorted[(len(sorted)*9)/10],
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// ParserDegradedError is returned by a crawl whose parser health crossed a threshold.
+type ParserDegradedError struct {
+	Health *ParserHealth
+}
+
+func (e *ParserDegradedError) Error() string {
+	return fmt.Sprintf("parser health degraded: %s", strings.Join(e.Health.Reasons, "; "))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParserHealthDetectsMissingFields(t *testing.T) {
+	doc, err := newReplayFetcher(t).FetchDocument("https://finance.naver.com/news/mainnews.naver?page=1", time.Second)
+	if err != nil {
+		t.Fatalf("FetchDocument: %v", err)
+	}
+
+	sampleDir := t.TempDir()
+	health := NewParserHealth(ParserHealthThresholds{
+		MinItems:             1,
+		MaxMissingFieldRatio: 0.2,
+		MaxBodyFallbackRatio: 0.3,
+		MinMedianBodyLength:  10,
+	}, sampleDir)
+	health.Source, health.RunID = NaverMainNewsSourceID, "20240510T000000Z-0a1b2c3d"
+	parseNaverNewsList(doc, "https://n.news.naver.com/mnews/article", health)
+
+	if !health.Finish() {
+		t.Fatalf("expected a degraded run, got reasons %v", health.Reasons)
+	}
+	if health.ItemsSeen != 5 || health.InvalidItems != 2 || health.MissingSource != 1 || health.MissingSummary != 1 {
+		t.Errorf("unexpected counts: seen=%d invalid=%d missingSource=%d missingSummary=%d",
+			health.ItemsSeen, health.InvalidItems, health.MissingSource, health.MissingSummary)
+	}
+	if health.SamplePath == "" {
+		t.Fatal("expected the first failing item to be saved as a sample")
+	}
+	if _, err := os.Stat(health.SamplePath); err != nil {
+		t.Errorf("sample file: %v", err)
+	}
+
+	// A run of another source starting in the same second keeps its own sample.
+	other := NewParserHealth(health.thresholds, sampleDir)
+	other.Source, other.RunID, other.StartedAt = "other/source", "20240510T000000Z-4e5f6a7b", health.StartedAt
+	parseNaverNewsList(doc, "https://n.news.naver.com/mnews/article", other)
+	want := health.StartedAt.Format("20060102T150405") + "-other_source-20240510T000000Z-4e5f6a7b-item.html"
+	if filepath.Base(other.SamplePath) != want || other.SamplePath == health.SamplePath {
+		t.Errorf("samples %s and %s, want %s", health.SamplePath, other.SamplePath, want)
+	}
+}
+
+func TestParserHealthBySource(t *testing.T) {
+	s := NewNewsCrawlerService(DefaultConfig())
+	ctx := context.Background()
+	for _, source := range []string{"naver-mainnews", "korean-business-feeds"} {
+		health := s.newParserHealth(source)
+		run := s.startRun(ctx, health, RunParams{})
+		if err := s.finishCrawl(ctx, health, run, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports := s.ParserHealthReports()
+	if len(reports) != 2 || reports[0].Source != "korean-business-feeds" || reports[1].Source != "naver-mainnews" {
+		t.Fatalf("reports = %+v", reports)
+	}
+	if health := s.LastParserHealth("naver-mainnews"); health == nil || health.RunID == "" {
+		t.Errorf("naver-mainnews report = %+v", health)
+	}
+	if s.LastParserHealth("missing") != nil {
+		t.Error("report of a source never crawled")
+	}
+}
+
+func TestParserHealthBodyThresholds(t *testing.T) {
+	thresholds := ParserHealthThresholds{MinItems: 0, MaxMissingFieldRatio: 1, MaxBodyFallbackRatio: 0.3, MinMedianBodyLength: 100}
+
+	healthy := NewParserHealth(thresholds, "")
+	healthy.RecordBody(string(make([]rune, 150)), ExtractionSelector)
+	healthy.RecordBody(string(make([]rune, 300)), ExtractionSelector)
+	if healthy.Finish() {
+		t.Errorf("expected a healthy run, got reasons %v", healthy.Reasons)
+	}
+
+	short := NewParserHealth(thresholds, "")
+	short.RecordBody("짧은 본문", ExtractionSelector)
+	if !short.Finish() {
+		t.Error("expected a short median body length to degrade the run")
+	}
+}
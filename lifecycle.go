@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// readinessTimeout bounds each readiness check.
+const readinessTimeout = 3 * time.Second
+
+// errCrawlInterrupted is returned by the crawls Shutdown interrupts.
+var errCrawlInterrupted = errors.New("crawl interrupted by a shutdown")
+
+// ReadinessCheck is the result of one check of GET /readyz.
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Readiness runs the readiness checks: the service is not shutting down, its configuration is
+// valid and its article store answers. reload is the status of the configuration reloads; a
+// rejected reload is reported, but the service keeps running on its previous configuration.
+func (s *NewsCrawlerService) Readiness(ctx context.Context, reload *ReloadStatus) []ReadinessCheck {
+	job := s.Snapshot()
+	checks := []ReadinessCheck{{Name: "shutdown", OK: !s.ShuttingDown()}}
+	if !checks[0].OK {
+		checks[0].Message = "shutting down"
+	}
+
+	config := ReadinessCheck{Name: "config", OK: true}
+	if err := job.Config.Validate(); err != nil {
+		config.OK, config.Message = false, err.Error()
+	} else if reload != nil && len(reload.Errors) > 0 {
+		config.Message = "last reload rejected, running the previous configuration: " + strings.Join(reload.Errors, "; ")
+	}
+	checks = append(checks, config)
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	store := ReadinessCheck{Name: "store", OK: true}
+	if _, err := job.Store.ListArticles(ctx, ArticleQuery{Limit: 1}); err != nil {
+		store.OK, store.Message = false, err.Error()
+	}
+	return append(checks, store)
+}
+
+// ShuttingDown reports whether Shutdown has started.
+func (s *NewsCrawlerService) ShuttingDown() bool {
+	root := s.root()
+	root.crawlingMu.Lock()
+	defer root.crawlingMu.Unlock()
+	return root.stopping
+}
+
+// runningCrawls returns the sources being crawled, sorted.
+func (s *NewsCrawlerService) runningCrawls() []string {
+	root := s.root()
+	root.crawlingMu.Lock()
+	defer root.crawlingMu.Unlock()
+	sources := make([]string, 0, len(root.crawling))
+	for source := range root.crawling {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Shutdown stops the service for a graceful shutdown. New crawls are refused (see beginCrawl);
+// the running crawls may finish within drainTimeout, then they are interrupted and record
+// their runs as interrupted. Once they have ended, Shutdown waits for the watchlist alerts
+// being sent, makes a last attempt at the queued webhook deliveries (those still failing are
+// sent after the restart) and closes the live article streams. It returns an error when ctx
+// is done first.
+func (s *NewsCrawlerService) Shutdown(ctx context.Context, drainTimeout time.Duration) error {
+	root := s.root()
+	s.interruption() // Makes sure interruptCrawls is set
+	root.crawlingMu.Lock()
+	root.stopping = true
+	root.crawlingMu.Unlock()
+	logger := componentLogger(LogComponentCrawler)
+
+	crawlsEnded := make(chan struct{})
+	go func() {
+		root.crawls.Wait()
+		close(crawlsEnded)
+	}()
+	if running := s.runningCrawls(); len(running) > 0 {
+		logger.Info("Waiting for the running crawls to finish", "sources", running, "timeout", drainTimeout)
+	}
+	drain := time.NewTimer(drainTimeout)
+	defer drain.Stop()
+	select {
+	case <-crawlsEnded:
+	case <-drain.C:
+	case <-ctx.Done():
+	}
+	if running := s.runningCrawls(); len(running) > 0 {
+		logger.Warn("Interrupting the running crawls", "sources", running)
+		root.interruptCrawls()
+		select {
+		case <-crawlsEnded:
+		case <-ctx.Done():
+			return fmt.Errorf("crawls of %s still running: %v", strings.Join(s.runningCrawls(), ", "), ctx.Err())
+		}
+	}
+
+	alertsSent := make(chan struct{})
+	go func() {
+		root.alerts.Wait()
+		close(alertsSent)
+	}()
+	select {
+	case <-alertsSent:
+	case <-ctx.Done():
+		return fmt.Errorf("watchlist alerts still being sent: %v", ctx.Err())
+	}
+	if s.Webhooks != nil {
+		if _, err := s.Webhooks.ProcessDue(ctx); err != nil {
+			logger.Warn("Webhook deliveries left queued", "error", err)
+		}
+	}
+	if s.Events != nil {
+		s.Events.CloseAll()
+	}
+	return nil
+}
+
+// shutdownServer stops the server gracefully within the configured shutdownTimeout: the
+// crawls are drained or interrupted (see NewsCrawlerService.Shutdown), then the HTTP server
+// stops accepting connections and waits for the requests in progress.
+func shutdownServer(app *fiber.App, s *NewsCrawlerService) error {
+	cfg := s.Snapshot().Config
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	err := s.Shutdown(ctx, cfg.ShutdownDrainTimeout)
+	if httpErr := app.ShutdownWithContext(ctx); err == nil {
+		err = httpErr
+	}
+	return err
+}
+
+// healthzHandler answers GET /healthz (liveness): the process serves requests.
+func healthzHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok"})
+	}
+}
+
+// readyzHandler answers GET /readyz (readiness) with the readiness checks: 200 when they all
+// pass, 503 otherwise (including during a shutdown). reloader may be nil.
+func readyzHandler(s *NewsCrawlerService, reloader *ConfigReloader) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var reload *ReloadStatus
+		if reloader != nil {
+			status := reloader.Status()
+			reload = &status
+		}
+		checks := s.Readiness(c.UserContext(), reload)
+		for _, check := range checks {
+			if !check.OK {
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "unavailable", "checks": checks})
+			}
+		}
+		return c.JSON(fiber.Map{"status": "ready", "checks": checks})
+	}
+}
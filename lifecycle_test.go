@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShutdownInterruptsCrawl(t *testing.T) {
+	articleRequested := make(chan struct{}, 1)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed" {
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>test</title>
+<item><title>첫 기사</title><link>http://%[1]s/a/1</link><description>요약 1</description></item>
+<item><title>둘째 기사</title><link>http://%[1]s/a/2</link><description>요약 2</description></item>
+</channel></rss>`, r.Host)
+			return
+		}
+		articleRequested <- struct{}{}
+		select { // The article page never answers, until the crawler gives up
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer site.Close()
+
+	cfg := DefaultConfig()
+	cfg.ArticleStore = "memory"
+	cfg.ArticleDelay, cfg.ArticleRetryDelay = 0, 0
+	s := NewNewsCrawlerService(cfg)
+	s.Store = NewMemoryArticleStore()
+	s.Runs = NewMemoryRunStore()
+	def := &SourceDefinition{ID: "shutdown-test", Type: SourceTypeFeed, Feeds: []FeedConfig{
+		{URL: site.URL + "/feed", Publisher: "테스트", Body: FieldSelector{Selector: "article"}},
+	}}
+
+	job := s.Job(context.Background(), JobInfo{ID: "job-1", Trigger: RunTriggerManual})
+	end, err := job.beginCrawl(def.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawled := make(chan error, 1)
+	go func() {
+		defer end()
+		_, err := job.CrawlSource(def, 1)
+		crawled <- err
+	}()
+	<-articleRequested
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	started := time.Now()
+	if err := s.Shutdown(ctx, 100*time.Millisecond); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Shutdown took %s: the crawl was not interrupted", elapsed)
+	}
+	if err := <-crawled; !errors.Is(err, errCrawlInterrupted) {
+		t.Errorf("crawl error = %v, want errCrawlInterrupted", err)
+	}
+	run, err := s.Runs.GetRun(context.Background(), job.LastRun().ID)
+	if err != nil || run == nil || run.Outcome != RunOutcomeInterrupted || run.FinishedAt.IsZero() {
+		t.Errorf("saved run = %+v, %v", run, err)
+	} else if run.New != 0 || run.ErrorCount != 0 {
+		t.Errorf("interrupted article counted: %+v", run)
+	}
+
+	if _, err := s.beginCrawl(def.ID); !errors.Is(err, errShuttingDown) {
+		t.Errorf("beginCrawl after Shutdown = %v, want errShuttingDown", err)
+	}
+}
+
+func TestShutdownSendsQueuedWebhooks(t *testing.T) {
+	receiver := &webhookReceiver{}
+	d, sub, _ := newTestDispatcher(t, receiver, WebhookEventAll)
+	s := NewNewsCrawlerService(DefaultConfig())
+	s.Store = NewMemoryArticleStore()
+	s.Webhooks = d
+	s.notifyWebhooks(context.Background(), WebhookEventArticleCreated, NewsArticle{URL: "https://example.com/a/1"})
+
+	if err := s.Shutdown(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, _ := d.Store.ListDeliveries(context.Background(), sub.ID, 10)
+	if len(receiver.requests) != 1 || len(deliveries) != 1 || deliveries[0].Status != DeliverySucceeded {
+		t.Errorf("queued delivery not sent at shutdown: %d request(s), %+v", len(receiver.requests), deliveries)
+	}
+}
+
+func TestRetryBackoffIsInterrupted(t *testing.T) {
+	site := httptest.NewServer(http.NotFoundHandler())
+	site.Close() // Connection refused: a retryable error
+	cfg := DefaultConfig()
+	cfg.ArticleFetchRetries, cfg.ArticleRetryDelay = 3, time.Minute
+	s := NewNewsCrawlerService(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if _, err := s.fetchArticleDocument(ctx, site.URL+"/a/1", s.newParserHealth("backoff-test")); err == nil {
+		t.Fatal("fetch of a closed server succeeded")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("fetch returned after %s: the retry backoff ignored the context", elapsed)
+	}
+}
+
+// unreachableStore is an article store whose backend does not answer.
+type unreachableStore struct{ ArticleStore }
+
+func (unreachableStore) ListArticles(ctx context.Context, q ArticleQuery) (*ArticlePage, error) {
+	return nil, errors.New("store unreachable")
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ArticleStore = "memory"
+	s := NewNewsCrawlerService(cfg)
+	s.Store = NewMemoryArticleStore()
+	app := fiber.New()
+	app.Get("/healthz", healthzHandler())
+	app.Get("/readyz", readyzHandler(s, nil))
+
+	get := func(target string) (int, map[string]interface{}) {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Errorf("GET /healthz = %d", status)
+	}
+	if status, body := get("/readyz"); status != http.StatusOK {
+		t.Errorf("GET /readyz = %d %v", status, body)
+	}
+
+	s.Store = unreachableStore{s.Store}
+	if status, body := get("/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz with an unreachable store = %d %v", status, body)
+	}
+
+	s.Store = NewMemoryArticleStore()
+	if err := s.Shutdown(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := get("/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz while shutting down = %d %v", status, body)
+	}
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Errorf("GET /healthz while shutting down = %d", status)
+	}
+}
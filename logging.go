@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Log formats (logFormat).
+const (
+	LogFormatJSON = "json" // One JSON object per line, with the Cloud Logging field names
+	LogFormatText = "text" // key=value pairs, for terminals
+)
+
+// Log components, each with its own level (logLevels).
+const (
+	LogComponentApp        = "app"        // Process start and stop, and the messages of the log package (third-party libraries)
+	LogComponentServer     = "server"     // HTTP requests and API handlers
+	LogComponentAuth       = "auth"       // Rejected credentials, OIDC keys
+	LogComponentCrawler    = "crawler"    // Crawl runs: list pages, articles, parser health
+	LogComponentFetcher    = "fetcher"    // HTTP requests of the crawler
+	LogComponentMedia      = "media"      // Lead images and thumbnails
+	LogComponentStore      = "store"      // Article store
+	LogComponentWebhooks   = "webhooks"   // Outbound webhook queue and deliveries
+	LogComponentWatchlists = "watchlists" // Watchlist alerts
+	LogComponentScheduler  = "scheduler"  // Built-in schedules
+	LogComponentConfig     = "config"     // Configuration loading and reloads
+	LogComponentCLI        = "cli"        // Results of the export, import, search and maintenance commands
+)
+
+var logComponents = []string{LogComponentApp, LogComponentServer, LogComponentAuth, LogComponentCrawler, LogComponentFetcher, LogComponentMedia,
+	LogComponentStore, LogComponentWebhooks, LogComponentWatchlists, LogComponentScheduler, LogComponentConfig, LogComponentCLI}
+
+// Keys of the structured log fields, shared by every component so that logs can be queried
+// across them (e.g. jsonPayload.job_id in Cloud Logging).
+const (
+	LogKeyComponent  = "component"
+	LogKeyJobID      = "job_id"     // Crawl run: the request ID of an HTTP-triggered crawl, else generated
+	LogKeyRequestID  = "request_id" // HTTP request (X-Request-ID)
+	LogKeySource     = "source"     // Source ID
+	LogKeyPage       = "page"       // List page number, or feed URL
+	LogKeyArticleURL = "article_url"
+	LogKeyStage      = "stage"    // Crawl stage: list, article, parse, store
+	LogKeyDuration   = "duration" // Rendered as a Go duration string
+	LogKeyError      = "error"
+)
+
+// Crawl stages (LogKeyStage).
+const (
+	StageList    = "list"
+	StageArticle = "article"
+	StageParse   = "parse"
+	StageStore   = "store"
+)
+
+// redactedLogKeys are field keys whose values are never logged.
+var redactedLogKeys = []string{"authorization", "password", "secret", "token", "api_key"}
+
+// logSettings holds the reloadable settings of the log handler.
+var logSettings = struct {
+	sync.RWMutex
+	level          slog.Level
+	components     map[string]slog.Level
+	maxFieldLength int
+}{level: slog.LevelInfo}
+
+// parseLogLevel parses debug, info, warn (or warning) and error.
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if strings.EqualFold(s, "warning") {
+		s = "warn"
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("%q is not a log level (debug, info, warn, error)", s)
+	}
+	return level, nil
+}
+
+// validateLogConfig returns the errors of the logging keys of cfg.
+func validateLogConfig(cfg *Config) []error {
+	var errs []error
+	if cfg.LogFormat != LogFormatJSON && cfg.LogFormat != LogFormatText {
+		errs = append(errs, fmt.Errorf("logFormat: %q is not supported (%s, %s)", cfg.LogFormat, LogFormatJSON, LogFormatText))
+	}
+	if _, err := parseLogLevel(cfg.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("logLevel: %v", err))
+	}
+	for component, level := range cfg.LogLevels {
+		if !containsString(logComponents, component) {
+			errs = append(errs, fmt.Errorf("logLevels.%s: unknown component (%s)", component, strings.Join(logComponents, ", ")))
+		}
+		if _, err := parseLogLevel(level); err != nil {
+			errs = append(errs, fmt.Errorf("logLevels.%s: %v", component, err))
+		}
+	}
+	if cfg.LogMaxFieldLength < 0 {
+		errs = append(errs, fmt.Errorf("logMaxFieldLength: must not be negative (0 for no limit)"))
+	}
+	return errs
+}
+
+// applyLogSettings applies the reloadable logging settings of cfg: the levels and the field
+// length limit. cfg is valid.
+func applyLogSettings(cfg *Config) {
+	level, _ := parseLogLevel(cfg.LogLevel)
+	components := map[string]slog.Level{}
+	for component, value := range cfg.LogLevels {
+		components[component], _ = parseLogLevel(value)
+	}
+	logSettings.Lock()
+	defer logSettings.Unlock()
+	logSettings.level, logSettings.components, logSettings.maxFieldLength = level, components, cfg.LogMaxFieldLength
+}
+
+// setupLogging makes the logger of cfg the default slog logger, writing to stderr. The
+// messages of the log package go through it too, at the level announced by their prefix
+// ("Warning:", "Error", ...).
+func setupLogging(cfg *Config) {
+	applyLogSettings(cfg)
+	slog.SetDefault(slog.New(newLogHandler(os.Stderr, cfg.LogFormat)))
+	log.SetFlags(0)
+	log.SetOutput(legacyLogWriter{logger: componentLogger(LogComponentApp)})
+}
+
+// componentLogger returns the default logger for a component.
+func componentLogger(component string) *slog.Logger {
+	return slog.Default().With(LogKeyComponent, component)
+}
+
+// logFieldsKey is the context key of the fields set by withLogFields.
+type logFieldsKey struct{}
+
+// withLogFields returns a copy of ctx carrying log fields (key-value pairs, as for slog.Logger.With),
+// so that what a crawl hands over (webhooks, watchlist alerts, media) logs the job and source.
+func withLogFields(ctx context.Context, args ...any) context.Context {
+	fields, _ := ctx.Value(logFieldsKey{}).([]any)
+	return context.WithValue(ctx, logFieldsKey{}, append(fields[:len(fields):len(fields)], args...))
+}
+
+// contextLogger returns the default logger for a component, with the log fields of ctx.
+func contextLogger(ctx context.Context, component string) *slog.Logger {
+	logger := componentLogger(component)
+	if fields, _ := ctx.Value(logFieldsKey{}).([]any); len(fields) > 0 {
+		logger = logger.With(fields...)
+	}
+	return logger
+}
+
+// requestLogger returns the server logger with the request ID of c.
+func requestLogger(c *fiber.Ctx) *slog.Logger {
+	return componentLogger(LogComponentServer).With(LogKeyRequestID, requestID(c))
+}
+
+// newJobID returns a random ID for a crawl run not started by an HTTP request.
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logHandler filters records by the level of their component, and renders them with the
+// field names of Cloud Logging structured logs (severity, message, time).
+type logHandler struct {
+	inner     slog.Handler
+	component string
+}
+
+// newLogHandler creates the log handler writing to w in format.
+func newLogHandler(w io.Writer, format string) *logHandler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceLogAttr}
+	if format == LogFormatText {
+		return &logHandler{inner: slog.NewTextHandler(w, opts)}
+	}
+	return &logHandler{inner: slog.NewJSONHandler(w, opts)}
+}
+
+// Enabled implements slog.Handler.
+func (h *logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	logSettings.RLock()
+	defer logSettings.RUnlock()
+	min, ok := logSettings.components[h.component]
+	if !ok {
+		min = logSettings.level
+	}
+	return level >= min
+}
+
+// Handle implements slog.Handler.
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler. The component attribute selects the level.
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, attr := range attrs {
+		if attr.Key == LogKeyComponent {
+			component = attr.Value.String()
+		}
+	}
+	return &logHandler{inner: h.inner.WithAttrs(attrs), component: component}
+}
+
+// WithGroup implements slog.Handler.
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{inner: h.inner.WithGroup(name), component: h.component}
+}
+
+// cloudLoggingSeverities maps the slog levels to the severities of Cloud Logging.
+var cloudLoggingSeverities = map[slog.Level]string{
+	slog.LevelDebug: "DEBUG",
+	slog.LevelInfo:  "INFO",
+	slog.LevelWarn:  "WARNING",
+	slog.LevelError: "ERROR",
+}
+
+// replaceLogAttr renames the built-in fields for Cloud Logging, renders durations, and applies
+// the redaction and truncation policy to the other fields.
+func replaceLogAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch a.Key {
+		case slog.LevelKey:
+			level, _ := a.Value.Any().(slog.Level)
+			severity, ok := cloudLoggingSeverities[level]
+			if !ok {
+				severity = level.String()
+			}
+			return slog.String("severity", severity)
+		case slog.MessageKey:
+			return slog.String("message", truncateLogValue(a.Value.String()))
+		case slog.TimeKey:
+			return a
+		}
+	}
+	for _, key := range redactedLogKeys {
+		if strings.Contains(strings.ToLower(a.Key), key) {
+			return slog.String(a.Key, "REDACTED")
+		}
+	}
+	switch a.Value.Kind() {
+	case slog.KindDuration:
+		return slog.String(a.Key, a.Value.Duration().Round(time.Microsecond).String())
+	case slog.KindString:
+		return slog.String(a.Key, truncateLogValue(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, truncateLogValue(err.Error()))
+		}
+	}
+	return a
+}
+
+// truncateLogValue cuts s to logMaxFieldLength bytes (on a rune boundary), noting how much was cut.
+func truncateLogValue(s string) string {
+	logSettings.RLock()
+	max := logSettings.maxFieldLength
+	logSettings.RUnlock()
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…[%d bytes truncated]", s[:cut], len(s)-cut)
+}
+
+// legacyLogWriter forwards the messages of the log package, written by third-party libraries,
+// to a slog logger, at the level announced by their prefix: "Warning:" for warnings, "Error" or
+// "Failed" for errors, else info.
+type legacyLogWriter struct {
+	logger *slog.Logger
+}
+
+func (w legacyLogWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+	level := slog.LevelInfo
+	switch {
+	case strings.HasPrefix(msg, "Warning: "):
+		level, msg = slog.LevelWarn, strings.TrimPrefix(msg, "Warning: ")
+	case strings.HasPrefix(msg, "Info: "):
+		msg = strings.TrimPrefix(msg, "Info: ")
+	case strings.HasPrefix(msg, "Error"), strings.HasPrefix(msg, "Failed"):
+		level = slog.LevelError
+	}
+	w.logger.Log(context.Background(), level, msg)
+	return len(p), nil
+}
+
+// requestLogMiddleware logs every HTTP request with its request ID, set by the requestid
+// middleware.
+func requestLogMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		started := time.Now()
+		err := c.Next()
+		status := responseStatus(c, err)
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		componentLogger(LogComponentServer).Log(c.UserContext(), level, "HTTP request",
+			"method", c.Method(), "path", c.Path(), "status", status, LogKeyDuration, time.Since(started),
+			"ip", c.IP(), LogKeyRequestID, requestID(c))
+		return err
+	}
+}
+
+// responseStatus returns the status code of a response, including the one the error
+// handler will send for the error returned by the handlers.
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	if fiberErr, ok := err.(*fiber.Error); ok {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// requestID returns the ID of an HTTP request, set by the requestid middleware.
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestid").(string)
+	return id
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LogLevel = "warn"
+	cfg.LogLevels = map[string]string{LogComponentFetcher: "debug"}
+	cfg.LogMaxFieldLength = 16
+	applyLogSettings(cfg)
+	defer applyLogSettings(DefaultConfig())
+
+	var buf bytes.Buffer
+	logger := slog.New(newLogHandler(&buf, LogFormatJSON))
+	crawler := logger.With(LogKeyComponent, LogComponentCrawler, LogKeyJobID, "job-1")
+	crawler.Info("filtered out")
+	crawler.Warn("list page", LogKeySource, NaverMainNewsSourceID, LogKeyDuration, 1500*time.Millisecond,
+		"item_html", "<li>가나다라마바사</li>", "api_token", "hunter2")
+	logger.With(LogKeyComponent, LogComponentFetcher).Debug("fetched")
+	legacy := log.New(legacyLogWriter{logger: logger.With(LogKeyComponent, LogComponentApp)}, "", 0)
+	legacy.Printf("Warning: Not updated: %v", 1)
+	legacy.Printf("Info: not shown")
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3:\n%s", len(records), buf.String())
+	}
+	want := map[string]any{
+		"severity":    "WARNING",
+		"message":     "list page",
+		"component":   LogComponentCrawler,
+		"job_id":      "job-1",
+		"source":      NaverMainNewsSourceID,
+		"duration":    "1.5s",
+		"item_html":   "<li>가나다라…[14 bytes truncated]",
+		"api_token":   "REDACTED",
+		"time":        records[0]["time"],
+		"level":       nil,
+		"msg":         nil,
+		"article_url": nil,
+	}
+	for key, value := range want {
+		if records[0][key] != value {
+			t.Errorf("%s = %v, want %v", key, records[0][key], value)
+		}
+	}
+	if records[1]["severity"] != "DEBUG" || records[1]["component"] != LogComponentFetcher {
+		t.Errorf("component level not applied: %v", records[1])
+	}
+	if records[2]["severity"] != "WARNING" || records[2]["message"] != "Not updated: 1" {
+		t.Errorf("legacy log record = %v", records[2])
+	}
+}
+
+func TestLogConfigValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LogFormat = "xml"
+	cfg.LogLevel = "verbose"
+	cfg.LogLevels = map[string]string{"parser": "debug", LogComponentStore: "loud"}
+	cfg.LogMaxFieldLength = -1
+	errs := validateLogConfig(cfg)
+	if len(errs) != 5 {
+		t.Errorf("got %d errors, want 5: %v", len(errs), errs)
+	}
+
+	cfg, err := loadConfigLayers(ConfigSource{Overrides: []string{"logLevels.fetcher=debug", "logLevels.crawler=warn"}})
+	if err != nil || cfg.LogLevels[LogComponentFetcher] != "debug" || cfg.LogLevels[LogComponentCrawler] != "warn" {
+		t.Errorf("-set logLevels: %v (%v)", cfg.LogLevels, err)
+	}
+}
+
+type failingChannel struct{}
+
+func (failingChannel) Send(ctx context.Context, alert Alert) error {
+	return errors.New("channel down")
+}
+
+func TestCrawlLogFieldsReachWatchlists(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(logger *slog.Logger, output io.Writer, flags int) {
+		slog.SetDefault(logger)
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	}(slog.Default(), log.Writer(), log.Flags())
+	slog.SetDefault(slog.New(newLogHandler(&buf, LogFormatJSON)))
+
+	file := WatchlistFile{
+		Channels: []ChannelConfig{{Name: "slack", Type: ChannelSlack, URL: "http://example.com"}},
+		Rules:    []WatchlistRule{{ID: "samsung", Query: "삼성전자", Channels: []string{"slack"}}},
+	}
+	w, err := file.compile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Channels["slack"] = failingChannel{}
+	s := NewNewsCrawlerService(DefaultConfig())
+	s.Watchlists = w
+	job := s.Job(context.Background(), JobInfo{ID: "job-1", Trigger: RunTriggerManual})
+	ctx, end := job.startCrawl("log-test")
+	job.checkWatchlists(ctx, NewsArticle{Title: "삼성전자 실적", URL: "https://example.com/a/1", SourceID: "log-test"})
+	end()
+	job.WaitForAlerts()
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("invalid JSON log line %q: %v", buf.String(), err)
+	}
+	for key, value := range map[string]any{
+		"component":   LogComponentWatchlists,
+		"severity":    "WARNING",
+		"job_id":      "job-1",
+		"source":      "log-test",
+		"article_url": "https://example.com/a/1",
+		"channel":     "slack",
+	} {
+		if record[key] != value {
+			t.Errorf("%s = %v, want %v", key, record[key], value)
+		}
+	}
+}
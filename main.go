@@ -1,80 +1,519 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 func main() {
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// runServeCommand implements "news-crawler serve": the HTTP API server.
+func runServeCommand(args []string) int {
+	fs := newFlagSet("serve", "")
+	config := configFlags(fs)
+	port := fs.String("port", "", "listen port (shorthand for -set port=...)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *port != "" {
+		config.Overrides = append(config.Overrides, "port="+*port)
+	}
+
 	// 1. Load configurations
-	cfg := LoadConfig()
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		printConfigError(err)
+		return exitUsage
+	}
+	setupLogging(cfg)
+	shutdownTracing, err := setupTracing(cfg.Tracing)
+	if err != nil {
+		componentLogger(LogComponentApp).Error("Failed to start", LogKeyError, err)
+		return exitFailure
+	}
+	defer shutdownTracing(context.Background())
 
-	// 2. Initialize Firebase Firestore client
-	err := InitializeFirestoreClient(cfg.FirebaseServiceAccountKeyPath)
+	// 2-3. Open the stores and create the News Crawler Service instance
+	crawlerService, err := newCrawlerService(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize Firebase: %v", err)
+		componentLogger(LogComponentApp).Error("Failed to start", LogKeyError, err)
+		return exitFailure
 	}
+	// The background workers and the article exports stop when the shutdown starts.
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	// The webhook worker keeps sending the deliveries the draining crawls queue, until the
+	// shutdown has made its last pass (see NewsCrawlerService.Shutdown).
+	webhooks, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	go crawlerService.Webhooks.Run(webhooks)
+	scheduler, err := NewScheduler(crawlerService, cfg)
+	if err != nil {
+		componentLogger(LogComponentApp).Error("Failed to start", LogKeyError, err)
+		return exitFailure
+	}
+	go scheduler.Run(background)
+	reloader := NewConfigReloader(*config, crawlerService)
+	reloader.OnReload = func(cfg *Config) {
+		applyLogSettings(cfg)
+		if err := scheduler.Update(cfg); err != nil {
+			componentLogger(LogComponentConfig).Warn("Schedules not updated", LogKeyError, err)
+		}
+	}
+	go reloader.Run(background)
 
-	// 3. Create News Crawler Service instance
-	crawlerService := NewNewsCrawlerService(cfg)
+	// 4. Create Fiber web application (its banner would break JSON logs)
+	app := fiber.New(fiber.Config{DisableStartupMessage: cfg.LogFormat == LogFormatJSON})
 
-	// 4. Create Fiber web application
-	app := fiber.New()
+	// Liveness and readiness probes, ahead of the middlewares: no request logs, traces or
+	// authentication
+	app.Get("/healthz", healthzHandler())
+	app.Get("/readyz", readyzHandler(crawlerService, reloader))
 
-	// Add logging middleware
-	app.Use(logger.New())
+	// Request IDs (X-Request-ID, generated when missing), request spans continuing the caller's
+	// traceparent, and structured request logs
+	app.Use(requestid.New())
+	app.Use(tracingMiddleware())
+	app.Use(requestLogMiddleware())
 
 	// Add CORS middleware (might not be strictly necessary for a crawler,
 	// but kept for development convenience or if other services call this API)
 	app.Use(func(c *fiber.Ctx) error {
-		c.Set("Access-Control-Allow-Origin", "*")
-		c.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS") // GET is no longer used, can be removed
-		c.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept")
+		if origin := corsAllowOrigin(cfg.CORSAllowOrigins, c.Get("Origin")); origin != "" {
+			c.Set("Access-Control-Allow-Origin", origin)
+		}
+		c.Vary("Origin")
+		c.Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		c.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, "+APIKeyHeader+", "+RequestSignatureHeader)
 		if c.Method() == "OPTIONS" {
 			return c.SendStatus(fiber.StatusNoContent)
 		}
 		return c.Next()
 	})
 
+	// Authentication: the scope each route requires is listed in routeScopes
+	auth := NewAuth(cfg.Auth)
+	if auth.Anonymous.HasScope(ScopeCrawl) {
+		componentLogger(LogComponentAuth).Warn("Anonymous requests can trigger crawls", "anonymous_scopes", cfg.Auth.AnonymousScopes)
+	}
+	app.Use(auth.Middleware())
+
 	// 5. Define REST API Endpoints
 
+	// Lead images and thumbnails of the local media store
+	if cfg.MediaStore == "local" {
+		app.Static(cfg.MediaBaseURL, cfg.MediaDir, fiber.Static{MaxAge: 86400})
+	}
+
 	// News crawling trigger endpoint (for Cloud Scheduler)
 	app.Post("/api/schedule/crawl", func(c *fiber.Ctx) error {
-		log.Println("HTTP request received to start news crawling...")
+		// The run keeps the configuration it starts with, even if it is reloaded meanwhile,
+		// its logs carry the request ID as job ID, and its spans are part of the request's trace.
+		job := crawlerService.Job(c.UserContext(), JobInfo{ID: requestID(c), Trigger: RunTriggerManual})
+		logger := job.logger()
+		logger.Info("HTTP request received to start news crawling")
 
 		pagesStr := c.Query("pages", "1")
 		pages, err := strconv.Atoi(pagesStr)
 		if err != nil {
-			log.Printf("Invalid 'pages' parameter value: %s. Using default of 1.", pagesStr)
+			logger.Warn("Invalid 'pages' parameter value. Using default of 1.", "pages", pagesStr)
 			pages = 1
 		}
 
 		if pages <= 0 || pages > 10 {
-			log.Printf("Invalid number of pages requested: %d. Limited to 1-10 pages.", pages)
+			logger.Warn("Invalid number of pages requested. Limited to 1-10 pages.", "pages", pages)
 			return c.Status(fiber.StatusBadRequest).SendString("Invalid number of pages requested. Please specify within 1-10 pages.")
 		}
 
-		log.Printf("Crawling %d pages.", pages)
-
-		_, err = crawlerService.CrawlNaverFinanceNews(pages)
+		// The hand-written Naver crawler stays the default; ?source= selects a declarative source.
+		sourceID := c.Query("source")
+		end, err := job.beginCrawl(sourceID)
+		if errors.Is(err, errShuttingDown) {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("The service is shutting down. Retry later.")
+		}
+		if err != nil {
+			return c.Status(fiber.StatusConflict).SendString("A crawl of this source is already running.")
+		}
+		defer end()
+		if sourceID == "" {
+			_, err = job.CrawlNaverFinanceNews(pages)
+		} else {
+			def, ok := job.Sources[sourceID]
+			if !ok {
+				return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Unknown source: %s", sourceID))
+			}
+			_, err = job.CrawlSource(def, pages)
+		}
+		var degradedErr *ParserDegradedError
+		if errors.As(err, &degradedErr) {
+			// A distinct status code lets the scheduler tell selector drift apart from outages.
+			return c.Status(fiber.StatusFailedDependency).JSON(degradedErr.Health)
+		}
+		if errors.Is(err, errCrawlInterrupted) {
+			// The articles saved so far are kept; a retry collects the rest.
+			return c.Status(fiber.StatusServiceUnavailable).SendString(fmt.Sprintf("News crawling operation interrupted by a shutdown. Retry later. (run: %s)", job.LastRun().ID))
+		}
 		if err != nil {
-			log.Printf("Error during news crawling operation: %v", err)
 			return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Error during news crawling operation: %v", err))
 		}
-		log.Println("News crawling operation completed via HTTP request.")
-		return c.Status(fiber.StatusOK).SendString(fmt.Sprintf("News crawling operation successfully triggered. (Pages crawled: %d)", pages))
+		message := fmt.Sprintf("News crawling operation successfully triggered. (Pages crawled: %d)", pages)
+		if run := job.LastRun(); run != nil {
+			message = fmt.Sprintf("News crawling operation successfully triggered. (Pages crawled: %d, new articles: %d, outcome: %s, run: %s)", pages, run.New, run.Outcome, run.ID)
+		}
+		return c.Status(fiber.StatusOK).SendString(message)
+	})
+
+	// Built-in schedules with their next and last runs
+	app.Get("/api/schedules", func(c *fiber.Ctx) error {
+		timeZone, schedules := scheduler.Schedules()
+		return c.JSON(fiber.Map{"timeZone": timeZone, "schedules": schedules})
+	})
+
+	// Article listing, newest first, with cursor pagination
+	app.Get("/api/articles", func(c *fiber.Ctx) error {
+		return listArticlesHandler(c, crawlerService.Store)
+	})
+
+	// Bulk export of the matching articles (all pages) as NDJSON, CSV or Parquet
+	app.Get("/api/articles/export", func(c *fiber.Ctx) error {
+		return exportArticlesHandler(background, c, crawlerService.Store)
+	})
+
+	// Live stream of newly saved articles (Server-Sent Events), filtered per connection
+	app.Get("/api/articles/stream", func(c *fiber.Ctx) error {
+		filter := ArticleFilter{
+			Keyword:  c.Query("keyword"),
+			Ticker:   c.Query("ticker"),
+			SourceID: c.Query("source"),
+		}
+		lastEventID := c.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("lastEventId") // For clients that cannot set headers
+		}
+		sub, replay, complete := crawlerService.Events.Subscribe(filter, parseLastEventID(lastEventID))
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			streamArticleEvents(w, sub, replay, complete)
+		})
+		return nil
+	})
+
+	// RSS, Atom and JSON Feed syndication of the crawled articles
+	registerFeedRoutes(app, crawlerService)
+
+	// Webhook subscriptions and delivery logs
+	app.Post("/api/webhooks", func(c *fiber.Ctx) error {
+		var sub WebhookSubscription
+		if err := c.BodyParser(&sub); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON body."})
+		}
+		created, err := crawlerService.Webhooks.Subscribe(c.Context(), sub)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		// The secret is only ever returned here.
+		return c.Status(fiber.StatusCreated).JSON(created)
+	})
+	app.Get("/api/webhooks", func(c *fiber.Ctx) error {
+		subs, err := crawlerService.Webhooks.Store.ListSubscriptions(c.Context())
+		if err != nil {
+			requestLogger(c).Error("Error listing webhook subscriptions", LogKeyError, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error listing webhook subscriptions."})
+		}
+		for i := range subs {
+			subs[i].Secret = ""
+		}
+		return c.JSON(fiber.Map{"webhooks": subs})
+	})
+	app.Delete("/api/webhooks/:id", func(c *fiber.Ctx) error {
+		sub, err := crawlerService.Webhooks.Store.GetSubscription(c.Context(), c.Params("id"))
+		if err == nil && sub == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found."})
+		}
+		if err == nil {
+			err = crawlerService.Webhooks.Store.DeleteSubscription(c.Context(), sub.ID)
+		}
+		if err != nil {
+			requestLogger(c).Error("Error deleting webhook subscription", LogKeyError, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error deleting webhook subscription."})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	app.Get("/api/webhooks/:id/deliveries", func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 50)
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+		deliveries, err := crawlerService.Webhooks.Store.ListDeliveries(c.Context(), c.Params("id"), limit)
+		if err != nil {
+			requestLogger(c).Error("Error listing webhook deliveries", LogKeyError, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error listing webhook deliveries."})
+		}
+		if deliveries == nil {
+			deliveries = []WebhookDelivery{}
+		}
+		return c.JSON(fiber.Map{"deliveries": deliveries})
 	})
 
-	// 6. Start the server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8888"
+	// Crawl run history, most recent first
+	app.Get("/api/runs", func(c *fiber.Ctx) error {
+		query, err := runQueryFromRequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		runs, err := crawlerService.Runs.ListRuns(c.Context(), query)
+		if err != nil {
+			requestLogger(c).Error("Error listing crawl runs", LogKeyError, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error listing crawl runs."})
+		}
+		return c.JSON(fiber.Map{"runs": runs})
+	})
+	app.Get("/api/runs/:id", func(c *fiber.Ctx) error {
+		run, err := crawlerService.Runs.GetRun(c.Context(), c.Params("id"))
+		if err != nil {
+			requestLogger(c).Error("Error reading crawl run", LogKeyError, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error reading crawl run."})
+		}
+		if run == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Run not found."})
+		}
+		return c.JSON(run)
+	})
+
+	// Parser health of the latest crawl run of every source, degraded if any is
+	app.Get("/api/parser/health", func(c *fiber.Ctx) error {
+		reports := crawlerService.ParserHealthReports()
+		if len(reports) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "unknown", "message": "No crawl has finished yet."})
+		}
+		status := ParserStatusHealthy
+		for _, health := range reports {
+			if health.Status == ParserStatusDegraded {
+				status = ParserStatusDegraded
+				c.Status(fiber.StatusFailedDependency)
+			}
+		}
+		return c.JSON(fiber.Map{"status": status, "sources": reports})
+	})
+
+	// Parser health of the latest crawl run of one source
+	app.Get("/api/parser/health/:source", func(c *fiber.Ctx) error {
+		health := crawlerService.LastParserHealth(c.Params("source"))
+		if health == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "unknown", "message": "No crawl of this source has finished yet."})
+		}
+		if health.Status == ParserStatusDegraded {
+			return c.Status(fiber.StatusFailedDependency).JSON(health)
+		}
+		return c.JSON(health)
+	})
+
+	// Admin dashboard (server-rendered, embedded templates)
+	registerAdminRoutes(app, crawlerService)
+
+	// Prometheus metrics of the crawl, fetch, parse and store stages
+	app.Get("/metrics", MetricsHandler())
+
+	// Effective configuration (secrets redacted) and the outcome of the last reload
+	app.Get("/api/admin/config", func(c *fiber.Ctx) error {
+		config, err := configMap(crawlerService.Snapshot().Config.Redacted())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"reload": reloader.Status(), "config": config})
+	})
+	app.Post("/api/admin/config/reload", func(c *fiber.Ctx) error {
+		if err := reloader.Reload("api"); err != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"reload": reloader.Status()})
+		}
+		return c.JSON(fiber.Map{"reload": reloader.Status()})
+	})
+
+	// 6. Start the server, until SIGTERM (sent by Cloud Run on every scale-down) or SIGINT
+	stopping, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopSignals()
+	listening := make(chan error, 1)
+	go func() {
+		componentLogger(LogComponentApp).Info("Crawler server starting", "port", cfg.Port)
+		listening <- app.Listen(":" + cfg.Port)
+	}()
+	select {
+	case err := <-listening:
+		componentLogger(LogComponentApp).Error("Server stopped", LogKeyError, err)
+		return exitFailure
+	case <-stopping.Done():
+	}
+
+	// 7. Graceful shutdown; a second signal kills the process
+	stopSignals()
+	componentLogger(LogComponentApp).Info("Shutting down", "timeout", crawlerService.Snapshot().Config.ShutdownTimeout)
+	stopBackground()
+	if err := shutdownServer(app, crawlerService); err != nil {
+		componentLogger(LogComponentApp).Warn("Shutdown incomplete", LogKeyError, err)
+		return exitFailure
+	}
+	componentLogger(LogComponentApp).Info("Server stopped")
+	return exitOK
+}
+
+// articleQueryFromRequest reads the /api/articles query parameters: source, publisher, ticker,
+// from, to (RFC 3339 or YYYY-MM-DD in Asia/Seoul), summaryStatus, hasAISummary, limit and cursor.
+func articleQueryFromRequest(c *fiber.Ctx) (ArticleQuery, error) {
+	query := ArticleQuery{
+		SourceID:      c.Query("source"),
+		Publisher:     c.Query("publisher"),
+		Ticker:        c.Query("ticker"),
+		SummaryStatus: c.Query("summaryStatus"),
+		Cursor:        c.Query("cursor"),
+	}
+	var err error
+	if query.From, err = parseQueryTime(c.Query("from"), false); err != nil {
+		return query, fmt.Errorf("invalid from: %v", err)
+	}
+	if query.To, err = parseQueryTime(c.Query("to"), true); err != nil {
+		return query, fmt.Errorf("invalid to: %v", err)
+	}
+	if value := c.Query("hasAISummary"); value != "" {
+		hasAISummary, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, fmt.Errorf("invalid hasAISummary: %q", value)
+		}
+		query.HasAISummary = &hasAISummary
+	}
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit <= 0 {
+			return query, fmt.Errorf("invalid limit: %q", value)
+		}
+	}
+	return query, query.Validate()
+}
+
+// runQueryFromRequest reads the /api/runs query parameters: source, trigger, outcome,
+// from, to (RFC 3339 or YYYY-MM-DD in Asia/Seoul, applied to the start time) and limit.
+func runQueryFromRequest(c *fiber.Ctx) (RunQuery, error) {
+	query := RunQuery{
+		Source:  c.Query("source"),
+		Trigger: c.Query("trigger"),
+		Outcome: c.Query("outcome"),
+	}
+	var err error
+	if query.From, err = parseQueryTime(c.Query("from"), false); err != nil {
+		return query, fmt.Errorf("invalid from: %v", err)
+	}
+	if query.To, err = parseQueryTime(c.Query("to"), true); err != nil {
+		return query, fmt.Errorf("invalid to: %v", err)
+	}
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit <= 0 {
+			return query, fmt.Errorf("invalid limit: %q", value)
+		}
+	}
+	return query, query.Validate()
+}
+
+// listArticlesHandler answers GET /api/articles with a page of the articles matching the
+// query parameters (see articleQueryFromRequest), newest first.
+func listArticlesHandler(c *fiber.Ctx, store ArticleStore) error {
+	query, err := articleQueryFromRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	page, err := store.ListArticles(c.Context(), query)
+	if err != nil {
+		requestLogger(c).Error("Error listing articles", LogKeyError, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error listing articles."})
+	}
+	return c.JSON(page)
+}
+
+// exportArticlesHandler streams the articles matching the /api/articles filters in the format
+// given by ?format= (ndjson, csv or parquet). limit caps the number of articles (default: all)
+// and cursor is ignored: the export walks every page. Cancelling ctx (at shutdown) ends the
+// export, which the client sees truncated.
+func exportArticlesHandler(ctx context.Context, c *fiber.Ctx, store ArticleStore) error {
+	query, err := articleQueryFromRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	format, err := parseExportFormat(c.Query("format"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	max := c.QueryInt("limit", 0)
+
+	c.Set("Content-Type", exportContentTypes[format])
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="articles-%s.%s"`, time.Now().Format("20060102-150405"), format))
+	logger := requestLogger(c).With("format", format) // c is released before the stream is written
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The response has started: errors can only be logged (and truncate the export).
+		count, err := ExportArticles(ctx, store, query, max, format, w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			logger.Warn("Article export aborted", "articles", count, LogKeyError, err)
+			return
+		}
+		logger.Info("Articles exported", "articles", count)
+	})
+	return nil
+}
+
+// parseQueryTime parses an RFC 3339 timestamp or a YYYY-MM-DD date in Asia/Seoul.
+// A date used as an exclusive upper bound (endOfDay) means the end of that day.
+func parseQueryTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, seoulLocation())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date, got %q", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// seoulLocation returns the Asia/Seoul time zone, the zone of the news sites' dates.
+func seoulLocation() *time.Location {
+	seoul, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		return time.FixedZone("KST", 9*60*60)
+	}
+	return seoul
+}
+
+// corsAllowOrigin returns the Access-Control-Allow-Origin value answering a request from
+// origin: "*" when any origin is allowed, the origin itself when listed, else "".
+func corsAllowOrigin(allowed []string, origin string) string {
+	for _, a := range allowed {
+		if a == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(a, origin) {
+			return origin
+		}
 	}
-	log.Printf("Crawler server starting on port %s...", port)
-	log.Fatal(app.Listen(":" + port))
+	return ""
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// MaintenanceResult summarizes a pass over the stored articles.
+type MaintenanceResult struct {
+	Scanned int
+	Updated int            // Articles changed (and saved, unless dry run)
+	Changes map[string]int // Articles changed, by reindexed field or migration ID
+}
+
+func (r *MaintenanceResult) record(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	r.Updated++
+	for _, name := range names {
+		r.Changes[name]++
+	}
+}
+
+// updateArticles applies update to every article matching q and saves the changed ones.
+// update returns the names of what it changed. With dryRun nothing is saved.
+func updateArticles(ctx context.Context, store ArticleStore, q ArticleQuery, dryRun bool, update func(article *NewsArticle) []string) (MaintenanceResult, error) {
+	result := MaintenanceResult{Changes: map[string]int{}}
+	err := walkArticles(ctx, store, q, func(article *NewsArticle) (bool, error) {
+		result.Scanned++
+		changes := update(article)
+		if len(changes) > 0 && !dryRun {
+			// Saving keeps CollectedAt, so the walk's cursor is unaffected.
+			if err := store.SaveArticle(ctx, *article); err != nil {
+				return false, fmt.Errorf("error saving %s: %v", article.URL, err)
+			}
+		}
+		result.record(changes)
+		if result.Scanned%1000 == 0 {
+			componentLogger(LogComponentCLI).Info("Articles scanned", "scanned", result.Scanned, "updated", result.Updated)
+		}
+		return true, nil
+	})
+	return result, err
+}
+
+// reindexArticle recomputes the fields derived from an article's URL, text and content blocks,
+// as saveCollectedArticle and setArticleBlocks do for new articles.
+func reindexArticle(a *NewsArticle) {
+	a.OfficeID = officeIDFromURL(a.URL)
+	if len(a.ContentBlocks) > 0 {
+		a.ContentMarkdown = blocksToMarkdown(a.ContentBlocks)
+		a.Figures = extractFigures(a.ContentBlocks)
+	}
+	a.Tickers = extractTickers(a.Title + "\n" + a.Summary + "\n" + a.ContentMarkdown)
+	a.Sentiment = scoreSentiment(a)
+}
+
+// reindexedFields are the fields set by reindexArticle, by name.
+var reindexedFields = []struct {
+	name string
+	get  func(a *NewsArticle) interface{}
+}{
+	{"officeId", func(a *NewsArticle) interface{} { return a.OfficeID }},
+	{"contentMarkdown", func(a *NewsArticle) interface{} { return a.ContentMarkdown }},
+	{"figures", func(a *NewsArticle) interface{} { return a.Figures }},
+	{"tickers", func(a *NewsArticle) interface{} { return a.Tickers }},
+	{"sentiment", func(a *NewsArticle) interface{} { return a.Sentiment }},
+}
+
+// ReindexArticles recomputes the derived fields of the stored articles matching q (office ID,
+// Markdown, figures, tickers and sentiment) with the current extractors, and saves the
+// articles whose fields changed. The articles are not fetched again.
+func ReindexArticles(ctx context.Context, store ArticleStore, q ArticleQuery, dryRun bool) (MaintenanceResult, error) {
+	return updateArticles(ctx, store, q, dryRun, func(article *NewsArticle) []string {
+		before := *article
+		reindexArticle(article)
+		var changed []string
+		for _, field := range reindexedFields {
+			if !reflect.DeepEqual(field.get(&before), field.get(article)) {
+				changed = append(changed, field.name)
+			}
+		}
+		return changed
+	})
+}
+
+// articleMigration upgrades articles stored by an older version of the crawler. Migrations
+// are idempotent: Apply returns false when the article is already up to date.
+type articleMigration struct {
+	ID          string
+	Description string
+	Apply       func(a *NewsArticle) bool
+}
+
+// articleMigrations are the data migrations of the article store, oldest first.
+var articleMigrations = []articleMigration{
+	{
+		ID:          "source-id",
+		Description: "set sourceId on articles saved before declarative sources (all from naver-mainnews)",
+		Apply: func(a *NewsArticle) bool {
+			if a.SourceID != "" {
+				return false
+			}
+			a.SourceID = NaverMainNewsSourceID
+			return true
+		},
+	},
+	{
+		ID:          "extraction-method",
+		Description: "set extractionMethod on articles saved before it was recorded",
+		Apply: func(a *NewsArticle) bool {
+			if a.ExtractionMethod != "" {
+				return false
+			}
+			a.ExtractionMethod = ExtractionSelector
+			if a.Content == "" || a.Content == a.Summary {
+				a.ExtractionMethod = ExtractionSummary
+			}
+			return true
+		},
+	},
+	{
+		ID:          "office-id",
+		Description: "set officeId, used by the publisher filter, on articles saved before it existed",
+		Apply: func(a *NewsArticle) bool {
+			if a.OfficeID != "" {
+				return false
+			}
+			a.OfficeID = officeIDFromURL(a.URL)
+			return a.OfficeID != ""
+		},
+	},
+	{
+		ID:          "sentiment",
+		Description: "score the sentiment, used by the minSentiment watchlist rules, of articles saved before it was",
+		Apply: func(a *NewsArticle) bool {
+			if a.Sentiment != nil {
+				return false
+			}
+			a.Sentiment = scoreSentiment(a)
+			return true
+		},
+	},
+}
+
+// MigrateArticles applies the migrations to every stored article and saves the upgraded ones.
+func MigrateArticles(ctx context.Context, store ArticleStore, migrations []articleMigration, dryRun bool) (MaintenanceResult, error) {
+	return updateArticles(ctx, store, ArticleQuery{}, dryRun, func(article *NewsArticle) []string {
+		var applied []string
+		for _, m := range migrations {
+			if m.Apply(article) {
+				applied = append(applied, m.ID)
+			}
+		}
+		return applied
+	})
+}
+
+// RequeueFailedSummaries resets the retry count of the articles matching q whose summarization
+// failed (SummaryStatusRetrying), so that the summarization server picks them up again as
+// pending articles.
+func RequeueFailedSummaries(ctx context.Context, store ArticleStore, q ArticleQuery, dryRun bool) (MaintenanceResult, error) {
+	q.SummaryStatus = SummaryStatusRetrying
+	return updateArticles(ctx, store, q, dryRun, func(article *NewsArticle) []string {
+		if article.AISummary != "" || article.SummaryRetryCount == 0 {
+			return nil
+		}
+		article.SummaryRetryCount = 0
+		return []string{"summaryRetryCount"}
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReindexArticles(t *testing.T) {
+	store := NewMemoryArticleStore()
+	stale := NewsArticle{
+		Title:         "삼성전자(005930) 실적 개선에 신고가",
+		URL:           "https://n.news.naver.com/mnews/article/015/0000000001",
+		CollectedAt:   time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC),
+		ContentBlocks: []ContentBlock{{Type: "paragraph", Text: "영업이익은 6조6000억원으로 집계됐다."}},
+	}
+	current := stale
+	current.URL = "https://n.news.naver.com/mnews/article/015/0000000002"
+	reindexArticle(&current)
+	for _, article := range []NewsArticle{stale, current} {
+		if err := store.SaveArticle(context.Background(), article); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := ReindexArticles(context.Background(), store, ArticleQuery{}, true)
+	if err != nil || result.Scanned != 2 || result.Updated != 1 {
+		t.Fatalf("dry run: %+v, %v", result, err)
+	}
+	if got, _ := store.GetArticle(context.Background(), stale.URL); got.OfficeID != "" {
+		t.Error("dry run saved the article")
+	}
+
+	result, err = ReindexArticles(context.Background(), store, ArticleQuery{}, false)
+	if err != nil || result.Updated != 1 {
+		t.Fatalf("reindex: %+v, %v", result, err)
+	}
+	for _, field := range []string{"officeId", "contentMarkdown", "figures", "tickers", "sentiment"} {
+		if result.Changes[field] != 1 {
+			t.Errorf("%s changed on %d article(s)", field, result.Changes[field])
+		}
+	}
+	got, _ := store.GetArticle(context.Background(), stale.URL)
+	if got.OfficeID != "015" || len(got.Tickers) != 1 || got.Tickers[0] != "005930" || len(got.Figures) == 0 || got.Sentiment == nil || *got.Sentiment <= 0 {
+		t.Errorf("reindexed article: %+v", got)
+	}
+	if result, _ := ReindexArticles(context.Background(), store, ArticleQuery{}, false); result.Updated != 0 {
+		t.Errorf("second reindex updated %d article(s)", result.Updated)
+	}
+}
+
+func TestMigrateArticles(t *testing.T) {
+	store := NewMemoryArticleStore()
+	old := NewsArticle{
+		Title:       "기사",
+		Summary:     "요약",
+		Content:     "요약",
+		URL:         "https://n.news.naver.com/mnews/article/009/0000000001",
+		CollectedAt: time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC),
+	}
+	if err := store.SaveArticle(context.Background(), old); err != nil {
+		t.Fatal(err)
+	}
+	result, err := MigrateArticles(context.Background(), store, articleMigrations, false)
+	if err != nil || result.Updated != 1 || len(result.Changes) != 4 {
+		t.Fatalf("migrate: %+v, %v", result, err)
+	}
+	got, _ := store.GetArticle(context.Background(), old.URL)
+	if got.SourceID != NaverMainNewsSourceID || got.ExtractionMethod != ExtractionSummary || got.OfficeID != "009" || got.Sentiment == nil {
+		t.Errorf("migrated article: %+v", got)
+	}
+	if result, _ := MigrateArticles(context.Background(), store, articleMigrations, false); result.Updated != 0 {
+		t.Errorf("migrations are not idempotent: %+v", result)
+	}
+}
+
+func TestCrawlExitCodes(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		if r.URL.Query().Get("date") == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("<html><body><p>점검 중</p></body></html>"))
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.NaverFinanceBaseURL = server.URL + "/news/mainnews.naver"
+	cfg.ParserSampleDir = t.TempDir()
+	s := NewNewsCrawlerService(cfg)
+	s.Store = NewMemoryArticleStore()
+
+	// An unreachable news list fails the run.
+	_, err := s.CrawlNaverFinanceNews(1)
+	if code := crawlExitCode(err); code != exitFailure {
+		t.Errorf("unreachable list: exit %d (%v)", code, err)
+	}
+	// A list page without the news list is selector drift.
+	_, err = s.CrawlNaverFinanceNewsOn(time.Date(2024, 5, 10, 1, 0, 0, 0, time.UTC), 1)
+	if code := crawlExitCode(err); code != exitDegraded {
+		t.Errorf("missing list: exit %d (%v)", code, err)
+	}
+	if len(requested) != 2 || requested[1] != "/news/mainnews.naver?date=2024-05-10&page=1" {
+		t.Errorf("requested %v", requested)
+	}
+	if code := crawlExitCode(nil); code != exitOK {
+		t.Errorf("success: exit %d", code)
+	}
+}
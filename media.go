@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers GIF decoding for image.Decode
+	"image/jpeg"
+	_ "image/png" // Registers PNG decoding for image.Decode
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registers WebP decoding for image.Decode
+)
+
+// ArticleImage is an image of an article body.
+type ArticleImage struct {
+	URL      string `firestore:"url" json:"url"`
+	Caption  string `firestore:"caption,omitempty" json:"caption,omitempty"`
+	Width    int    `firestore:"width,omitempty" json:"width,omitempty"`
+	Height   int    `firestore:"height,omitempty" json:"height,omitempty"`
+	Position int    `firestore:"position" json:"position"` // Index of the image block in ContentBlocks
+}
+
+// imageSourceAttrs lists the attributes holding an image URL, lazy-loading ones first
+// (Naver serves <img data-src=...> with a placeholder src).
+var imageSourceAttrs = []string{"data-src", "data-original", "data-lazy-src", "src"}
+
+// imageBlock builds an image block from an <img> element. It returns false for
+// inline data URIs and tracking pixels.
+func imageBlock(img *goquery.Selection) (ContentBlock, bool) {
+	var src string
+	for _, attr := range imageSourceAttrs {
+		if value, ok := img.Attr(attr); ok && strings.TrimSpace(value) != "" {
+			src = strings.TrimSpace(value)
+			break
+		}
+	}
+	if src == "" || strings.HasPrefix(src, "data:") {
+		return ContentBlock{}, false
+	}
+	width := imageDimension(img, "width")
+	height := imageDimension(img, "height")
+	if (width > 0 && width <= 2) || (height > 0 && height <= 2) {
+		return ContentBlock{}, false
+	}
+	alt, _ := img.Attr("alt")
+	return ContentBlock{Type: BlockImage, URL: src, Width: width, Height: height, alt: normalizeSpace(alt)}, true
+}
+
+// imageDimension reads a pixel dimension from an attribute (or its data- variant).
+func imageDimension(img *goquery.Selection, attr string) int {
+	for _, name := range []string{attr, "data-" + attr} {
+		if value, ok := img.Attr(name); ok {
+			if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "px")); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// resolveURL resolves ref against base; it returns ref unchanged when either does not parse.
+func resolveURL(ref, base string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+// resolveImageURLs makes the image block URLs absolute against the article URL.
+func resolveImageURLs(blocks []ContentBlock, articleURL string) {
+	for i := range blocks {
+		if blocks[i].Type == BlockImage {
+			blocks[i].URL = resolveURL(blocks[i].URL, articleURL)
+		}
+	}
+}
+
+// articleImages lists the image blocks as ArticleImages, with their block positions.
+func articleImages(blocks []ContentBlock) []ArticleImage {
+	var images []ArticleImage
+	for i, block := range blocks {
+		if block.Type != BlockImage {
+			continue
+		}
+		images = append(images, ArticleImage{
+			URL:      block.URL,
+			Caption:  block.Text,
+			Width:    block.Width,
+			Height:   block.Height,
+			Position: i,
+		})
+	}
+	return images
+}
+
+// metaImageURL returns the page's og:image / twitter:image, resolved against the page URL.
+func metaImageURL(doc *goquery.Document, pageURL string) string {
+	for _, selector := range []string{`meta[property="og:image"]`, `meta[name="twitter:image"]`, `link[rel="image_src"]`} {
+		sel := doc.Find(selector).First()
+		value, _ := sel.Attr("content")
+		if value == "" {
+			value, _ = sel.Attr("href")
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			return resolveURL(value, pageURL)
+		}
+	}
+	return ""
+}
+
+// BlobStore stores binary objects (e.g. thumbnails) and returns their public URL.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) (string, error)
+}
+
+// LocalBlobStore is a BlobStore writing objects below a local directory,
+// served at BaseURL (see the /media static route).
+type LocalBlobStore struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocalBlobStore creates a LocalBlobStore rooted at dir.
+func NewLocalBlobStore(dir, baseURL string) *LocalBlobStore {
+	return &LocalBlobStore{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Put implements BlobStore.
+func (s *LocalBlobStore) Put(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	target := filepath.Join(s.Dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("error creating blob directory: %v", err)
+	}
+	// Write then rename so readers never see a partial file.
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("error writing blob %s: %v", key, err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return "", fmt.Errorf("error storing blob %s: %v", key, err)
+	}
+	return s.BaseURL + "/" + clean, nil
+}
+
+// MediaPipeline downloads an article's lead image, generates a thumbnail and stores both.
+type MediaPipeline struct {
+	Store          BlobStore
+	Fetcher        *Fetcher
+	ThumbnailWidth int
+	MaxImageBytes  int
+	MaxImagePixels int           // Larger lead images are not decoded (a small file can expand to gigabytes)
+	FetchTimeout   time.Duration // Timeout of the lead image download
+}
+
+// NewMediaPipeline creates a MediaPipeline storing into store.
+func NewMediaPipeline(store BlobStore, fetcher *Fetcher, thumbnailWidth int) *MediaPipeline {
+	return &MediaPipeline{
+		Store:          store,
+		Fetcher:        fetcher,
+		ThumbnailWidth: thumbnailWidth,
+		MaxImageBytes:  10 << 20,
+		MaxImagePixels: 40_000_000,
+		FetchTimeout:   20 * time.Second,
+	}
+}
+
+// Process stores the lead image of article and its thumbnail, filling LeadImageStoredURL,
+// ThumbnailURL and the lead image dimensions.
+func (p *MediaPipeline) Process(ctx context.Context, article *NewsArticle) error {
+	if article.LeadImageURL == "" {
+		return nil
+	}
+	data, contentType, err := p.Fetcher.fetch(ctx, article.LeadImageURL, p.FetchTimeout)
+	if err != nil {
+		return fmt.Errorf("error downloading lead image %s: %v", article.LeadImageURL, err)
+	}
+	if p.MaxImageBytes > 0 && len(data) > p.MaxImageBytes {
+		return fmt.Errorf("lead image %s is too large (%d bytes)", article.LeadImageURL, len(data))
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error decoding lead image %s: %v", article.LeadImageURL, err)
+	}
+	if p.MaxImagePixels > 0 && int64(config.Width)*int64(config.Height) > int64(p.MaxImagePixels) {
+		return fmt.Errorf("lead image %s is too large (%dx%d pixels)", article.LeadImageURL, config.Width, config.Height)
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error decoding lead image %s: %v", article.LeadImageURL, err)
+	}
+	bounds := src.Bounds()
+	article.LeadImageWidth, article.LeadImageHeight = bounds.Dx(), bounds.Dy()
+
+	sum := sha1.Sum([]byte(article.LeadImageURL))
+	key := path.Join(time.Now().Format("2006/01/02"), hex.EncodeToString(sum[:])[:20])
+
+	if contentType == "" {
+		contentType = "image/" + format
+	}
+	originalURL, err := p.Store.Put(ctx, key+"."+format, contentType, data)
+	if err != nil {
+		return err
+	}
+	article.LeadImageStoredURL = originalURL
+
+	thumbnail, err := encodeThumbnail(src, p.ThumbnailWidth)
+	if err != nil {
+		return fmt.Errorf("error generating thumbnail for %s: %v", article.LeadImageURL, err)
+	}
+	thumbnailURL, err := p.Store.Put(ctx, fmt.Sprintf("%s_w%d.jpg", key, p.ThumbnailWidth), "image/jpeg", thumbnail)
+	if err != nil {
+		return err
+	}
+	article.ThumbnailURL = thumbnailURL
+	return nil
+}
+
+// encodeThumbnail scales src down to width (keeping the aspect ratio) and encodes it as JPEG.
+// Images narrower than width are re-encoded at their own size.
+func encodeThumbnail(src image.Image, width int) ([]byte, error) {
+	bounds := src.Bounds()
+	if width <= 0 || bounds.Dx() <= width {
+		width = bounds.Dx()
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// processMedia runs the media pipeline, if configured, on a newly collected article.
+// Failures are logged and never prevent the article from being saved.
+func (s *NewsCrawlerService) processMedia(ctx context.Context, article *NewsArticle) {
+	if s.Media == nil || article.LeadImageURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*s.Media.FetchTimeout)
+	defer cancel()
+	if err := s.Media.Process(ctx, article); err != nil {
+		contextLogger(ctx, LogComponentMedia).Warn("Media pipeline failed", LogKeyArticleURL, article.URL, LogKeyError, err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractBlocksImages(t *testing.T) {
+	const body = `<div id="body">
+<span class="end_photo_org"><img data-src="/image/001.jpg" src="data:image/gif;base64,R0lGOD" width="640" height="360" alt="사옥"><em class="img_desc">삼성전자 서초사옥</em></span>
+<p>본문 첫 문단.</p>
+<img src="https://track.example.com/pixel.gif" width="1" height="1">
+<img src="https://example.com/chart.png">
+</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	article := NewsArticle{URL: "https://n.news.naver.com/mnews/article/015/0004970001", LeadImageURL: "https://example.com/thumb.jpg"}
+	setArticleBlocks(&article, extractBlocks(doc.Find("#body")))
+
+	want := []ArticleImage{
+		{URL: "https://n.news.naver.com/image/001.jpg", Caption: "삼성전자 서초사옥", Width: 640, Height: 360, Position: 0},
+		{URL: "https://example.com/chart.png", Position: 2},
+	}
+	if len(article.Images) != len(want) {
+		t.Fatalf("images = %+v, want %+v", article.Images, want)
+	}
+	for i := range want {
+		if article.Images[i] != want[i] {
+			t.Errorf("image %d = %+v, want %+v", i, article.Images[i], want[i])
+		}
+	}
+	if article.LeadImageURL != want[0].URL {
+		t.Errorf("LeadImageURL = %q, want the first body image", article.LeadImageURL)
+	}
+	if wantText := "삼성전자 서초사옥\n\n본문 첫 문단."; article.Content != wantText {
+		t.Errorf("content = %q, want %q", article.Content, wantText)
+	}
+	if !strings.HasPrefix(article.ContentMarkdown, "![사옥](https://n.news.naver.com/image/001.jpg)\n_삼성전자 서초사옥_") {
+		t.Errorf("markdown = %q", article.ContentMarkdown)
+	}
+}
+
+func TestMediaPipelineStoresThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for x := 0; x < 800; x++ {
+		for y := 0; y < 400; y++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, src); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(encoded.Bytes())
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	pipeline := NewMediaPipeline(NewLocalBlobStore(dir, "/media/"), NewFetcher("test", nil), 320)
+	article := NewsArticle{URL: "https://example.com/article", LeadImageURL: server.URL + "/lead.png"}
+	if err := pipeline.Process(context.Background(), &article); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	if article.LeadImageWidth != 800 || article.LeadImageHeight != 400 {
+		t.Errorf("lead image size = %dx%d, want 800x400", article.LeadImageWidth, article.LeadImageHeight)
+	}
+	if !strings.HasPrefix(article.LeadImageStoredURL, "/media/") || !strings.HasSuffix(article.LeadImageStoredURL, ".png") {
+		t.Errorf("LeadImageStoredURL = %q", article.LeadImageStoredURL)
+	}
+	if !strings.HasSuffix(article.ThumbnailURL, "_w320.jpg") {
+		t.Fatalf("ThumbnailURL = %q", article.ThumbnailURL)
+	}
+
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(article.ThumbnailURL, "/media/"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	thumbnail, err := jpeg.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := thumbnail.Bounds().Size(); size.X != 320 || size.Y != 160 {
+		t.Errorf("thumbnail size = %v, want 320x160", size)
+	}
+
+	pipeline.MaxImagePixels = 800*400 - 1
+	article = NewsArticle{URL: "https://example.com/article", LeadImageURL: server.URL + "/lead.png"}
+	if err := pipeline.Process(context.Background(), &article); err == nil || article.LeadImageStoredURL != "" {
+		t.Errorf("image over MaxImagePixels stored as %q (error %v)", article.LeadImageStoredURL, err)
+	}
+}
+
+func TestReloadRefreshesMediaFetcher(t *testing.T) {
+	s := NewNewsCrawlerService(DefaultConfig())
+	s.Media = NewMediaPipeline(NewLocalBlobStore(t.TempDir(), "/media/"), s.Fetcher, 320)
+	cfg := DefaultConfig()
+	cfg.UserAgent = "reloaded"
+	s.Reload(cfg, nil, nil)
+	if s.Media.Fetcher != s.Fetcher || s.Media.Fetcher.UserAgent != "reloaded" {
+		t.Errorf("media fetcher not reloaded: %+v", s.Media.Fetcher)
+	}
+}
+
+func TestLocalBlobStoreRejectsEscapingKeys(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalBlobStore(filepath.Join(dir, "media"), "/media")
+	url, err := store.Put(context.Background(), "../../etc/passwd", "text/plain", []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "/media/etc/passwd" {
+		t.Errorf("url = %q", url)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "media", "etc", "passwd")); err != nil {
+		t.Errorf("blob not stored below the store directory: %v", err)
+	}
+}
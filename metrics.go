@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Outcomes of the articles of a crawl, for newscrawler_articles_total.
+const (
+	ArticleOutcomeDiscovered = "discovered" // Listed on a list page or in a feed
+	ArticleOutcomeNew        = "new"        // Saved
+	ArticleOutcomeSkipped    = "skipped"    // Already stored, or the existence check failed
+	ArticleOutcomeFailed     = "failed"     // Could not be saved
+)
+
+// maxMetricHosts bounds the host label: requests to further hosts are counted as "other".
+// Hosts come from the source definitions and from the article links found on list pages.
+const maxMetricHosts = 50
+
+// metricsRegistry holds the metrics served on /metrics: those of the crawler, and the Go
+// runtime and process collectors.
+var metricsRegistry = prometheus.NewRegistry()
+
+// Crawler metrics. Their labels are bounded: source IDs come from the configuration, hosts
+// are limited by metricHost, and the other labels take a fixed set of values.
+var (
+	pagesFetchedMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "newscrawler_pages_fetched_total",
+		Help: "List pages and feeds requested by the crawler, by source and result (ok, error).",
+	}, []string{"source", "result"})
+	articlesMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "newscrawler_articles_total",
+		Help: "Articles seen by the crawler, by source and outcome (discovered, new, skipped, failed).",
+	}, []string{"source", "outcome"})
+	fetchDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "newscrawler_fetch_duration_seconds",
+		Help:    "Duration of the crawler's HTTP requests, by host.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"host"})
+	fetchResponsesMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "newscrawler_fetch_responses_total",
+		Help: "HTTP responses received by the crawler, by host and status code (\"error\" when no response was received).",
+	}, []string{"host", "code"})
+	fetchRetriesMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "newscrawler_fetch_retries_total",
+		Help: "Article requests retried after a network error, by host.",
+	}, []string{"host"})
+	parseFallbacksMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "newscrawler_parse_fallbacks_total",
+		Help: "Parser fallbacks, by source and kind (body: the body selector found nothing; list: the list selector matched nothing).",
+	}, []string{"source", "kind"})
+	storeDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "newscrawler_store_operation_duration_seconds",
+		Help:    "Duration of the article store operations, by operation.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"operation"})
+	storeErrorsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "newscrawler_store_errors_total",
+		Help: "Failed article store operations, by operation.",
+	}, []string{"operation"})
+	crawlDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "newscrawler_crawl_duration_seconds",
+		Help:    "Duration of crawl runs, by source and status (ok, degraded, failed).",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200},
+	}, []string{"source", "status"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		pagesFetchedMetric, articlesMetric,
+		fetchDurationMetric, fetchResponsesMetric, fetchRetriesMetric,
+		parseFallbacksMetric,
+		storeDurationMetric, storeErrorsMetric,
+		crawlDurationMetric,
+	)
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format.
+func MetricsHandler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+}
+
+var (
+	metricHostsMu sync.Mutex
+	metricHosts   = map[string]bool{}
+)
+
+// metricHost returns the host label of a URL: its host for the first maxMetricHosts hosts
+// seen, else "other".
+func metricHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "other"
+	}
+	host := u.Hostname()
+	metricHostsMu.Lock()
+	defer metricHostsMu.Unlock()
+	if !metricHosts[host] {
+		if len(metricHosts) >= maxMetricHosts {
+			return "other"
+		}
+		metricHosts[host] = true
+	}
+	return host
+}
+
+// observeFetch records an HTTP request of the crawler: its duration, and its status code
+// (0 when no response was received).
+func observeFetch(pageURL string, started time.Time, statusCode int) {
+	host := metricHost(pageURL)
+	fetchDurationMetric.WithLabelValues(host).Observe(time.Since(started).Seconds())
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	fetchResponsesMetric.WithLabelValues(host, code).Inc()
+}
+
+// observePageFetch records a list page or feed request of a crawl.
+func observePageFetch(source string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	pagesFetchedMetric.WithLabelValues(source, result).Inc()
+}
+
+// observeArticles records n articles of a crawl with an outcome.
+func observeArticles(source, outcome string, n int) {
+	articlesMetric.WithLabelValues(source, outcome).Add(float64(n))
+}
+
+// observeCrawl records a finished crawl run: its duration, status and parser fallbacks.
+func observeCrawl(health *ParserHealth, err error) {
+	status := ScheduleRunOK
+	var degradedErr *ParserDegradedError
+	if errors.As(err, &degradedErr) {
+		status = ScheduleRunDegraded
+	} else if err != nil {
+		status = ScheduleRunFailed
+	}
+	crawlDurationMetric.WithLabelValues(health.Source, status).Observe(health.FinishedAt.Sub(health.StartedAt).Seconds())
+	parseFallbacksMetric.WithLabelValues(health.Source, "body").Add(float64(health.BodyFallbacks))
+	parseFallbacksMetric.WithLabelValues(health.Source, "list").Add(float64(health.PagesWithoutList))
+}
+
+// HostFetchStats are the crawler's HTTP requests to a host since the process started.
+type HostFetchStats struct {
+	Host        string
+	Requests    int
+	Errors      int // No response, or a status code of 400 or more
+	Retries     int
+	AvgDuration time.Duration
+}
+
+// fetchStatsByHost reads the fetch metrics per host, busiest host first.
+func fetchStatsByHost() ([]HostFetchStats, error) {
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	byHost := map[string]*HostFetchStats{}
+	hostStats := func(host string) *HostFetchStats {
+		if byHost[host] == nil {
+			byHost[host] = &HostFetchStats{Host: host}
+		}
+		return byHost[host]
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			switch family.GetName() {
+			case "newscrawler_fetch_responses_total":
+				stats, n := hostStats(labels["host"]), int(m.GetCounter().GetValue())
+				stats.Requests += n
+				if code, err := strconv.Atoi(labels["code"]); err != nil || code >= 400 {
+					stats.Errors += n
+				}
+			case "newscrawler_fetch_retries_total":
+				hostStats(labels["host"]).Retries += int(m.GetCounter().GetValue())
+			case "newscrawler_fetch_duration_seconds":
+				if count := m.GetHistogram().GetSampleCount(); count > 0 {
+					seconds := m.GetHistogram().GetSampleSum() / float64(count)
+					hostStats(labels["host"]).AvgDuration = time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+				}
+			}
+		}
+	}
+	stats := make([]HostFetchStats, 0, len(byHost))
+	for _, s := range byHost {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].Host < stats[j].Host
+	})
+	return stats, nil
+}
+
+// instrumentedArticleStore is an ArticleStore recording the operations of the store it wraps:
+// their duration and errors in the metrics, and a span per operation.
+type instrumentedArticleStore struct {
+	store ArticleStore
+}
+
+// instrumentArticleStore wraps store to record its operations in the metrics and traces.
+func instrumentArticleStore(store ArticleStore) ArticleStore {
+	return &instrumentedArticleStore{store: store}
+}
+
+// startStoreOperation starts the span of a store operation. The returned function ends it
+// and records the operation in the metrics.
+func startStoreOperation(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	started := time.Now()
+	attrs = append(attrs, attribute.String("store.operation", operation))
+	ctx, span := tracer.Start(ctx, "store."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		storeDurationMetric.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+		if err != nil {
+			storeErrorsMetric.WithLabelValues(operation).Inc()
+		}
+		endSpan(span, err)
+	}
+}
+
+func (s *instrumentedArticleStore) GetArticle(ctx context.Context, url string) (*NewsArticle, error) {
+	ctx, end := startStoreOperation(ctx, "get", attribute.String(TraceKeyArticleURL, url))
+	article, err := s.store.GetArticle(ctx, url)
+	end(err)
+	return article, err
+}
+
+func (s *instrumentedArticleStore) SaveArticle(ctx context.Context, article NewsArticle) error {
+	ctx, end := startStoreOperation(ctx, "save", attribute.String(TraceKeyArticleURL, article.URL))
+	err := s.store.SaveArticle(ctx, article)
+	end(err)
+	return err
+}
+
+func (s *instrumentedArticleStore) ResetAISummary(ctx context.Context, url string) (bool, error) {
+	ctx, end := startStoreOperation(ctx, "resetAISummary", attribute.String(TraceKeyArticleURL, url))
+	changed, err := s.store.ResetAISummary(ctx, url)
+	end(err)
+	return changed, err
+}
+
+func (s *instrumentedArticleStore) ListArticles(ctx context.Context, q ArticleQuery) (*ArticlePage, error) {
+	ctx, end := startStoreOperation(ctx, "list")
+	page, err := s.store.ListArticles(ctx, q)
+	end(err)
+	return page, err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCrawlMetrics(t *testing.T) {
+	feed, err := os.ReadFile(filepath.Join("testdata", "feeds", "yna_economy_atom.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.ArticleStore = "memory"
+	s := NewNewsCrawlerService(cfg)
+	s.Store = instrumentArticleStore(NewMemoryArticleStore())
+	def := &SourceDefinition{ID: "metrics-test", Type: SourceTypeFeed, Feeds: []FeedConfig{
+		{URL: server.URL + "/feed", Publisher: "연합뉴스"},
+		{URL: server.URL + "/down", Publisher: "연합뉴스"},
+	}}
+	host := strings.TrimPrefix(server.URL, "http://")
+	host = host[:strings.LastIndex(host, ":")]
+	counts := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"newscrawler_pages_fetched_total", map[string]string{"source": "metrics-test", "result": "ok"}, 2},
+		{"newscrawler_pages_fetched_total", map[string]string{"source": "metrics-test", "result": "error"}, 2},
+		{"newscrawler_articles_total", map[string]string{"source": "metrics-test", "outcome": "discovered"}, 4},
+		{"newscrawler_articles_total", map[string]string{"source": "metrics-test", "outcome": "new"}, 2},
+		{"newscrawler_articles_total", map[string]string{"source": "metrics-test", "outcome": "skipped"}, 2},
+		{"newscrawler_fetch_responses_total", map[string]string{"host": host, "code": "503"}, 2},
+		{"newscrawler_crawl_duration_seconds", map[string]string{"source": "metrics-test", "status": "ok"}, 2},
+		{"newscrawler_store_operation_duration_seconds", map[string]string{"operation": "save"}, 2},
+		{"newscrawler_store_operation_duration_seconds", map[string]string{"operation": "get"}, 4},
+	}
+	before := make([]float64, len(counts))
+	for i, c := range counts {
+		before[i] = metricCount(t, c.name, c.labels)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.CrawlSource(def, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, c := range counts {
+		if got := metricCount(t, c.name, c.labels) - before[i]; got != c.want {
+			t.Errorf("%s%v increased by %v, want %v", c.name, c.labels, got, c.want)
+		}
+	}
+
+	app := fiber.New()
+	app.Get("/metrics", MetricsHandler())
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{`newscrawler_articles_total{outcome="new",source="metrics-test"} `, "go_goroutines "} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics does not contain %s", want)
+		}
+	}
+}
+
+// metricCount returns the value of a counter, or the sample count of a histogram, of the
+// metrics registry.
+func metricCount(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			if m.GetHistogram() != nil {
+				return float64(m.GetHistogram().GetSampleCount())
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMetricHostIsBounded(t *testing.T) {
+	metricHostsMu.Lock()
+	saved := metricHosts
+	metricHosts = map[string]bool{}
+	metricHostsMu.Unlock()
+	defer func() {
+		metricHostsMu.Lock()
+		metricHosts = saved
+		metricHostsMu.Unlock()
+	}()
+
+	for i := 0; i < maxMetricHosts; i++ {
+		if host := metricHost("https://news" + strings.Repeat("x", i) + ".example.com/a"); host == "other" {
+			t.Fatalf("host %d counted as other", i)
+		}
+	}
+	if host := metricHost("https://one-too-many.example.com/a"); host != "other" {
+		t.Errorf("host beyond the limit = %q", host)
+	}
+	if host := metricHost("https://news.example.com/b"); host != "news.example.com" {
+		t.Errorf("known host = %q", host)
+	}
+	if host := metricHost("not a url"); host != "other" {
+		t.Errorf("invalid URL = %q", host)
+	}
+}
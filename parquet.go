@@ -0,0 +1,761 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A minimal Parquet codec for the article export: flat schemas of required or optional
+// INT32, INT64, DOUBLE and BYTE_ARRAY columns, PLAIN encoded and uncompressed, with one
+// data page per column chunk, following the Parquet format specification. The reader below
+// only supports files of this shape; the tests also read the export with an independent
+// implementation (github.com/xitongsys/parquet-go), which new column types must pass too.
+
+// Parquet physical types.
+const (
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+)
+
+// Parquet converted (logical) types.
+const (
+	parquetNoConvertedType int32 = -1
+	parquetUTF8            int32 = 0
+	parquetTimestampMillis int32 = 9
+)
+
+const (
+	parquetMagic          = "PAR1"
+	parquetEncodingPlain  = 0
+	parquetEncodingRLE    = 3
+	parquetCodecNone      = 0
+	parquetPageData       = 0
+	parquetRepRequired    = 0
+	parquetRepOptional    = 1
+	parquetDefaultRowSize = 8192 // Rows per row group
+)
+
+// parquetColumn describes a column of a flat Parquet schema.
+type parquetColumn struct {
+	Name          string
+	Type          int32 // parquetInt32, parquetInt64, parquetDouble or parquetByteArray
+	ConvertedType int32 // parquetNoConvertedType, parquetUTF8 or parquetTimestampMillis
+	Optional      bool
+}
+
+// parquetWriter streams rows to a Parquet file. Rows are buffered per row group,
+// so memory use is bounded by RowGroupSize rows.
+type parquetWriter struct {
+	RowGroupSize int
+
+	w         *countingWriter
+	columns   []parquetColumn
+	buffered  [][]interface{} // Values by column of the current row group
+	rows      int
+	rowGroups []parquetRowGroup
+	totalRows int64
+}
+
+type parquetRowGroup struct {
+	chunks   []parquetChunk
+	numRows  int64
+	byteSize int64
+}
+
+type parquetChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// newParquetWriter writes the file header and returns a writer for the given schema.
+func newParquetWriter(w io.Writer, columns []parquetColumn) (*parquetWriter, error) {
+	pw := &parquetWriter{
+		RowGroupSize: parquetDefaultRowSize,
+		w:            &countingWriter{w: w},
+		columns:      columns,
+		buffered:     make([][]interface{}, len(columns)),
+	}
+	if _, err := io.WriteString(pw.w, parquetMagic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// Write buffers a row: one value per column, of type int32, int64, float64 or string
+// according to the column type, or nil for a null in an optional column.
+func (pw *parquetWriter) Write(row []interface{}) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("row has %d values, schema has %d columns", len(row), len(pw.columns))
+	}
+	for i, value := range row {
+		if value == nil && !pw.columns[i].Optional {
+			return fmt.Errorf("column %s is required", pw.columns[i].Name)
+		}
+		pw.buffered[i] = append(pw.buffered[i], value)
+	}
+	pw.rows++
+	if pw.rows >= pw.RowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+// flushRowGroup writes the buffered rows as a row group.
+func (pw *parquetWriter) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{numRows: int64(pw.rows)}
+	for i, column := range pw.columns {
+		page, err := encodeParquetPage(column, pw.buffered[i])
+		if err != nil {
+			return fmt.Errorf("column %s: %v", column.Name, err)
+		}
+		offset := pw.w.n
+		if _, err := pw.w.Write(page); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, parquetChunk{offset: offset, size: int64(len(page)), numValues: int64(pw.rows)})
+		group.byteSize += int64(len(page))
+		pw.buffered[i] = pw.buffered[i][:0]
+	}
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.totalRows += int64(pw.rows)
+	pw.rows = 0
+	return nil
+}
+
+// Close writes the remaining rows and the file footer.
+func (pw *parquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	footer := pw.fileMetaData()
+	if _, err := pw.w.Write(footer); err != nil {
+		return err
+	}
+	var tail [8]byte
+	binary.LittleEndian.PutUint32(tail[:4], uint32(len(footer)))
+	copy(tail[4:], parquetMagic)
+	_, err := pw.w.Write(tail[:])
+	return err
+}
+
+// encodeParquetPage encodes the values of a column chunk as a single PLAIN data page.
+func encodeParquetPage(column parquetColumn, values []interface{}) ([]byte, error) {
+	var data bytes.Buffer
+	if column.Optional {
+		levels := make([]int, len(values))
+		for i, value := range values {
+			if value != nil {
+				levels[i] = 1
+			}
+		}
+		encoded := encodeRLELevels(levels)
+		binary.Write(&data, binary.LittleEndian, uint32(len(encoded)))
+		data.Write(encoded)
+	}
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if err := writePlainValue(&data, column.Type, value); err != nil {
+			return nil, err
+		}
+	}
+
+	var header thriftWriter
+	header.i32(1, parquetPageData)
+	header.i32(2, int32(data.Len()))
+	header.i32(3, int32(data.Len()))
+	header.beginStruct(5) // DataPageHeader
+	header.i32(1, int32(len(values)))
+	header.i32(2, parquetEncodingPlain)
+	header.i32(3, parquetEncodingRLE)
+	header.i32(4, parquetEncodingRLE)
+	header.endStruct()
+	header.stop()
+	return append(header.buf.Bytes(), data.Bytes()...), nil
+}
+
+func writePlainValue(buf *bytes.Buffer, typ int32, value interface{}) error {
+	var ok bool
+	switch typ {
+	case parquetInt32:
+		var v int32
+		if v, ok = value.(int32); ok {
+			binary.Write(buf, binary.LittleEndian, v)
+		}
+	case parquetInt64:
+		var v int64
+		if v, ok = value.(int64); ok {
+			binary.Write(buf, binary.LittleEndian, v)
+		}
+	case parquetDouble:
+		var v float64
+		if v, ok = value.(float64); ok {
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(v))
+		}
+	case parquetByteArray:
+		var v string
+		if v, ok = value.(string); ok {
+			binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+			buf.WriteString(v)
+		}
+	}
+	if !ok {
+		return fmt.Errorf("value %v (%T) does not match the column type", value, value)
+	}
+	return nil
+}
+
+// encodeRLELevels encodes definition levels (bit width 1) as RLE runs of the
+// RLE/bit-packing hybrid encoding.
+func encodeRLELevels(levels []int) []byte {
+	var buf bytes.Buffer
+	var varint [binary.MaxVarintLen64]byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		n := binary.PutUvarint(varint[:], uint64(j-i)<<1)
+		buf.Write(varint[:n])
+		buf.WriteByte(byte(levels[i]))
+		i = j
+	}
+	return buf.Bytes()
+}
+
+// fileMetaData encodes the footer (FileMetaData) of the file.
+func (pw *parquetWriter) fileMetaData() []byte {
+	var t thriftWriter
+	t.i32(1, 1) // version
+	t.listHeader(2, thriftStruct, len(pw.columns)+1)
+	t.beginListStruct() // Root of the schema
+	t.binary(4, "schema")
+	t.i32(5, int32(len(pw.columns)))
+	t.endStruct()
+	for _, column := range pw.columns {
+		t.beginListStruct()
+		t.i32(1, column.Type)
+		repetition := int32(parquetRepRequired)
+		if column.Optional {
+			repetition = parquetRepOptional
+		}
+		t.i32(3, repetition)
+		t.binary(4, column.Name)
+		if column.ConvertedType != parquetNoConvertedType {
+			t.i32(6, column.ConvertedType)
+		}
+		t.endStruct()
+	}
+	t.i64(3, pw.totalRows)
+	t.listHeader(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		t.beginListStruct()
+		t.listHeader(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			column := pw.columns[i]
+			t.beginListStruct() // ColumnChunk
+			t.i64(2, chunk.offset)
+			t.beginStruct(3) // ColumnMetaData
+			t.i32(1, column.Type)
+			t.listHeader(2, thriftI32, 2)
+			t.listI32(parquetEncodingPlain)
+			t.listI32(parquetEncodingRLE)
+			t.listHeader(3, thriftBinary, 1)
+			t.listBinary(column.Name)
+			t.i32(4, parquetCodecNone)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.byteSize)
+		t.i64(3, group.numRows)
+		t.endStruct()
+	}
+	t.binary(6, "news-crawler")
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, as used by Parquet metadata.
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastIDs []int16 // Field ID stack of the enclosing structs
+	lastID  int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(uint64((id << 1) ^ (id >> 15)))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thriftWriter) listHeader(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) { t.varint(uint64(uint32((v << 1) ^ (v >> 31)))) }
+
+func (t *thriftWriter) listBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginListStruct()
+}
+
+// beginListStruct starts a struct that is a list element (no field header).
+func (t *thriftWriter) beginListStruct() {
+	t.lastIDs = append(t.lastIDs, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.lastID = t.lastIDs[len(t.lastIDs)-1]
+	t.lastIDs = t.lastIDs[:len(t.lastIDs)-1]
+}
+
+func (t *thriftWriter) stop() { t.buf.WriteByte(0) }
+
+// thriftFields is a decoded Thrift struct: values by field ID. Values are int64 (integers),
+// bool, float64, []byte, []interface{} (lists and sets) or thriftFields (structs).
+type thriftFields map[int16]interface{}
+
+func (f thriftFields) int(id int16) int64 {
+	v, _ := f[id].(int64)
+	return v
+}
+
+func (f thriftFields) str(id int16) string {
+	v, _ := f[id].([]byte)
+	return string(v)
+}
+
+func (f thriftFields) list(id int16) []interface{} {
+	v, _ := f[id].([]interface{})
+	return v
+}
+
+func (f thriftFields) strct(id int16) thriftFields {
+	v, _ := f[id].(thriftFields)
+	return v
+}
+
+// thriftReader decodes the Thrift compact protocol.
+type thriftReader struct {
+	r     *bytes.Reader
+	depth int
+}
+
+func (t *thriftReader) varint() (uint64, error) { return binary.ReadUvarint(t.r) }
+
+func (t *thriftReader) zigzag() (int64, error) {
+	v, err := t.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (t *thriftReader) readStruct() (thriftFields, error) {
+	if t.depth++; t.depth > 32 {
+		return nil, fmt.Errorf("thrift structs nested too deeply")
+	}
+	defer func() { t.depth-- }()
+	fields := thriftFields{}
+	var lastID int16
+	for {
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+		typ := header & 0x0F
+		id := lastID + int16(header>>4)
+		if header>>4 == 0 {
+			v, err := t.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		lastID = id
+		var value interface{}
+		switch typ {
+		case thriftBoolTrue, thriftBoolFalse:
+			value = typ == thriftBoolTrue
+		default:
+			if value, err = t.readValue(typ); err != nil {
+				return nil, err
+			}
+		}
+		fields[id] = value
+	}
+}
+
+func (t *thriftReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftBoolTrue, thriftBoolFalse: // List elements: one byte each
+		b, err := t.r.ReadByte()
+		return b == thriftBoolTrue, err
+	case thriftByte:
+		b, err := t.r.ReadByte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return t.zigzag()
+	case thriftDouble:
+		var v float64
+		err := binary.Read(t.r, binary.LittleEndian, &v)
+		return v, err
+	case thriftBinary:
+		n, err := t.varint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(t.r.Len()) {
+			return nil, fmt.Errorf("thrift binary of %d bytes exceeds the data", n)
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(t.r, b)
+		return b, err
+	case thriftList, thriftSet:
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = t.varint(); err != nil {
+				return nil, err
+			}
+		}
+		if size > uint64(t.r.Len()) {
+			return nil, fmt.Errorf("thrift list of %d elements exceeds the data", size)
+		}
+		list := make([]interface{}, 0, size)
+		for i := uint64(0); i < size; i++ {
+			v, err := t.readValue(header & 0x0F)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case thriftStruct:
+		return t.readStruct()
+	case thriftMap:
+		return nil, fmt.Errorf("thrift maps are not supported")
+	}
+	return nil, fmt.Errorf("unknown thrift type %d", typ)
+}
+
+// parquetReader reads the row groups of a Parquet file written by parquetWriter.
+type parquetReader struct {
+	r       io.ReaderAt
+	Columns []parquetColumn
+	NumRows int64
+	groups  []thriftFields
+}
+
+// openParquet reads the footer of a Parquet file of the given size.
+func openParquet(r io.ReaderAt, size int64) (*parquetReader, error) {
+	if size < 12 {
+		return nil, fmt.Errorf("not a Parquet file")
+	}
+	var tail [8]byte
+	if _, err := r.ReadAt(tail[:], size-8); err != nil {
+		return nil, err
+	}
+	if string(tail[4:]) != parquetMagic {
+		return nil, fmt.Errorf("not a Parquet file")
+	}
+	footerSize := int64(binary.LittleEndian.Uint32(tail[:4]))
+	if footerSize > size-12 {
+		return nil, fmt.Errorf("invalid Parquet footer size %d", footerSize)
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-8-footerSize); err != nil {
+		return nil, err
+	}
+	meta, err := (&thriftReader{r: bytes.NewReader(footer)}).readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet footer: %v", err)
+	}
+
+	pr := &parquetReader{r: r, NumRows: meta.int(3)}
+	schema := meta.list(2)
+	for i, element := range schema {
+		fields, _ := element.(thriftFields)
+		if i == 0 {
+			continue // Root
+		}
+		if fields.int(5) > 0 {
+			return nil, fmt.Errorf("nested Parquet schemas are not supported")
+		}
+		column := parquetColumn{
+			Name:          fields.str(4),
+			Type:          int32(fields.int(1)),
+			ConvertedType: parquetNoConvertedType,
+			Optional:      fields.int(3) == parquetRepOptional,
+		}
+		if _, ok := fields[6]; ok {
+			column.ConvertedType = int32(fields.int(6))
+		}
+		if fields.int(3) == 2 {
+			return nil, fmt.Errorf("repeated Parquet column %s is not supported", column.Name)
+		}
+		pr.Columns = append(pr.Columns, column)
+	}
+	for _, group := range meta.list(4) {
+		fields, _ := group.(thriftFields)
+		pr.groups = append(pr.groups, fields)
+	}
+	return pr, nil
+}
+
+// ReadRows calls fn with every row, one row group in memory at a time. The row
+// holds a value per column as described by parquetWriter.Write.
+func (pr *parquetReader) ReadRows(fn func(row []interface{}) error) error {
+	for g, group := range pr.groups {
+		numRows := int(group.int(3))
+		chunks := group.list(1)
+		if len(chunks) != len(pr.Columns) {
+			return fmt.Errorf("row group %d has %d columns, schema has %d", g, len(chunks), len(pr.Columns))
+		}
+		values := make([][]interface{}, len(pr.Columns))
+		for i, chunk := range chunks {
+			meta := chunk.(thriftFields).strct(3)
+			if meta == nil {
+				return fmt.Errorf("row group %d: column %s has no metadata", g, pr.Columns[i].Name)
+			}
+			if meta.int(4) != parquetCodecNone {
+				return fmt.Errorf("column %s: compressed Parquet files are not supported", pr.Columns[i].Name)
+			}
+			var err error
+			if values[i], err = pr.readChunk(pr.Columns[i], meta); err != nil {
+				return fmt.Errorf("row group %d, column %s: %v", g, pr.Columns[i].Name, err)
+			}
+			if len(values[i]) != numRows {
+				return fmt.Errorf("row group %d, column %s: %d values for %d rows", g, pr.Columns[i].Name, len(values[i]), numRows)
+			}
+		}
+		row := make([]interface{}, len(pr.Columns))
+		for r := 0; r < numRows; r++ {
+			for i := range values {
+				row[i] = values[i][r]
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readChunk decodes the data pages of a column chunk.
+func (pr *parquetReader) readChunk(column parquetColumn, meta thriftFields) ([]interface{}, error) {
+	offset := meta.int(9)
+	if dict, ok := meta[11]; ok && dict.(int64) > 0 {
+		return nil, fmt.Errorf("dictionary encoded Parquet files are not supported")
+	}
+	size := meta.int(7)
+	if size <= 0 || size > 1<<31 {
+		return nil, fmt.Errorf("invalid column chunk size %d", size)
+	}
+	chunk := make([]byte, size)
+	if _, err := pr.r.ReadAt(chunk, offset); err != nil {
+		return nil, err
+	}
+	numValues := int(meta.int(5))
+	values := make([]interface{}, 0, numValues)
+	r := bytes.NewReader(chunk)
+	for len(values) < numValues {
+		header, err := (&thriftReader{r: r}).readStruct()
+		if err != nil {
+			return nil, fmt.Errorf("invalid page header: %v", err)
+		}
+		pageSize := header.int(3)
+		if pageSize < 0 || pageSize > int64(r.Len()) {
+			return nil, fmt.Errorf("invalid page size %d", pageSize)
+		}
+		page := make([]byte, pageSize)
+		io.ReadFull(r, page)
+		if header.int(1) != parquetPageData {
+			continue // Index pages and the like
+		}
+		dataHeader := header.strct(5)
+		if dataHeader.int(2) != parquetEncodingPlain {
+			return nil, fmt.Errorf("only PLAIN encoded Parquet files are supported")
+		}
+		pageValues, err := decodeParquetPage(column, page, int(dataHeader.int(1)))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, pageValues...)
+	}
+	return values, nil
+}
+
+// decodeParquetPage decodes a PLAIN data page of n values.
+func decodeParquetPage(column parquetColumn, page []byte, n int) ([]interface{}, error) {
+	levels := make([]int, n)
+	for i := range levels {
+		levels[i] = 1
+	}
+	if column.Optional {
+		if len(page) < 4 {
+			return nil, fmt.Errorf("truncated definition levels")
+		}
+		size := int(binary.LittleEndian.Uint32(page))
+		if size > len(page)-4 {
+			return nil, fmt.Errorf("truncated definition levels")
+		}
+		var err error
+		if levels, err = decodeHybridLevels(page[4:4+size], n); err != nil {
+			return nil, err
+		}
+		page = page[4+size:]
+	}
+
+	r := bytes.NewReader(page)
+	values := make([]interface{}, n)
+	for i := range values {
+		if levels[i] == 0 {
+			continue
+		}
+		var err error
+		switch column.Type {
+		case parquetInt32:
+			var v int32
+			err = binary.Read(r, binary.LittleEndian, &v)
+			values[i] = v
+		case parquetInt64:
+			var v int64
+			err = binary.Read(r, binary.LittleEndian, &v)
+			values[i] = v
+		case parquetDouble:
+			var v float64
+			err = binary.Read(r, binary.LittleEndian, &v)
+			values[i] = v
+		case parquetByteArray:
+			var length uint32
+			if err = binary.Read(r, binary.LittleEndian, &length); err == nil {
+				if int(length) > r.Len() {
+					return nil, fmt.Errorf("truncated value")
+				}
+				b := make([]byte, length)
+				io.ReadFull(r, b)
+				values[i] = string(b)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported Parquet type %d", column.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("truncated values: %v", err)
+		}
+	}
+	return values, nil
+}
+
+// decodeHybridLevels decodes n definition levels of bit width 1 in the RLE/bit-packing hybrid encoding.
+func decodeHybridLevels(data []byte, n int) ([]int, error) {
+	r := bytes.NewReader(data)
+	levels := make([]int, 0, n)
+	for len(levels) < n {
+		header, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("truncated definition levels")
+		}
+		if header&1 == 0 { // RLE run
+			value, err := r.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("truncated definition levels")
+			}
+			for count := header >> 1; count > 0 && len(levels) < n; count-- {
+				levels = append(levels, int(value&1))
+			}
+			continue
+		}
+		for groups := header >> 1; groups > 0; groups-- { // Bit-packed groups of 8 values
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("truncated definition levels")
+			}
+			for bit := 0; bit < 8 && len(levels) < n; bit++ {
+				levels = append(levels, int(b>>bit)&1)
+			}
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	articleIDPattern       = regexp.MustCompile(`article_id=(\d+)`)
+	naverOfficePathPattern = regexp.MustCompile(`/(?:mnews/)?article/(\d{3})/\d+`)
+	officeIDPattern        = regexp.MustCompile(`office_id=(\d+)`)
+)
+
+// parseNaverNewsList extracts the news items of a Naver Finance news list page.
+// The returned articles carry the list fields only (title, summary, source, URL);
+// Content is pre-filled with the summary until the full body is fetched.
+// Items missing a required element are logged, recorded in health (which may be nil) and skipped.
+func parseNaverNewsList(doc *goquery.Document, articleBaseURL string, health *ParserHealth) []NewsArticle {
+	articles := []NewsArticle{}
+	doc.Find("ul.newsList li").Each(func(i int, s_item *goquery.Selection) {
+		// Extract data from each news item
+		titleTag := s_item.Find("dd.articleSubject a")
+		summaryDdTag := s_item.Find("dd.articleSummary")
+
+		title := strings.TrimSpace(titleTag.Text())
+		originalLink, _ := titleTag.Attr("href")
+
+		var summaryText string
+		var sourceText string
+
+		if summaryDdTag.Length() > 0 {
+			sourceSpan := summaryDdTag.Find("span.press")
+			if sourceSpan.Length() > 0 {
+				sourceText = strings.TrimSpace(sourceSpan.Text())
+				sourceSpan.Remove()
+			}
+			summaryDdTag.Find("span.wdate, span.bar").Remove()
+			summaryText = strings.TrimSpace(summaryDdTag.Text())
+		}
+
+		// Validate extracted data
+		health.RecordItem(s_item, title == "", summaryText == "", sourceText == "", originalLink == "")
+		if title == "" || summaryText == "" || sourceText == "" || originalLink == "" {
+			itemHtml, _ := goquery.OuterHtml(s_item)
+			health.logger().Warn("Missing required news elements (title, summary, source, link)", LogKeyStage, StageParse, "item_html", itemHtml)
+			return
+		}
+
+		article := NewsArticle{
+			Title:   cleanUTF8String(title),
+			Summary: cleanUTF8String(summaryText),
+			Content: cleanUTF8String(summaryText),
+			Source:  cleanUTF8String(sourceText),
+			URL:     cleanUTF8String(naverArticleURL(originalLink, articleBaseURL)),
+		}
+		// The list thumbnail is the lead image until the article page provides a better one.
+		if thumb, ok := s_item.Find("dt.thumb img").Attr("src"); ok && strings.TrimSpace(thumb) != "" {
+			article.LeadImageURL = resolveURL(strings.TrimSpace(thumb), "https://finance.naver.com/")
+		}
+		articles = append(articles, article)
+	})
+	return articles
+}
+
+// naverArticleURL reconstructs the n.news.naver.com URL of the full article from a list link.
+// Links without article_id or office_id are resolved against finance.naver.com instead.
+func naverArticleURL(originalLink, articleBaseURL string) string {
+	articleIDMatch := articleIDPattern.FindStringSubmatch(originalLink)
+	officeIDMatch := officeIDPattern.FindStringSubmatch(originalLink)
+
+	if len(articleIDMatch) > 1 && len(officeIDMatch) > 1 {
+		return fmt.Sprintf("%s/%s/%s", articleBaseURL, officeIDMatch[1], articleIDMatch[1])
+	}
+	componentLogger(LogComponentCrawler).Warn("Could not extract article_id or office_id", LogKeyStage, StageParse, "link", originalLink)
+	return "https://finance.naver.com" + originalLink
+}
+
+// parseNaverArticleBody extracts the content blocks of a n.news.naver.com article page.
+// It returns false when the body element (article#dic_area) is missing.
+func parseNaverArticleBody(doc *goquery.Document) ([]ContentBlock, bool) {
+	contentDiv := doc.Find("article#dic_area")
+	if contentDiv.Length() == 0 {
+		return nil, false
+	}
+	contentDiv.Find("script, iframe, .link_text, .byline, .reporter_area").Remove()
+	return extractBlocks(contentDiv), true
+}
+
+// officeIDFromURL returns the Naver press office ID of an article URL
+// (n.news.naver.com/mnews/article/{office}/{article} or office_id=...), or "".
+func officeIDFromURL(articleURL string) string {
+	if m := naverOfficePathPattern.FindStringSubmatch(articleURL); m != nil && strings.Contains(articleURL, "naver.com") {
+		return m[1]
+	}
+	if m := officeIDPattern.FindStringSubmatch(articleURL); len(m) > 1 {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Extraction strategies recorded on NewsArticle.ExtractionMethod.
+const (
+	ExtractionSelector    = "selector"    // Site-specific body selector
+	ExtractionReadability = "readability" // Generic main-content extractor
+	ExtractionSummary     = "summary"     // No body found; the list summary / feed description is the content
+)
+
+// Thresholds of the generic main-content extractor.
+const (
+	readabilityMinParagraphLength = 25  // Shorter text blocks are ignored when scoring
+	readabilityMinContentLength   = 140 // Shorter results are rejected as boilerplate
+)
+
+var (
+	// Elements that never hold article text.
+	readabilityStripTags = "script, style, noscript, iframe, form, button, input, select, textarea, nav, header, footer, aside, svg, canvas, object, embed"
+
+	readabilityNegativePattern = regexp.MustCompile(`(?i)comment|footer|sidebar|side[_-]|sponsor|(^|[\s_-])ads?([\s_-]|$)|advert|banner|share|sns|social|related|recommend|popular|ranking|menu|\bnav\b|gnb|lnb|breadcrumb|copyright|byline|reporter|subscribe|newsletter|promo|outbrain|taboola`)
+	readabilityPositivePattern = regexp.MustCompile(`(?i)article|content|body|entry|main|news|post|story|text|view|detail`)
+)
+
+// extractMainContent finds the main text of an arbitrary article page by scoring DOM nodes
+// on text density (paragraph length, punctuation) and penalizing link-heavy boilerplate,
+// in the spirit of Arc90's Readability. It returns false when no convincing block is found.
+func extractMainContent(doc *goquery.Document) ([]ContentBlock, bool) {
+	root := doc.Selection.Clone()
+	root.Find(readabilityStripTags).Remove()
+	root.Find("*").Each(func(i int, sel *goquery.Selection) {
+		if isBoilerplate(sel) {
+			sel.Remove()
+		}
+	})
+
+	scores := map[*html.Node]float64{}
+	var candidates []*goquery.Selection
+	root.Find("p, td, pre, div, article, section").Each(func(i int, sel *goquery.Selection) {
+		// Only score the innermost text holders; containers are credited through their children.
+		if goquery.NodeName(sel) != "p" && sel.Find("p, div, article, section").Length() > 0 {
+			return
+		}
+		text := normalizeSpace(sel.Text())
+		length := utf8.RuneCountInString(text)
+		if length < readabilityMinParagraphLength {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(length)/100, 3)
+
+		parent := sel.Parent()
+		grandparent := parent.Parent()
+		for level, ancestor := range []*goquery.Selection{parent, grandparent} {
+			if ancestor.Length() == 0 {
+				continue
+			}
+			node := ancestor.Get(0)
+			if _, seen := scores[node]; !seen {
+				scores[node] = classWeight(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+			scores[node] += score / float64(level+1)
+		}
+	})
+
+	var best *goquery.Selection
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		score := scores[candidate.Get(0)] * (1 - linkDensity(candidate))
+		if best == nil || score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+
+	blocks := extractBlocks(best)
+	if utf8.RuneCountInString(blocksToText(blocks)) < readabilityMinContentLength {
+		return nil, false
+	}
+	return blocks, true
+}
+
+// isBoilerplate reports whether an element's class or id marks it as page chrome.
+func isBoilerplate(sel *goquery.Selection) bool {
+	switch goquery.NodeName(sel) {
+	case "html", "body", "article", "main":
+		return false
+	}
+	class, _ := sel.Attr("class")
+	id, _ := sel.Attr("id")
+	hint := class + " " + id
+	return readabilityNegativePattern.MatchString(hint) && !readabilityPositivePattern.MatchString(hint)
+}
+
+// classWeight gives a head start to nodes whose class or id look like article content.
+func classWeight(sel *goquery.Selection) float64 {
+	class, _ := sel.Attr("class")
+	id, _ := sel.Attr("id")
+	weight := 0.0
+	for _, hint := range []string{class, id} {
+		if hint == "" {
+			continue
+		}
+		if readabilityPositivePattern.MatchString(hint) {
+			weight += 25
+		}
+		if readabilityNegativePattern.MatchString(hint) {
+			weight -= 25
+		}
+	}
+	if goquery.NodeName(sel) == "article" {
+		weight += 10
+	}
+	return weight
+}
+
+// linkDensity is the share of an element's text that sits inside links.
+func linkDensity(sel *goquery.Selection) float64 {
+	total := utf8.RuneCountInString(normalizeSpace(sel.Text()))
+	if total == 0 {
+		return 1
+	}
+	linked := 0
+	sel.Find("a").Each(func(i int, a *goquery.Selection) {
+		linked += utf8.RuneCountInString(normalizeSpace(a.Text()))
+	})
+	return float64(linked) / float64(total)
+}
+
+// normalizeSpace collapses runs of whitespace into single spaces and trims the result.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
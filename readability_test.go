@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractMainContentGolden(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "pages", "outlet_article.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, ok := extractMainContent(doc)
+	content := blocksToText(blocks)
+	if !ok {
+		t.Fatal("expected the generic extractor to find the article body")
+	}
+	for _, boilerplate := range []string{"많이 본 뉴스", "관련기사", "댓글", "대표전화", "카카오톡"} {
+		if strings.Contains(content, boilerplate) {
+			t.Errorf("extracted content contains boilerplate %q", boilerplate)
+		}
+	}
+	assertGolden(t, "readability_outlet_article", blocks)
+}
+
+func TestExtractMainContentRejectsPagesWithoutArticle(t *testing.T) {
+	doc, err := newReplayFetcher(t).FetchDocument("https://n.news.naver.com/mnews/article/009/0005312002", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks, ok := extractMainContent(doc); ok {
+		t.Errorf("expected no content from an error page, got %v", blocks)
+	}
+}
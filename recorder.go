@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Modes supported by the RecordingTransport.
+const (
+	FixtureModeRecord = "record" // Forward requests to the network and store every exchange
+	FixtureModeReplay = "replay" // Serve stored exchanges only, never touching the network
+)
+
+// recordedExchange is the on-disk metadata of a recorded HTTP exchange.
+// The response body is stored next to it, byte for byte, in a ".body" file.
+type recordedExchange struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+}
+
+// RecordingTransport is an http.RoundTripper that records live exchanges to fixture files
+// and replays them deterministically.
+type RecordingTransport struct {
+	Dir  string
+	Mode string
+	Next http.RoundTripper // Transport used in record mode; nil means http.DefaultTransport
+}
+
+// NewRecordingTransport creates a RecordingTransport storing its fixtures in dir.
+func NewRecordingTransport(dir, mode string, next http.RoundTripper) (*RecordingTransport, error) {
+	if mode != FixtureModeRecord && mode != FixtureModeReplay {
+		return nil, fmt.Errorf("unknown fixture mode %q (expected %q or %q)", mode, FixtureModeRecord, FixtureModeReplay)
+	}
+	return &RecordingTransport{Dir: dir, Mode: mode, Next: next}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Mode == FixtureModeRecord {
+		return t.record(req)
+	}
+	return t.replay(req)
+}
+
+// fixtureName returns the base file name used for the exchange of req.
+// It is a readable slug of the URL followed by a short hash to keep names unique.
+func fixtureName(method, rawURL string) string {
+	sum := sha1.Sum([]byte(method + " " + rawURL))
+	slug := strings.NewReplacer("https://", "", "http://", "").Replace(rawURL)
+	slug = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, slug)
+	if len(slug) > 80 {
+		slug = slug[:80]
+	}
+	return fmt.Sprintf("%s_%s", slug, hex.EncodeToString(sum[:])[:8])
+}
+
+func (t *RecordingTransport) record(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body to record: %v", err)
+	}
+
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating fixture directory: %v", err)
+	}
+	name := fixtureName(req.Method, req.URL.String())
+	meta, err := json.MarshalIndent(recordedExchange{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding fixture metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(t.Dir, name+".json"), meta, 0o644); err != nil {
+		return nil, fmt.Errorf("error writing fixture metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(t.Dir, name+".body"), body, 0o644); err != nil {
+		return nil, fmt.Errorf("error writing fixture body: %v", err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func (t *RecordingTransport) replay(req *http.Request) (*http.Response, error) {
+	name := fixtureName(req.Method, req.URL.String())
+	meta, err := os.ReadFile(filepath.Join(t.Dir, name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded exchange for %s %s: %v", req.Method, req.URL, err)
+	}
+	var exchange recordedExchange
+	if err := json.Unmarshal(meta, &exchange); err != nil {
+		return nil, fmt.Errorf("error decoding fixture %s: %v", name, err)
+	}
+	body, err := os.ReadFile(filepath.Join(t.Dir, name+".body"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture body %s: %v", name, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.StatusCode, http.StatusText(exchange.StatusCode)),
+		StatusCode:    exchange.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        exchange.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
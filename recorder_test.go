@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecordingTransportRecordThenReplay(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>recorded</body></html>"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecordingTransport(dir, FixtureModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: recorder}).Get(server.URL + "/news?page=1")
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	recorded, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	server.Close()
+	replayer, err := NewRecordingTransport(dir, FixtureModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = (&http.Client{Transport: replayer}).Get(server.URL + "/news?page=1")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	replayed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if hits != 1 {
+		t.Errorf("server hit %d times, want 1", hits)
+	}
+	if string(replayed) != string(recorded) {
+		t.Errorf("replayed body %q, want %q", replayed, recorded)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("replayed Content-Type %q", got)
+	}
+
+	if _, err := (&http.Client{Transport: replayer}).Get(server.URL + "/news?page=2"); err == nil {
+		t.Error("expected an error for an exchange that was never recorded")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// restartOnlyKeys are the config keys applied when the service starts: a reload keeps their
+// running values and reports them as requiring a restart.
+var restartOnlyKeys = []string{
+	"logFormat", "tracing", "articleStore", "firebaseServiceAccountKeyPath", "eventReplaySize",
+	"mediaStore", "mediaDir", "mediaBaseUrl", "thumbnailWidth", "maxImageBytes",
+	"webhookMaxAttempts", "scheduleStateFile", "port", "corsAllowOrigins", "auth",
+}
+
+// ReloadStatus describes the configuration in use and the last reload attempt.
+type ReloadStatus struct {
+	ConfigFile      string     `json:"configFile"`
+	LoadedAt        time.Time  `json:"loadedAt"` // When the configuration in use was loaded
+	LastAttemptAt   *time.Time `json:"lastAttemptAt,omitempty"`
+	LastTrigger     string     `json:"lastTrigger,omitempty"`     // What started the last attempt (file change, SIGHUP, api)
+	Errors          []string   `json:"errors,omitempty"`          // Why the last attempt was rejected
+	RestartRequired []string   `json:"restartRequired,omitempty"` // Changed keys that only apply after a restart
+}
+
+// ConfigReloader reloads the service configuration, with its declarative sources and
+// watchlists, when their files change or the process receives SIGHUP. An invalid new
+// configuration is rejected as a whole and the running one is kept.
+type ConfigReloader struct {
+	Source   ConfigSource
+	Service  *NewsCrawlerService
+	OnReload func(cfg *Config) // Applies the reloaded configuration beyond the service, if set
+
+	mu     sync.Mutex
+	status ReloadStatus
+	stamp  string // Fingerprint of the watched files when last loaded
+}
+
+// NewConfigReloader creates a reloader for the configuration the service was started with.
+func NewConfigReloader(src ConfigSource, s *NewsCrawlerService) *ConfigReloader {
+	path, _ := src.path()
+	cfg := s.Snapshot().Config
+	return &ConfigReloader{
+		Source:  src,
+		Service: s,
+		status:  ReloadStatus{ConfigFile: path, LoadedAt: time.Now()},
+		stamp:   watchedFilesStamp(path, cfg),
+	}
+}
+
+// Status returns the reload status.
+func (r *ConfigReloader) Status() ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Reload loads the configuration, the source definitions and the watchlists again and swaps
+// them into the service. On error nothing is swapped.
+func (r *ConfigReloader) Reload(trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.status.LastAttemptAt = &now
+	r.status.LastTrigger = trigger
+	path, _ := r.Source.path()
+	stamp := watchedFilesStamp(path, r.Service.Snapshot().Config)
+
+	cfg, sources, watchlists, err := loadReloadable(r.Source)
+	// A rejected change is not retried until the files change again.
+	r.stamp = stamp
+	if err != nil {
+		r.status.Errors = strings.Split(err.Error(), "\n")
+		componentLogger(LogComponentConfig).Warn("Configuration reload rejected, keeping the running configuration", "trigger", trigger, "errors", r.status.Errors)
+		return err
+	}
+
+	running := r.Service.Snapshot().Config
+	restart := keepRestartOnlyKeys(cfg, running)
+	r.Service.Reload(cfg, sources, watchlists)
+	if r.OnReload != nil {
+		r.OnReload(cfg)
+	}
+	r.stamp = watchedFilesStamp(path, cfg)
+	r.status.Errors = nil
+	r.status.LoadedAt = now
+	r.status.RestartRequired = restart
+	rules := 0
+	if watchlists != nil {
+		rules = len(watchlists.Rules)
+	}
+	componentLogger(LogComponentConfig).Info("Configuration reloaded", "trigger", trigger, "sources", len(sources), "watchlist_rules", rules)
+	if len(restart) > 0 {
+		componentLogger(LogComponentConfig).Warn("Some changes only apply after a restart", "keys", restart)
+	}
+	return nil
+}
+
+// loadReloadable loads the reloadable settings: the configuration, and the source
+// definitions and watchlists it names.
+func loadReloadable(src ConfigSource) (*Config, []*SourceDefinition, *Watchlists, error) {
+	cfg, err := LoadConfig(src)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sources, err := LoadSourceDefinitions(cfg.SourcesDir)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("sources: %v", err)
+	}
+	watchlists, err := LoadWatchlists(cfg.WatchlistsFile, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("watchlists: %v", err)
+	}
+	return cfg, sources, watchlists, nil
+}
+
+// keepRestartOnlyKeys resets the restart-only keys of cfg to their running values and
+// returns those that differed.
+func keepRestartOnlyKeys(cfg, running *Config) []string {
+	var changed []string
+	next, current := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(running).Elem()
+	for i := 0; i < next.NumField(); i++ {
+		key := strings.Split(next.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		for _, restartOnly := range restartOnlyKeys {
+			if key == restartOnly && !reflect.DeepEqual(next.Field(i).Interface(), current.Field(i).Interface()) {
+				changed = append(changed, key)
+				next.Field(i).Set(current.Field(i))
+			}
+		}
+	}
+	return changed
+}
+
+// watchedFilesStamp fingerprints the files a reload reads (size and modification time):
+// the config file, the source definitions and the watchlists.
+func watchedFilesStamp(configPath string, cfg *Config) string {
+	paths := []string{configPath, cfg.WatchlistsFile}
+	if entries, err := os.ReadDir(cfg.SourcesDir); err == nil {
+		for _, entry := range entries {
+			paths = append(paths, filepath.Join(cfg.SourcesDir, entry.Name()))
+		}
+	}
+	var b strings.Builder
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&b, "%s:-;", path)
+		}
+	}
+	return b.String()
+}
+
+// Run reloads the configuration on SIGHUP, and when the watched files change (checked every
+// reloadInterval), until ctx is done.
+func (r *ConfigReloader) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		var tick <-chan time.Time
+		if interval := r.Service.Snapshot().Config.ReloadInterval; interval > 0 {
+			tick = time.After(interval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.Reload("SIGHUP")
+		case <-tick:
+			path, _ := r.Source.path()
+			r.mu.Lock()
+			changed := watchedFilesStamp(path, r.Service.Snapshot().Config) != r.stamp
+			r.mu.Unlock()
+			if changed {
+				r.Reload("file change")
+			}
+		}
+	}
+}
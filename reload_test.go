@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("articleStore: memory\nsourcesDir: " + filepath.Join(dir, "sources") + "\nwatchlistsFile: " + filepath.Join(dir, "watchlists.yaml") + "\narticleDelay: 1s\n")
+	src := ConfigSource{File: path}
+	cfg, err := LoadConfig(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewNewsCrawlerService(cfg)
+	reloader := NewConfigReloader(src, s)
+
+	// A crawl started before the reload keeps its settings.
+	job := s.Snapshot()
+	os.Mkdir(filepath.Join(dir, "sources"), 0755)
+	os.WriteFile(filepath.Join(dir, "sources", "example.yaml"), []byte(`
+id: example
+name: Example
+listUrl: "https://example.com/news?page={page}"
+item: li
+title:
+  selector: a
+link:
+  selector: a
+  attr: href
+`), 0644)
+	write("articleStore: memory\nsourcesDir: " + filepath.Join(dir, "sources") + "\nwatchlistsFile: " + filepath.Join(dir, "watchlists.yaml") + "\narticleDelay: 3s\nport: \"9000\"\n")
+	if err := reloader.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	current := s.Snapshot()
+	if current.Config.ArticleDelay != 3*time.Second || current.Sources["example"] == nil {
+		t.Errorf("reloaded: articleDelay %v, sources %v", current.Config.ArticleDelay, current.Sources)
+	}
+	if job.Config.ArticleDelay != time.Second || job.Sources["example"] != nil {
+		t.Error("the reload changed a running crawl's snapshot")
+	}
+	if current.Config.Port != "8888" || len(reloader.Status().RestartRequired) != 1 {
+		t.Errorf("port %s, restart required %v", current.Config.Port, reloader.Status().RestartRequired)
+	}
+
+	// An invalid configuration is rejected and the running one kept.
+	write("articleStore: memory\narticleDelay: -1s\nthumbnailWidth: 0\n")
+	if err := reloader.Reload("test"); err == nil {
+		t.Fatal("invalid configuration accepted")
+	}
+	if s.Snapshot().Config.ArticleDelay != 3*time.Second {
+		t.Error("invalid configuration swapped in")
+	}
+	if status := reloader.Status(); len(status.Errors) != 2 || !status.LastAttemptAt.After(status.LoadedAt) {
+		t.Errorf("status after rejected reload: %+v", status)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// What started a crawl run (CrawlRun.Trigger).
+const (
+	RunTriggerScheduler = "scheduler" // Built-in schedule
+	RunTriggerManual    = "manual"    // POST /api/schedule/crawl
+	RunTriggerCLI       = "cli"       // crawl and backfill commands
+)
+
+// Outcomes of a crawl run (CrawlRun.Outcome).
+const (
+	RunOutcomeRunning     = "running"
+	RunOutcomeOK          = "ok"
+	RunOutcomeEmpty       = "empty"       // Finished without errors, but saved no article
+	RunOutcomeDegraded    = "degraded"    // Parser health crossed its thresholds (see ParserDegradedError)
+	RunOutcomeFailed      = "failed"      // The first list page (or every feed) could not be fetched
+	RunOutcomeInterrupted = "interrupted" // Stopped by a shutdown before finishing
+)
+
+// maxRunErrors is the number of errors kept in a run record; later ones are only counted.
+const maxRunErrors = 20
+
+// Run listing page sizes.
+const (
+	DefaultRunPageSize = 50
+	MaxRunPageSize     = 200
+)
+
+// JobInfo identifies a crawl job, for its logs, traces and run records.
+type JobInfo struct {
+	ID       string // Request ID of an HTTP-triggered crawl, else generated (newJobID)
+	Trigger  string // RunTriggerScheduler, RunTriggerManual or RunTriggerCLI
+	Schedule string // Name of the schedule of a scheduled crawl
+}
+
+// CrawlRun is the record of a crawl run of one source: what started it, what it fetched and
+// saved page by page, a sample of its errors and its outcome.
+type CrawlRun struct {
+	ID           string     `firestore:"id" json:"id"`
+	JobID        string     `firestore:"jobId" json:"jobId"` // Shared by the runs of a job (e.g. the days of a backfill), and in the logs
+	Source       string     `firestore:"source" json:"source"`
+	Trigger      string     `firestore:"trigger" json:"trigger"`
+	Schedule     string     `firestore:"schedule,omitempty" json:"schedule,omitempty"`
+	Params       RunParams  `firestore:"params" json:"params"`
+	StartedAt    time.Time  `firestore:"startedAt" json:"startedAt"`
+	FinishedAt   time.Time  `firestore:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	Outcome      string     `firestore:"outcome" json:"outcome"`
+	Error        string     `firestore:"error,omitempty" json:"error,omitempty"` // Why the run failed or is degraded
+	ParserStatus string     `firestore:"parserStatus,omitempty" json:"parserStatus,omitempty"`
+	Discovered   int        `firestore:"discovered" json:"discovered"` // Articles listed on the pages
+	New          int        `firestore:"new" json:"new"`               // Articles saved
+	Skipped      int        `firestore:"skipped" json:"skipped"`       // Already stored, or the existence check failed
+	Failed       int        `firestore:"failed" json:"failed"`         // Could not be saved
+	Pages        []RunPage  `firestore:"pages" json:"pages"`
+	Errors       []RunError `firestore:"errors,omitempty" json:"errors,omitempty"` // The first maxRunErrors errors
+	ErrorCount   int        `firestore:"errorCount" json:"errorCount"`
+}
+
+// MarshalJSON omits the FinishedAt of a run in progress, which the omitempty option cannot do
+// for a time.Time.
+func (r CrawlRun) MarshalJSON() ([]byte, error) {
+	type run CrawlRun // Without this method
+	var finishedAt *time.Time
+	if !r.FinishedAt.IsZero() {
+		finishedAt = &r.FinishedAt
+	}
+	return json.Marshal(struct {
+		run
+		FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	}{run(r), finishedAt})
+}
+
+// RunParams are the parameters a crawl run was started with.
+type RunParams struct {
+	Pages int    `firestore:"pages,omitempty" json:"pages,omitempty"` // List pages requested (not for feed sources)
+	Day   string `firestore:"day,omitempty" json:"day,omitempty"`     // Day of a backfill run (YYYY-MM-DD, Asia/Seoul)
+}
+
+// RunPage counts the articles of a list page or feed.
+type RunPage struct {
+	Page       string `firestore:"page" json:"page"` // Page number, or feed URL
+	URL        string `firestore:"url" json:"url"`
+	Fetched    bool   `firestore:"fetched" json:"fetched"`
+	Discovered int    `firestore:"discovered" json:"discovered"`
+	New        int    `firestore:"new" json:"new"`
+	Skipped    int    `firestore:"skipped" json:"skipped"`
+	Failed     int    `firestore:"failed" json:"failed"`
+}
+
+// RunError is an error of a crawl run.
+type RunError struct {
+	At      time.Time `firestore:"at" json:"at"`
+	Stage   string    `firestore:"stage" json:"stage"` // StageList, StageArticle, StageParse or StageStore
+	URL     string    `firestore:"url" json:"url"`
+	Message string    `firestore:"message" json:"message"`
+}
+
+// newCrawlRun starts the record of a crawl run of source for job.
+func newCrawlRun(job JobInfo, source string, params RunParams) *CrawlRun {
+	started := time.Now()
+	return &CrawlRun{
+		ID:        started.UTC().Format("20060102T150405Z") + "-" + newJobID()[:8], // Sorts by start time
+		JobID:     job.ID,
+		Source:    source,
+		Trigger:   job.Trigger,
+		Schedule:  job.Schedule,
+		Params:    params,
+		StartedAt: started,
+		Outcome:   RunOutcomeRunning,
+		Pages:     []RunPage{},
+	}
+}
+
+// startPage records the request of a list page or feed, and counts it in the metrics.
+// The articles counted next belong to it.
+func (r *CrawlRun) startPage(page, pageURL string, err error) {
+	observePageFetch(r.Source, err)
+	r.Pages = append(r.Pages, RunPage{Page: page, URL: pageURL, Fetched: err == nil})
+	if err != nil {
+		r.recordError(StageList, pageURL, err)
+	}
+}
+
+// countArticles counts n articles of the current page with an outcome (ArticleOutcomeDiscovered,
+// ...), in the record and in the metrics.
+func (r *CrawlRun) countArticles(outcome string, n int) {
+	observeArticles(r.Source, outcome, n)
+	var page RunPage
+	if len(r.Pages) > 0 {
+		page = r.Pages[len(r.Pages)-1]
+	}
+	switch outcome {
+	case ArticleOutcomeDiscovered:
+		r.Discovered += n
+		page.Discovered += n
+	case ArticleOutcomeNew:
+		r.New += n
+		page.New += n
+	case ArticleOutcomeSkipped:
+		r.Skipped += n
+		page.Skipped += n
+	case ArticleOutcomeFailed:
+		r.Failed += n
+		page.Failed += n
+	}
+	if len(r.Pages) > 0 {
+		r.Pages[len(r.Pages)-1] = page
+	}
+}
+
+// recordError records an error of the run, keeping the first maxRunErrors.
+func (r *CrawlRun) recordError(stage, url string, err error) {
+	r.ErrorCount++
+	if len(r.Errors) < maxRunErrors {
+		r.Errors = append(r.Errors, RunError{At: time.Now(), Stage: stage, URL: url, Message: err.Error()})
+	}
+}
+
+// finish completes the record with the outcome of the run: err is the error returned by the crawl.
+func (r *CrawlRun) finish(health *ParserHealth, err error) {
+	r.FinishedAt = time.Now()
+	r.ParserStatus = health.Status
+	switch {
+	case errors.Is(err, errCrawlInterrupted):
+		r.Outcome = RunOutcomeInterrupted
+	case health.Status == ParserStatusDegraded:
+		r.Outcome = RunOutcomeDegraded
+	case err != nil:
+		r.Outcome = RunOutcomeFailed
+	case r.New == 0:
+		r.Outcome = RunOutcomeEmpty
+	default:
+		r.Outcome = RunOutcomeOK
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// startRun starts the record of a crawl run of the source of health and saves it, so that runs
+// that never finish show up as running. health gets the run ID.
+func (s *NewsCrawlerService) startRun(ctx context.Context, health *ParserHealth, params RunParams) *CrawlRun {
+	run := newCrawlRun(s.job, health.Source, params)
+	health.RunID = run.ID
+	s.saveRun(ctx, run)
+	return run
+}
+
+// saveRun saves a run record, if the run history is enabled. Failures are logged and never
+// fail the crawl.
+func (s *NewsCrawlerService) saveRun(ctx context.Context, run *CrawlRun) {
+	if s.Runs == nil {
+		return
+	}
+	if err := s.Runs.SaveRun(ctx, *run); err != nil {
+		s.logger().Warn("Failed to save the crawl run record", "run_id", run.ID, LogKeySource, run.Source, LogKeyError, err)
+	}
+}
+
+// RunStore persists the crawl run history.
+type RunStore interface {
+	// SaveRun creates or replaces a run record.
+	SaveRun(ctx context.Context, run CrawlRun) error
+	// GetRun returns a run record, or nil when there is none.
+	GetRun(ctx context.Context, id string) (*CrawlRun, error)
+	// ListRuns returns the runs matching q, most recently started first.
+	ListRuns(ctx context.Context, q RunQuery) ([]CrawlRun, error)
+}
+
+// RunQuery filters a run listing. Zero fields do not filter.
+type RunQuery struct {
+	Source   string
+	Trigger  string
+	Outcome  string
+	From, To time.Time // startedAt range: From inclusive, To exclusive (pass the startedAt of the last run to get the next page)
+	Limit    int       // DefaultRunPageSize when 0, capped at MaxRunPageSize
+}
+
+// Validate checks the query values and applies the default page size.
+func (q *RunQuery) Validate() error {
+	switch q.Trigger {
+	case "", RunTriggerScheduler, RunTriggerManual, RunTriggerCLI:
+	default:
+		return fmt.Errorf("invalid trigger %q (expected %s, %s or %s)", q.Trigger, RunTriggerScheduler, RunTriggerManual, RunTriggerCLI)
+	}
+	switch q.Outcome {
+	case "", RunOutcomeRunning, RunOutcomeOK, RunOutcomeEmpty, RunOutcomeDegraded, RunOutcomeFailed, RunOutcomeInterrupted:
+	default:
+		return fmt.Errorf("invalid outcome %q (expected %s, %s, %s, %s, %s or %s)", q.Outcome, RunOutcomeRunning, RunOutcomeOK, RunOutcomeEmpty, RunOutcomeDegraded, RunOutcomeFailed, RunOutcomeInterrupted)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return fmt.Errorf("invalid date range: from must be before to")
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultRunPageSize
+	}
+	if q.Limit > MaxRunPageSize {
+		q.Limit = MaxRunPageSize
+	}
+	return nil
+}
+
+// Matches reports whether run satisfies the filters of q.
+func (q *RunQuery) Matches(run *CrawlRun) bool {
+	return (q.Source == "" || run.Source == q.Source) &&
+		(q.Trigger == "" || run.Trigger == q.Trigger) &&
+		(q.Outcome == "" || run.Outcome == q.Outcome) &&
+		(q.From.IsZero() || !run.StartedAt.Before(q.From)) &&
+		(q.To.IsZero() || run.StartedAt.Before(q.To))
+}
+
+// runsCollection is the Firestore collection of the run history.
+const runsCollection = "crawlRuns"
+
+// FirestoreRunStore is the RunStore backed by the crawlRuns Firestore collection.
+type FirestoreRunStore struct {
+	FirestoreArticleStore // Shares the client setup
+}
+
+// SaveRun implements RunStore.
+func (s *FirestoreRunStore) SaveRun(ctx context.Context, run CrawlRun) error {
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.Collection(runsCollection).Doc(run.ID).Set(ctx, run); err != nil {
+		return fmt.Errorf("error saving crawl run: %v", err)
+	}
+	return nil
+}
+
+// GetRun implements RunStore.
+func (s *FirestoreRunStore) GetRun(ctx context.Context, id string) (*CrawlRun, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	doc, err := client.Collection(runsCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading crawl run: %v", err)
+	}
+	var run CrawlRun
+	if err := doc.DataTo(&run); err != nil {
+		return nil, fmt.Errorf("error decoding crawl run %s: %v", id, err)
+	}
+	return &run, nil
+}
+
+// ListRuns implements RunStore. The filters run as an indexed query (see firestore.indexes.json).
+func (s *FirestoreRunStore) ListRuns(ctx context.Context, q RunQuery) ([]CrawlRun, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	query := client.Collection(runsCollection).Query
+	for field, value := range map[string]string{"source": q.Source, "trigger": q.Trigger, "outcome": q.Outcome} {
+		if value != "" {
+			query = query.Where(field, "==", value)
+		}
+	}
+	if !q.From.IsZero() {
+		query = query.Where("startedAt", ">=", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("startedAt", "<", q.To)
+	}
+	docs, err := query.OrderBy("startedAt", firestore.Desc).Limit(q.Limit).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error listing crawl runs from Firestore: %v", err)
+	}
+	runs := make([]CrawlRun, 0, len(docs))
+	for _, doc := range docs {
+		var run CrawlRun
+		if err := doc.DataTo(&run); err != nil {
+			componentLogger(LogComponentStore).Warn("Failed to decode crawl run", "doc_id", doc.Ref.ID, LogKeyError, err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// memoryRunStoreSize is the number of runs kept by a MemoryRunStore.
+const memoryRunStoreSize = 1000
+
+// MemoryRunStore is an in-process RunStore (ARTICLE_STORE=memory and tests), keeping the
+// latest memoryRunStoreSize runs.
+type MemoryRunStore struct {
+	mu   sync.Mutex
+	runs map[string]CrawlRun
+}
+
+// NewMemoryRunStore creates an empty MemoryRunStore.
+func NewMemoryRunStore() *MemoryRunStore {
+	return &MemoryRunStore{runs: map[string]CrawlRun{}}
+}
+
+// SaveRun implements RunStore.
+func (s *MemoryRunStore) SaveRun(ctx context.Context, run CrawlRun) error {
+	// The crawl keeps updating its record: store a copy of its slices.
+	run.Pages = append([]RunPage(nil), run.Pages...)
+	run.Errors = append([]RunError(nil), run.Errors...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.ID] = run
+	if len(s.runs) > memoryRunStoreSize {
+		oldest := run
+		for _, r := range s.runs {
+			if r.StartedAt.Before(oldest.StartedAt) {
+				oldest = r
+			}
+		}
+		delete(s.runs, oldest.ID)
+	}
+	return nil
+}
+
+// GetRun implements RunStore.
+func (s *MemoryRunStore) GetRun(ctx context.Context, id string) (*CrawlRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+// ListRuns implements RunStore.
+func (s *MemoryRunStore) ListRuns(ctx context.Context, q RunQuery) ([]CrawlRun, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	runs := []CrawlRun{}
+	for _, run := range s.runs {
+		if q.Matches(&run) {
+			runs = append(runs, run)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if len(runs) > q.Limit {
+		runs = runs[:q.Limit]
+	}
+	return runs, nil
+}
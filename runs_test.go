@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCrawlRunRecord(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>test</title>
+<item><title>첫 기사</title><link>http://%[1]s/a/1</link><description>요약 1</description></item>
+<item><title>둘째 기사</title><link>http://%[1]s/a/2</link><description>요약 2</description></item>
+</channel></rss>`, r.Host)
+		case "/a/1":
+			fmt.Fprint(w, `<html><body><article><p>본문입니다.</p></article></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+
+	cfg := DefaultConfig()
+	cfg.ArticleStore = "memory"
+	cfg.ArticleDelay, cfg.ArticleRetryDelay = 0, 0
+	s := NewNewsCrawlerService(cfg)
+	s.Store = NewMemoryArticleStore()
+	s.Runs = NewMemoryRunStore()
+	def := &SourceDefinition{ID: "runs-test", Type: SourceTypeFeed, Feeds: []FeedConfig{
+		{URL: site.URL + "/feed", Publisher: "테스트", Body: FieldSelector{Selector: "article"}},
+		{URL: site.URL + "/missing-feed", Publisher: "테스트"},
+	}}
+
+	job := s.Job(context.Background(), JobInfo{ID: "job-1", Trigger: RunTriggerCLI})
+	if _, err := job.CrawlSource(def, 1); err != nil {
+		t.Fatal(err)
+	}
+	first := job.LastRun()
+	if first == nil {
+		t.Fatal("no run recorded")
+	}
+	if first.JobID != "job-1" || first.Trigger != RunTriggerCLI || first.Source != "runs-test" || first.Outcome != RunOutcomeOK || first.FinishedAt.IsZero() {
+		t.Errorf("first run: %+v", first)
+	}
+	if first.Discovered != 2 || first.New != 2 || len(first.Pages) != 2 || first.Pages[0].New != 2 || first.Pages[1].Fetched {
+		t.Errorf("first run counts: %+v", first)
+	}
+	// The missing feed and the missing article body are both sampled.
+	if first.ErrorCount != 2 || len(first.Errors) != 2 || first.Errors[0].Stage != StageArticle || first.Errors[1].Stage != StageList {
+		t.Errorf("first run errors: %d %+v", first.ErrorCount, first.Errors)
+	}
+
+	job = s.Job(context.Background(), JobInfo{ID: "job-2", Trigger: RunTriggerScheduler, Schedule: "nightly"})
+	if _, err := job.CrawlSource(def, 1); err != nil {
+		t.Fatal(err)
+	}
+	if second := job.LastRun(); second.Outcome != RunOutcomeEmpty || second.Skipped != 2 || second.New != 0 || second.Schedule != "nightly" {
+		t.Errorf("second run: %+v", second)
+	}
+
+	app := fiber.New()
+	app.Get("/api/runs", func(c *fiber.Ctx) error {
+		query, err := runQueryFromRequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		runs, err := s.Runs.ListRuns(c.Context(), query)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{"runs": runs})
+	})
+	for target, want := range map[string]int{
+		"/api/runs":                              2,
+		"/api/runs?outcome=empty":                1,
+		"/api/runs?trigger=cli&source=runs-test": 1,
+		"/api/runs?source=naver":                 0,
+		"/api/runs?limit=1":                      1,
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: %v, %v", target, resp, err)
+			continue
+		}
+		var body struct{ Runs []CrawlRun }
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Runs) != want {
+			t.Errorf("GET %s: %d runs, want %d", target, len(body.Runs), want)
+		} else if want == 2 && body.Runs[0].JobID != "job-2" {
+			t.Errorf("GET %s: runs not sorted by start time: %s first", target, body.Runs[0].JobID)
+		}
+	}
+	for _, target := range []string{"/api/runs?trigger=cron", "/api/runs?outcome=bad", "/api/runs?from=yesterday", "/api/runs?from=2024-05-02&to=2024-05-01"} {
+		if resp, err := app.Test(httptest.NewRequest("GET", target, nil)); err != nil || resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: %v, %v, want 400", target, resp, err)
+		}
+	}
+
+	if run, err := s.Runs.GetRun(context.Background(), first.ID); err != nil || run == nil || run.New != 2 {
+		t.Errorf("GetRun(%s) = %+v, %v", first.ID, run, err)
+	}
+	if run, err := s.Runs.GetRun(context.Background(), "missing"); err != nil || run != nil {
+		t.Errorf("GetRun(missing) = %+v, %v", run, err)
+	}
+}
+
+func TestRunningRunJSON(t *testing.T) {
+	run := newCrawlRun(JobInfo{ID: "job-1", Trigger: RunTriggerManual}, "naver-mainnews", RunParams{Pages: 1})
+	body, err := json.Marshal(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(body, &fields)
+	if _, ok := fields["finishedAt"]; ok || fields["outcome"] != RunOutcomeRunning || fields["startedAt"] == nil {
+		t.Errorf("running run: %s", body)
+	}
+
+	run.finish(&ParserHealth{Status: ParserStatusHealthy}, nil)
+	body, _ = json.Marshal(run)
+	var finished CrawlRun
+	if err := json.Unmarshal(body, &finished); err != nil || !finished.FinishedAt.Equal(run.FinishedAt) {
+		t.Errorf("finished run: %s, %v", body, err)
+	}
+}
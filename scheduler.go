@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Missed run policies of a schedule: what to do when a run time passed while the server was
+// stopped or suspended.
+const (
+	MissedRunsSkip    = "skip"    // Wait for the next run time
+	MissedRunsRunOnce = "runOnce" // Run once right away, however many runs were missed
+)
+
+// Statuses of a ScheduleRun.
+const (
+	ScheduleRunRunning  = "running"
+	ScheduleRunOK       = "ok"
+	ScheduleRunDegraded = "degraded" // The crawl finished with degraded parser health
+	ScheduleRunFailed   = "failed"
+	ScheduleRunSkipped  = "skipped" // Missed, or the source was still being crawled
+)
+
+// missedRunGrace is how late a run may start (e.g. after the host was suspended) before it
+// counts as missed.
+const missedRunGrace = time.Minute
+
+// cronParser parses the standard 5-field cron expressions and descriptors (@hourly, @every 5m).
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// loadScheduleLocation loads the time zone of the schedules. Asia/Seoul does not depend on
+// the system time zone database.
+func loadScheduleLocation(name string) (*time.Location, error) {
+	if name == "Asia/Seoul" {
+		return seoulLocation(), nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("time zone not set")
+	}
+	return time.LoadLocation(name)
+}
+
+// ScheduleRun is the outcome of a scheduled crawl.
+type ScheduleRun struct {
+	ScheduledAt time.Time  `json:"scheduledAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"` // After the jitter delay
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Status      string     `json:"status"`
+	Articles    int        `json:"articles"` // New articles saved
+	Error       string     `json:"error,omitempty"`
+	RunID       string     `json:"runId,omitempty"` // Record of the crawl run (GET /api/runs/{id})
+}
+
+// ScheduleState describes a schedule for GET /api/schedules.
+type ScheduleState struct {
+	Name       string       `json:"name"`
+	Cron       string       `json:"cron"`
+	Source     string       `json:"source"`
+	Pages      int          `json:"pages"`
+	Jitter     string       `json:"jitter,omitempty"`
+	MissedRuns string       `json:"missedRuns"`
+	NextRun    time.Time    `json:"nextRun"`
+	LastRun    *ScheduleRun `json:"lastRun,omitempty"`
+}
+
+type scheduledJob struct {
+	config   ScheduleConfig
+	schedule cron.Schedule
+	next     time.Time
+	lastRun  *ScheduleRun
+	running  bool
+}
+
+// Scheduler runs the crawls of the configured schedules in-process, for setups without an
+// external scheduler calling POST /api/schedule/crawl. Runs of a schedule never overlap with
+// another crawl of the same source: a run finding its source busy is skipped.
+type Scheduler struct {
+	Service *NewsCrawlerService
+
+	mu        sync.Mutex
+	location  *time.Location
+	jobs      []*scheduledJob
+	stateFile string
+	lastFired map[string]time.Time // Schedule name -> run time of its last run, kept in stateFile
+	wake      chan struct{}
+	now       func() time.Time
+	crawl     func(s *NewsCrawlerService, sourceID string, pages int) ([]NewsArticle, error)
+}
+
+// NewScheduler creates a scheduler for the schedules of cfg. The last run times are read from
+// cfg.ScheduleStateFile, to apply the missed run policies of runs missed while stopped.
+func NewScheduler(s *NewsCrawlerService, cfg *Config) (*Scheduler, error) {
+	sc := &Scheduler{
+		Service:   s,
+		stateFile: cfg.ScheduleStateFile,
+		lastFired: map[string]time.Time{},
+		wake:      make(chan struct{}, 1),
+		now:       time.Now,
+		crawl:     crawlScheduledSource,
+	}
+	if sc.stateFile != "" {
+		data, err := os.ReadFile(sc.stateFile)
+		if err == nil {
+			err = json.Unmarshal(data, &sc.lastFired)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading schedule state %s: %v", sc.stateFile, err)
+		}
+	}
+	if err := sc.Update(cfg); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// Update replaces the schedules, e.g. after a configuration reload. Schedules keep their last
+// run (and a run in progress) by name.
+func (sc *Scheduler) Update(cfg *Config) error {
+	location, err := loadScheduleLocation(cfg.ScheduleTimeZone)
+	if err != nil {
+		return fmt.Errorf("scheduleTimeZone: %v", err)
+	}
+	now := sc.now().In(location)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	existing := map[string]*scheduledJob{}
+	for _, job := range sc.jobs {
+		existing[job.config.Name] = job
+	}
+	var jobs []*scheduledJob
+	for _, config := range cfg.Schedules {
+		schedule, err := cronParser.Parse(config.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %v", config.Name, err)
+		}
+		if config.Pages == 0 {
+			config.Pages = 1
+		}
+		if config.MissedRuns == "" {
+			config.MissedRuns = MissedRunsSkip
+		}
+		job := existing[config.Name]
+		if job == nil {
+			job = &scheduledJob{}
+		}
+		job.config, job.schedule = config, schedule
+		job.next = schedule.Next(now)
+		if last, ok := sc.lastFired[config.Name]; ok && schedule.Next(last.In(location)).Before(now.Add(-missedRunGrace)) {
+			if config.MissedRuns == MissedRunsRunOnce {
+				sc.logger().Info("Schedule missed a run; running it now.", "schedule", config.Name, "missed_run", schedule.Next(last.In(location)))
+				job.next = now
+			} else {
+				sc.logger().Info("Schedule missed a run; skipped.", "schedule", config.Name, "missed_run", schedule.Next(last.In(location)))
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	sc.location, sc.jobs = location, jobs
+	select {
+	case sc.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Schedules returns the time zone of the schedules and their state.
+func (sc *Scheduler) Schedules() (string, []ScheduleState) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	states := []ScheduleState{}
+	for _, job := range sc.jobs {
+		state := ScheduleState{
+			Name:       job.config.Name,
+			Cron:       job.config.Cron,
+			Source:     job.config.Source,
+			Pages:      job.config.Pages,
+			MissedRuns: job.config.MissedRuns,
+			NextRun:    job.next,
+		}
+		if state.Source == "" {
+			state.Source = NaverMainNewsSourceID
+		}
+		if job.config.Jitter > 0 {
+			state.Jitter = job.config.Jitter.String()
+		}
+		if job.lastRun != nil {
+			run := *job.lastRun
+			state.LastRun = &run
+		}
+		states = append(states, state)
+	}
+	return sc.location.String(), states
+}
+
+// Run starts the crawls of the schedules when they are due, until ctx is done.
+func (sc *Scheduler) Run(ctx context.Context) {
+	for {
+		var due <-chan time.Time
+		sc.mu.Lock()
+		var next time.Time
+		for _, job := range sc.jobs {
+			if next.IsZero() || job.next.Before(next) {
+				next = job.next
+			}
+		}
+		sc.mu.Unlock()
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(sc.now()))
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-sc.wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		sc.startDue(ctx)
+	}
+}
+
+// startDue starts the runs that are due.
+func (sc *Scheduler) startDue(ctx context.Context) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	now := sc.now().In(sc.location)
+	fired := false
+	for _, job := range sc.jobs {
+		if job.next.After(now) {
+			continue
+		}
+		scheduledAt := job.next
+		job.next = job.schedule.Next(now)
+		if now.Sub(scheduledAt) > missedRunGrace && job.config.MissedRuns != MissedRunsRunOnce {
+			sc.logger().Warn("Schedule missed a run; skipped.", "schedule", job.config.Name, "missed_run", scheduledAt)
+			job.lastRun = &ScheduleRun{ScheduledAt: scheduledAt, Status: ScheduleRunSkipped, Error: "missed"}
+			continue
+		}
+		sc.lastFired[job.config.Name] = scheduledAt
+		fired = true
+		if job.running {
+			sc.logger().Warn("Schedule skipped a run: the previous run is still going.", "schedule", job.config.Name, "scheduled_at", scheduledAt)
+			continue
+		}
+		sc.start(ctx, job, scheduledAt)
+	}
+	if fired {
+		sc.saveState()
+	}
+}
+
+// start runs a schedule's crawl in the background, after its jitter delay. sc.mu is held.
+func (sc *Scheduler) start(ctx context.Context, job *scheduledJob, scheduledAt time.Time) {
+	run := &ScheduleRun{ScheduledAt: scheduledAt, Status: ScheduleRunRunning}
+	job.lastRun, job.running = run, true
+	config := job.config
+	jobID := newJobID()
+	logger := sc.logger().With("schedule", config.Name, LogKeyJobID, jobID)
+	go func() {
+		var articles []NewsArticle
+		var crawlRun *CrawlRun
+		err := func() error {
+			if config.Jitter > 0 {
+				select {
+				case <-time.After(time.Duration(rand.Int63n(int64(config.Jitter)))):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			end, err := sc.Service.beginCrawl(config.Source)
+			if err != nil {
+				return err
+			}
+			defer end()
+			started := sc.now()
+			sc.mu.Lock()
+			run.StartedAt = &started
+			sc.mu.Unlock()
+			logger.Info("Scheduled crawl started", "pages", config.Pages)
+			crawlJob := sc.Service.Job(context.Background(), JobInfo{ID: jobID, Trigger: RunTriggerScheduler, Schedule: config.Name})
+			articles, err = sc.crawl(crawlJob, config.Source, config.Pages)
+			crawlRun = crawlJob.LastRun()
+			return err
+		}()
+
+		var degradedErr *ParserDegradedError
+		finished := sc.now()
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		job.running = false
+		run.FinishedAt, run.Articles, run.Status = &finished, len(articles), ScheduleRunOK
+		if crawlRun != nil {
+			run.RunID = crawlRun.ID
+		}
+		switch {
+		case errors.Is(err, errSourceBusy), errors.Is(err, errShuttingDown), errors.Is(err, context.Canceled):
+			run.Status, run.Error = ScheduleRunSkipped, err.Error()
+			logger.Warn("Scheduled crawl skipped", LogKeyError, err)
+			return
+		case errors.As(err, &degradedErr):
+			run.Status, run.Error = ScheduleRunDegraded, err.Error()
+		case err != nil:
+			run.Status, run.Error = ScheduleRunFailed, err.Error()
+		}
+		logger.Info("Scheduled crawl finished", "status", run.Status, "articles", run.Articles, LogKeyDuration, finished.Sub(*run.StartedAt))
+	}()
+}
+
+func (sc *Scheduler) logger() *slog.Logger {
+	return componentLogger(LogComponentScheduler)
+}
+
+// saveState writes the last run times to the state file. sc.mu is held.
+func (sc *Scheduler) saveState() {
+	if sc.stateFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(sc.lastFired, "", "  ")
+	if err == nil {
+		tmp := sc.stateFile + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, sc.stateFile)
+		}
+	}
+	if err != nil {
+		sc.logger().Warn("Error saving schedule state", "path", sc.stateFile, LogKeyError, err)
+	}
+}
+
+// crawlScheduledSource crawls a source by ID, as the crawl command does: the hand-written
+// Naver Finance crawler for "" or naver-mainnews, else a declarative source.
+func crawlScheduledSource(s *NewsCrawlerService, sourceID string, pages int) ([]NewsArticle, error) {
+	if sourceID == "" || sourceID == NaverMainNewsSourceID {
+		return s.CrawlNaverFinanceNews(pages)
+	}
+	def := s.Sources[sourceID]
+	if def == nil {
+		return nil, fmt.Errorf("unknown source: %s", sourceID)
+	}
+	return s.CrawlSource(def, pages)
+}
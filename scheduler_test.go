@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+func newTestScheduler(t *testing.T, clock *fakeClock, schedules ...ScheduleConfig) (*Scheduler, *Config) {
+	cfg := DefaultConfig()
+	cfg.Schedules = schedules
+	cfg.ScheduleStateFile = filepath.Join(t.TempDir(), "schedule-state.json")
+	sc, err := NewScheduler(&NewsCrawlerService{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.now = clock.Now
+	if err := sc.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return sc, cfg
+}
+
+func lastRunStatus(sc *Scheduler, i int) string {
+	_, states := sc.Schedules()
+	if states[i].LastRun == nil {
+		return ""
+	}
+	return states[i].LastRun.Status
+}
+
+func TestSchedulerRuns(t *testing.T) {
+	seoul := seoulLocation()
+	clock := &fakeClock{now: time.Date(2024, 5, 10, 8, 58, 0, 0, seoul)} // Friday
+	sc, _ := newTestScheduler(t, clock,
+		ScheduleConfig{Name: "market", Cron: "*/5 9-15 * * 1-5", Pages: 2},
+		ScheduleConfig{Name: "off-hours", Cron: "0 * * * *", Source: "example"},
+	)
+	release := make(chan struct{})
+	crawled := make(chan string, 4)
+	sc.crawl = func(s *NewsCrawlerService, sourceID string, pages int) ([]NewsArticle, error) {
+		crawled <- sourceID
+		<-release
+		return []NewsArticle{{}}, nil
+	}
+
+	timeZone, states := sc.Schedules()
+	if timeZone != seoul.String() || len(states) != 2 {
+		t.Fatalf("schedules: %s %+v", timeZone, states)
+	}
+	if want := time.Date(2024, 5, 10, 9, 0, 0, 0, seoul); !states[0].NextRun.Equal(want) || !states[1].NextRun.Equal(want) {
+		t.Errorf("next runs %v, %v; want %v", states[0].NextRun, states[1].NextRun, want)
+	}
+	if states[0].Source != NaverMainNewsSourceID || states[1].Pages != 1 || states[1].MissedRuns != MissedRunsSkip {
+		t.Errorf("defaults: %+v", states)
+	}
+
+	clock.Set(time.Date(2024, 5, 10, 9, 0, 10, 0, seoul))
+	sc.startDue(context.Background())
+	<-crawled
+	<-crawled
+	if status := lastRunStatus(sc, 0); status != ScheduleRunRunning {
+		t.Errorf("status %q while crawling", status)
+	}
+
+	// The next run of a schedule still running is skipped.
+	clock.Set(time.Date(2024, 5, 10, 9, 5, 0, 0, seoul))
+	sc.startDue(context.Background())
+	close(release)
+	for lastRunStatus(sc, 0) == ScheduleRunRunning || lastRunStatus(sc, 1) == ScheduleRunRunning {
+		time.Sleep(time.Millisecond)
+	}
+	_, states = sc.Schedules()
+	if run := states[0].LastRun; run.Status != ScheduleRunOK || run.Articles != 1 || !run.ScheduledAt.Equal(time.Date(2024, 5, 10, 9, 0, 0, 0, seoul)) {
+		t.Errorf("last run %+v", run)
+	}
+	if want := time.Date(2024, 5, 10, 9, 10, 0, 0, seoul); !states[0].NextRun.Equal(want) {
+		t.Errorf("next run %v, want %v", states[0].NextRun, want)
+	}
+
+	// A run started long after its time was missed.
+	clock.Set(time.Date(2024, 5, 10, 9, 30, 0, 0, seoul))
+	sc.startDue(context.Background())
+	if status := lastRunStatus(sc, 0); status != ScheduleRunSkipped {
+		t.Errorf("late run: %q", status)
+	}
+}
+
+func TestSchedulerMissedRuns(t *testing.T) {
+	seoul := seoulLocation()
+	clock := &fakeClock{now: time.Date(2024, 5, 10, 12, 30, 0, 0, seoul)}
+	skip := ScheduleConfig{Name: "skip", Cron: "@hourly"}
+	once := ScheduleConfig{Name: "once", Cron: "@hourly", MissedRuns: MissedRunsRunOnce}
+	recent := ScheduleConfig{Name: "recent", Cron: "@hourly", MissedRuns: MissedRunsRunOnce}
+	cfg := DefaultConfig()
+	cfg.Schedules = []ScheduleConfig{skip, once, recent}
+	cfg.ScheduleStateFile = filepath.Join(t.TempDir(), "schedule-state.json")
+
+	// Runs before the server stopped, read from the state file.
+	state, _ := json.Marshal(map[string]time.Time{
+		"skip":   time.Date(2024, 5, 10, 9, 0, 0, 0, seoul),
+		"once":   time.Date(2024, 5, 10, 9, 0, 0, 0, seoul),
+		"recent": time.Date(2024, 5, 10, 12, 0, 0, 0, seoul),
+	})
+	os.WriteFile(cfg.ScheduleStateFile, state, 0644)
+	sc, err := NewScheduler(&NewsCrawlerService{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.now = clock.Now
+	sc.Update(cfg)
+
+	_, states := sc.Schedules()
+	next := time.Date(2024, 5, 10, 13, 0, 0, 0, seoul)
+	if !states[0].NextRun.Equal(next) || !states[1].NextRun.Equal(clock.Now()) || !states[2].NextRun.Equal(next) {
+		t.Errorf("next runs: skip %v, once %v, recent %v", states[0].NextRun, states[1].NextRun, states[2].NextRun)
+	}
+}
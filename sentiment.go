@@ -0,0 +1,50 @@
+package main
+
+import "strings"
+
+// Market-news wording counted by scoreSentiment. Longer phrases are listed before the words
+// they contain, which are then not counted again (e.g. "상승세 둔화" is negative, not positive).
+var (
+	positiveSentimentTerms = []string{
+		"사상 최대", "최대 실적", "어닝 서프라이즈", "호실적", "흑자 전환", "흑자전환", "신고가", "급등", "상한가", "강세",
+		"반등", "상승", "돌파", "호재", "수혜", "개선", "성장", "증가", "확대", "상향", "회복", "순매수", "매수", "최대",
+	}
+	negativeSentimentTerms = []string{
+		"상승세 둔화", "어닝 쇼크", "적자 전환", "적자전환", "신저가", "급락", "폭락", "하한가", "약세", "하락", "적자",
+		"부진", "악재", "우려", "리스크", "위기", "둔화", "감소", "축소", "하향", "손실", "순매도", "매도", "침체", "부도",
+	}
+)
+
+// scoreSentiment estimates the tone of an article from market-news wording, from -1 (negative)
+// to 1 (positive); 0 is neutral or unknown. Title words weigh twice as much as body words.
+func scoreSentiment(article *NewsArticle) *float64 {
+	body := article.Content
+	if body == "" {
+		body = article.Summary
+	}
+	pos, neg := countSentimentTerms(article.Title)
+	pos, neg = pos*2, neg*2
+	bodyPos, bodyNeg := countSentimentTerms(body)
+	pos += bodyPos
+	neg += bodyNeg
+	score := 0.0
+	if pos+neg > 0 {
+		score = float64(pos-neg) / float64(pos+neg)
+	}
+	return &score
+}
+
+// countSentimentTerms counts the positive and negative terms in text. Matched text is blanked
+// out so that overlapping terms are counted once.
+func countSentimentTerms(text string) (pos, neg int) {
+	// The negative phrases go first: they contain positive words ("상승세 둔화").
+	for _, term := range negativeSentimentTerms {
+		neg += strings.Count(text, term)
+		text = strings.ReplaceAll(text, term, " ")
+	}
+	for _, term := range positiveSentimentTerms {
+		pos += strings.Count(text, term)
+		text = strings.ReplaceAll(text, term, " ")
+	}
+	return pos, neg
+}
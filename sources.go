@@ -0,0 +1,422 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Source time zones (e.g. Asia/Seoul) must resolve in minimal containers
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// NaverMainNewsSourceID identifies the built-in Naver Finance main news source.
+const NaverMainNewsSourceID = "naver-mainnews"
+
+// Source definition types.
+const (
+	SourceTypeHTML = "html" // Paginated HTML list pages (default)
+	SourceTypeFeed = "feed" // A list of RSS 2.0 / Atom feeds
+)
+
+// FieldSelector locates a value inside a list item or page.
+// The value is the element's text, or the given attribute when Attr is set.
+type FieldSelector struct {
+	Selector string   `json:"selector" yaml:"selector"`
+	Attr     string   `json:"attr,omitempty" yaml:"attr,omitempty"`
+	Remove   []string `json:"remove,omitempty" yaml:"remove,omitempty"` // Selectors removed before reading the text
+}
+
+// LinkRewrite rewrites article links matching Pattern into Replacement
+// (regexp.Expand syntax, e.g. "https://n.news.naver.com/mnews/article/${office}/${article}").
+type LinkRewrite struct {
+	Pattern     string `json:"pattern" yaml:"pattern"`
+	Replacement string `json:"replacement" yaml:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// Pagination describes how the {page} placeholder of the list URL advances.
+type Pagination struct {
+	Start    int `json:"start,omitempty" yaml:"start,omitempty"`       // First page number (default 1)
+	Step     int `json:"step,omitempty" yaml:"step,omitempty"`         // Increment between pages (default 1)
+	MaxPages int `json:"maxPages,omitempty" yaml:"maxPages,omitempty"` // Upper bound on pages per run (0 = unlimited)
+}
+
+// SourceDefinition declaratively describes how to crawl a news site.
+type SourceDefinition struct {
+	ID      string `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	Type    string `json:"type,omitempty" yaml:"type,omitempty"` // SourceTypeHTML (default) or SourceTypeFeed
+	ListURL string `json:"listUrl" yaml:"listUrl"`               // List page URL template containing {page}
+
+	Feeds []FeedConfig `json:"feeds,omitempty" yaml:"feeds,omitempty"` // Feeds of a SourceTypeFeed source
+
+	Pagination Pagination `json:"pagination" yaml:"pagination"`
+
+	Item    string        `json:"item" yaml:"item"` // Selector of a news item on the list page
+	Title   FieldSelector `json:"title" yaml:"title"`
+	Link    FieldSelector `json:"link" yaml:"link"`
+	Summary FieldSelector `json:"summary" yaml:"summary"`
+	Source  FieldSelector `json:"source" yaml:"source"`
+	Date    FieldSelector `json:"date" yaml:"date"`
+	Image   FieldSelector `json:"image,omitempty" yaml:"image,omitempty"` // List thumbnail, the lead image fallback
+
+	// Required list fields; items missing one of them are skipped (default: title and link).
+	Required []string `json:"required,omitempty" yaml:"required,omitempty"`
+
+	LinkRewrites []LinkRewrite `json:"linkRewrites,omitempty" yaml:"linkRewrites,omitempty"`
+
+	Body FieldSelector `json:"body" yaml:"body"` // Article body on the article page; Remove strips boilerplate
+
+	DateFormats []string `json:"dateFormats,omitempty" yaml:"dateFormats,omitempty"` // Go reference layouts
+	TimeZone    string   `json:"timeZone,omitempty" yaml:"timeZone,omitempty"`       // Default Asia/Seoul
+
+	location *time.Location
+}
+
+// LoadSourceDefinitions loads every *.yaml, *.yml and *.json source definition in dir.
+// A missing directory yields no definitions.
+func LoadSourceDefinitions(dir string) ([]*SourceDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading source directory %s: %v", dir, err)
+	}
+
+	var defs []*SourceDefinition
+	seen := map[string]string{}
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		def, err := LoadSourceDefinition(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[def.ID]; ok {
+			return nil, fmt.Errorf("source %q is defined in both %s and %s", def.ID, other, path)
+		}
+		seen[def.ID] = path
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].ID < defs[j].ID })
+	return defs, nil
+}
+
+// LoadSourceDefinition loads and validates a single YAML or JSON source definition file.
+func LoadSourceDefinition(path string) (*SourceDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading source definition %s: %v", path, err)
+	}
+	def := &SourceDefinition{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, def)
+	} else {
+		err = yaml.Unmarshal(data, def)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding source definition %s: %v", path, err)
+	}
+	if err := def.compile(); err != nil {
+		return nil, fmt.Errorf("invalid source definition %s: %v", path, err)
+	}
+	return def, nil
+}
+
+// compile validates the definition, applies defaults and compiles its regular expressions.
+func (d *SourceDefinition) compile() error {
+	if d.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	switch d.Type {
+	case "", SourceTypeHTML:
+		d.Type = SourceTypeHTML
+	case SourceTypeFeed:
+		if len(d.Feeds) == 0 {
+			return fmt.Errorf("a feed source needs at least one feed")
+		}
+		for i, feed := range d.Feeds {
+			if feed.URL == "" {
+				return fmt.Errorf("feed %d: url is required", i)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown source type %q", d.Type)
+	}
+
+	switch {
+	case !strings.Contains(d.ListURL, "{page}"):
+		return fmt.Errorf("listUrl must contain the {page} placeholder")
+	case d.Item == "":
+		return fmt.Errorf("item selector is required")
+	case d.Title.Selector == "" || d.Link.Selector == "":
+		return fmt.Errorf("title and link selectors are required")
+	}
+
+	if d.Pagination.Start == 0 {
+		d.Pagination.Start = 1
+	}
+	if d.Pagination.Step == 0 {
+		d.Pagination.Step = 1
+	}
+	if len(d.Required) == 0 {
+		d.Required = []string{"title", "link"}
+	}
+	for _, field := range d.Required {
+		switch field {
+		case "title", "link", "summary", "source":
+		default:
+			return fmt.Errorf("unknown required field %q", field)
+		}
+	}
+
+	for i := range d.LinkRewrites {
+		re, err := regexp.Compile(d.LinkRewrites[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("link rewrite %d: %v", i, err)
+		}
+		d.LinkRewrites[i].re = re
+	}
+
+	if d.TimeZone == "" {
+		d.TimeZone = "Asia/Seoul"
+	}
+	loc, err := time.LoadLocation(d.TimeZone)
+	if err != nil {
+		return fmt.Errorf("timeZone: %v", err)
+	}
+	d.location = loc
+	return nil
+}
+
+// PageURL returns the list URL of the n-th page (0-based) of a run.
+func (d *SourceDefinition) PageURL(n int) string {
+	page := d.Pagination.Start + n*d.Pagination.Step
+	return strings.ReplaceAll(d.ListURL, "{page}", strconv.Itoa(page))
+}
+
+// extract reads the value designated by f inside sel. Empty selectors yield "".
+func (f FieldSelector) extract(sel *goquery.Selection) string {
+	if f.Selector == "" {
+		return ""
+	}
+	found := sel.Find(f.Selector).First()
+	if found.Length() == 0 {
+		return ""
+	}
+	if f.Attr != "" {
+		value, _ := found.Attr(f.Attr)
+		return strings.TrimSpace(value)
+	}
+	if len(f.Remove) > 0 {
+		found = found.Clone()
+		found.Find(strings.Join(f.Remove, ", ")).Remove()
+	}
+	return strings.TrimSpace(found.Text())
+}
+
+// ResolveLink applies the first matching link rewrite to link, then resolves
+// the result against the list page URL.
+func (d *SourceDefinition) ResolveLink(link, pageURL string) string {
+	for _, rewrite := range d.LinkRewrites {
+		if match := rewrite.re.FindStringSubmatchIndex(link); match != nil {
+			link = string(rewrite.re.ExpandString(nil, rewrite.Replacement, link, match))
+			break
+		}
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// ParseDate parses a publication date using the definition's formats and time zone.
+// It returns the zero time when no format matches.
+func (d *SourceDefinition) ParseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range d.DateFormats {
+		if t, err := time.ParseInLocation(layout, value, d.location); err == nil {
+			return t
+		}
+	}
+	componentLogger(LogComponentCrawler).Warn("Could not parse date", LogKeyStage, StageParse, LogKeySource, d.ID, "date", value, "formats", d.DateFormats)
+	return time.Time{}
+}
+
+// ParseList extracts the news items of a list page fetched from pageURL.
+// Content is pre-filled with the summary until the full body is fetched.
+// Items missing a required field are recorded in health (which may be nil) and skipped.
+func (d *SourceDefinition) ParseList(doc *goquery.Document, pageURL string, health *ParserHealth) []NewsArticle {
+	articles := []NewsArticle{}
+	doc.Find(d.Item).Each(func(i int, item *goquery.Selection) {
+		values := map[string]string{
+			"title":   d.Title.extract(item),
+			"link":    d.Link.extract(item),
+			"summary": d.Summary.extract(item),
+			"source":  d.Source.extract(item),
+		}
+		var missing []string
+		for _, field := range d.Required {
+			if values[field] == "" {
+				missing = append(missing, field)
+			}
+		}
+		isMissing := func(field string) bool {
+			for _, m := range missing {
+				if m == field {
+					return true
+				}
+			}
+			return false
+		}
+		health.RecordItem(item, isMissing("title"), isMissing("summary"), isMissing("source"), isMissing("link"))
+		if len(missing) > 0 {
+			health.logger().Warn("List item is missing required fields. Skipping.", LogKeyStage, StageParse, "item", i, "missing", missing)
+			return
+		}
+
+		article := NewsArticle{
+			Title:       cleanUTF8String(values["title"]),
+			Summary:     cleanUTF8String(values["summary"]),
+			Content:     cleanUTF8String(values["summary"]),
+			Source:      cleanUTF8String(values["source"]),
+			URL:         cleanUTF8String(d.ResolveLink(values["link"], pageURL)),
+			SourceID:    d.ID,
+			PublishedAt: d.ParseDate(d.Date.extract(item)),
+		}
+		if image := d.Image.extract(item); image != "" {
+			article.LeadImageURL = resolveURL(image, pageURL)
+		}
+		articles = append(articles, article)
+	})
+	return articles
+}
+
+// ParseBody extracts the content blocks of an article page.
+// It returns false when the body selector matches nothing.
+func (d *SourceDefinition) ParseBody(doc *goquery.Document) ([]ContentBlock, bool) {
+	return extractBody(d.Body, doc)
+}
+
+// extractBody extracts the content blocks of the element designated by body, after removing
+// its Remove selectors. It returns false when the selector matches nothing.
+func extractBody(body FieldSelector, doc *goquery.Document) ([]ContentBlock, bool) {
+	if body.Selector == "" {
+		return nil, false
+	}
+	found := doc.Find(body.Selector).First()
+	if found.Length() == 0 {
+		return nil, false
+	}
+	if len(body.Remove) > 0 {
+		found = found.Clone()
+		found.Find(strings.Join(body.Remove, ", ")).Remove()
+	}
+	return extractBlocks(found), true
+}
+
+// CrawlSource crawls up to pages list pages of a declaratively defined source
+// and saves the new articles, exactly like CrawlNaverFinanceNews does for its hand-written parser.
+// Feed sources read each of their feeds once; pages does not apply to them.
+func (s *NewsCrawlerService) CrawlSource(def *SourceDefinition, pages int) ([]NewsArticle, error) {
+	if def.Type == SourceTypeFeed {
+		return s.crawlFeeds(def)
+	}
+	if def.Pagination.MaxPages > 0 && pages > def.Pagination.MaxPages {
+		pages = def.Pagination.MaxPages
+	}
+	ctx, end := s.startCrawl(def.ID)
+	defer end()
+	allNews := []NewsArticle{}
+	health := s.newParserHealth(def.ID)
+	health.logger().Info("Starting collection of source", "pages", pages)
+	run := s.startRun(ctx, health, RunParams{Pages: pages})
+	var fetchErr error
+
+	for n := 0; n < pages && ctx.Err() == nil; n++ {
+		pageURL := def.PageURL(n)
+		logger := health.logger().With(LogKeyPage, n+1)
+		pageCtx, pageSpan := tracer.Start(ctx, "list page", trace.WithAttributes(attribute.Int(TraceKeyPage, n+1), semconv.URLFull(pageURL)))
+		doc, err := s.Fetcher.FetchDocumentContext(pageCtx, pageURL, s.Config.ListFetchTimeout)
+		run.startPage(strconv.Itoa(n+1), pageURL, err)
+		if err != nil {
+			logger.Error("Error requesting list page", LogKeyStage, StageList, "url", pageURL, LogKeyError, err)
+			if n == 0 {
+				fetchErr = fmt.Errorf("error requesting %s: %v", pageURL, err)
+			}
+			endSpan(pageSpan, err)
+			break
+		}
+		if doc.Find(def.Item).Length() == 0 {
+			health.RecordMissingList(pageURL, doc)
+			logger.Warn("Could not find news list. Stopping crawl.", LogKeyStage, StageParse, "selector", def.Item, "url", pageURL)
+			run.recordError(StageParse, pageURL, errNewsListNotFound)
+			endSpan(pageSpan, errNewsListNotFound)
+			break
+		}
+
+		_, parseSpan := tracer.Start(pageCtx, "parse list")
+		list := def.ParseList(doc, pageURL, health)
+		parseSpan.SetAttributes(attribute.Int("crawler.items", len(list)))
+		parseSpan.End()
+		run.countArticles(ArticleOutcomeDiscovered, len(list))
+		for _, newsArticle := range list {
+			if ctx.Err() != nil {
+				break // Interrupted: the remaining articles are collected by the next run
+			}
+			if s.articleAlreadyKnown(pageCtx, newsArticle.URL, health) {
+				run.countArticles(ArticleOutcomeSkipped, 1)
+				continue
+			}
+
+			if err := s.fetchArticleContent(pageCtx, &newsArticle, def.ParseBody, health); err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				run.recordError(StageArticle, newsArticle.URL, err)
+			}
+
+			if err := s.saveCollectedArticle(pageCtx, &newsArticle); err != nil {
+				logger.Error("Article store save error", LogKeyStage, StageStore, LogKeyArticleURL, newsArticle.URL, LogKeyError, err)
+				run.recordError(StageStore, newsArticle.URL, err)
+				run.countArticles(ArticleOutcomeFailed, 1)
+				continue
+			}
+			run.countArticles(ArticleOutcomeNew, 1)
+			allNews = append(allNews, newsArticle)
+
+			s.pauseBetweenArticles(ctx)
+		}
+
+		logger.Info("Page collection complete", LogKeyStage, StageList, "listed", len(list), "saved_so_far", len(allNews))
+		pageSpan.End()
+		s.saveRun(ctx, run) // Progress, shown by the admin dashboard
+		s.pauseBetweenPages(ctx)
+	}
+	return allNews, s.finishCrawl(ctx, health, run, fetchErr)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestNaverMainNewsDefinitionMatchesParser proves that sources/naver-mainnews.yaml expresses
+// the hand-written Naver parser: both must produce the same articles from the same fixtures.
+func TestNaverMainNewsDefinitionMatchesParser(t *testing.T) {
+	def, err := LoadSourceDefinition(filepath.Join("sources", "naver-mainnews.yaml"))
+	if err != nil {
+		t.Fatalf("LoadSourceDefinition: %v", err)
+	}
+	fetcher := newReplayFetcher(t)
+
+	for n := 0; n < 3; n++ {
+		pageURL := def.PageURL(n)
+		want := parseNaverNewsList(mustFetch(t, fetcher, pageURL), "https://n.news.naver.com/mnews/article", nil)
+		got := def.ParseList(mustFetch(t, fetcher, pageURL), pageURL, nil)
+
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d articles, want %d", pageURL, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].SourceID != NaverMainNewsSourceID || got[i].PublishedAt.IsZero() {
+				t.Errorf("%s item %d: SourceID=%q PublishedAt=%v", pageURL, i, got[i].SourceID, got[i].PublishedAt)
+			}
+			got[i].SourceID, got[i].PublishedAt = "", time.Time{}
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("%s item %d:\n got %+v\nwant %+v", pageURL, i, got[i], want[i])
+			}
+		}
+	}
+
+	articleURL := "https://n.news.naver.com/mnews/article/015/0004970001"
+	wantBody, wantOK := parseNaverArticleBody(mustFetch(t, fetcher, articleURL))
+	gotBody, gotOK := def.ParseBody(mustFetch(t, fetcher, articleURL))
+	if gotOK != wantOK || !reflect.DeepEqual(gotBody, wantBody) {
+		t.Errorf("body mismatch:\n got %v %q\nwant %v %q", gotOK, gotBody, wantOK, wantBody)
+	}
+}
+
+func TestSourceDefinitionParseDate(t *testing.T) {
+	def, err := LoadSourceDefinition(filepath.Join("sources", "naver-mainnews.yaml"))
+	if err != nil {
+		t.Fatalf("LoadSourceDefinition: %v", err)
+	}
+	got := def.ParseDate("2024-05-10 09:12:33")
+	if want := time.Date(2024, 5, 10, 0, 12, 33, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ParseDate = %v, want %v", got, want)
+	}
+	if !def.ParseDate("어제").IsZero() {
+		t.Error("expected the zero time for an unparseable date")
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// articlesCollection is the Firestore collection holding NewsArticle documents.
+const articlesCollection = "newsArticles"
+
+// Summary statuses accepted by ArticleQuery.SummaryStatus.
+const (
+	SummaryStatusPending  = "pending"  // No AI summary yet, never retried
+	SummaryStatusRetrying = "retrying" // No AI summary yet, summarization failed at least once
+	SummaryStatusDone     = "done"     // AI summary present
+)
+
+// Listing page sizes.
+const (
+	DefaultArticlePageSize = 20
+	MaxArticlePageSize     = 100
+)
+
+// ArticleStore persists news articles.
+type ArticleStore interface {
+	// GetArticle returns the article stored for url, or nil when there is none.
+	GetArticle(ctx context.Context, url string) (*NewsArticle, error)
+	// SaveArticle creates or replaces an article.
+	SaveArticle(ctx context.Context, article NewsArticle) error
+	// ResetAISummary sets the AI summary of a stored article to "" so it is summarized again.
+	// It reports whether the article changed: false when its summary already was "".
+	ResetAISummary(ctx context.Context, url string) (bool, error)
+	// ListArticles returns a page of articles matching q, newest first.
+	ListArticles(ctx context.Context, q ArticleQuery) (*ArticlePage, error)
+}
+
+// ArticleQuery filters and paginates an article listing. Zero fields do not filter.
+type ArticleQuery struct {
+	SourceID      string    // Crawl source (sourceId)
+	Publisher     string    // Press name (source), or Naver office ID (officeId) when numeric
+	Ticker        string    // Stock code contained in tickers
+	From, To      time.Time // collectedAt range: From inclusive, To exclusive
+	SummaryStatus string    // SummaryStatusPending, SummaryStatusRetrying or SummaryStatusDone
+	HasAISummary  *bool
+	Limit         int    // Page size (DefaultArticlePageSize when 0, capped at MaxArticlePageSize)
+	Cursor        string // NextCursor of the previous page
+}
+
+// ArticlePage is a page of an article listing.
+type ArticlePage struct {
+	Articles   []NewsArticle `json:"articles"`
+	NextCursor string        `json:"nextCursor,omitempty"` // Empty on the last page
+}
+
+// articleCursor is the position after the last article of a page. Listings are ordered by
+// collectedAt, then document ID (both descending), so the pair identifies the position uniquely.
+type articleCursor struct {
+	CollectedAt time.Time `json:"c"`
+	DocID       string    `json:"d"`
+}
+
+func (c articleCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeArticleCursor parses an opaque cursor returned as ArticlePage.NextCursor.
+func decodeArticleCursor(s string) (*articleCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c articleCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.DocID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// Validate checks the query values and applies the default page size.
+func (q *ArticleQuery) Validate() error {
+	switch q.SummaryStatus {
+	case "", SummaryStatusPending, SummaryStatusRetrying, SummaryStatusDone:
+	default:
+		return fmt.Errorf("invalid summary status %q (expected %s, %s or %s)", q.SummaryStatus, SummaryStatusPending, SummaryStatusRetrying, SummaryStatusDone)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return fmt.Errorf("invalid date range: from must be before to")
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultArticlePageSize
+	}
+	if q.Limit > MaxArticlePageSize {
+		q.Limit = MaxArticlePageSize
+	}
+	if _, err := decodeArticleCursor(q.Cursor); err != nil {
+		return err
+	}
+	return nil
+}
+
+var officeIDValuePattern = regexp.MustCompile(`^\d{3}$`)
+
+// publisherField returns the article field the Publisher filter applies to.
+func (q *ArticleQuery) publisherField() string {
+	if officeIDValuePattern.MatchString(q.Publisher) {
+		return "officeId"
+	}
+	return "source"
+}
+
+// Matches reports whether article satisfies the filters of q (pagination aside).
+func (q *ArticleQuery) Matches(article *NewsArticle) bool {
+	if q.SourceID != "" && article.SourceID != q.SourceID {
+		return false
+	}
+	if q.Publisher != "" {
+		if q.publisherField() == "officeId" && article.OfficeID != q.Publisher {
+			return false
+		}
+		if q.publisherField() == "source" && article.Source != q.Publisher {
+			return false
+		}
+	}
+	if q.Ticker != "" && !containsString(article.Tickers, q.Ticker) {
+		return false
+	}
+	if !q.From.IsZero() && article.CollectedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !article.CollectedAt.Before(q.To) {
+		return false
+	}
+	return q.matchesSummary(article)
+}
+
+// matchesSummary applies the summary filters, which Firestore cannot fully express as
+// equality filters next to the collectedAt ordering.
+func (q *ArticleQuery) matchesSummary(article *NewsArticle) bool {
+	if q.HasAISummary != nil && (article.AISummary != "") != *q.HasAISummary {
+		return false
+	}
+	switch q.SummaryStatus {
+	case SummaryStatusPending:
+		return article.AISummary == "" && article.SummaryRetryCount == 0
+	case SummaryStatusRetrying:
+		return article.AISummary == "" && article.SummaryRetryCount > 0
+	case SummaryStatusDone:
+		return article.AISummary != ""
+	}
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// walkArticles calls fn for every article matching q (pagination aside), newest first.
+// Articles are read from the store page by page, so memory use does not grow with the number
+// of articles. The walk stops when fn returns false or an error, or when ctx is done.
+func walkArticles(ctx context.Context, store ArticleStore, q ArticleQuery, fn func(article *NewsArticle) (bool, error)) error {
+	q.Limit = MaxArticlePageSize
+	q.Cursor = ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := store.ListArticles(ctx, q)
+		if err != nil {
+			return err
+		}
+		for i := range page.Articles {
+			more, err := fn(&page.Articles[i])
+			if err != nil || !more {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// articleDocID derives the Firestore document ID of an article from its URL.
+func articleDocID(url string) string {
+	docID := strings.NewReplacer("/", "_", ":", "_", "?", "_", "&", "_", "=", "_", "#", "_", "%", "_", ".", "_").Replace(url)
+	if len(docID) > 500 {
+		docID = docID[:500]
+	}
+	return docID
+}
+
+// FirestoreArticleStore is the ArticleStore backed by the newsArticles Firestore collection.
+// It uses the Firebase app set up by InitializeFirestoreClient.
+type FirestoreArticleStore struct{}
+
+func (s *FirestoreArticleStore) client(ctx context.Context) (*firestore.Client, error) {
+	if firestoreApp == nil {
+		return nil, fmt.Errorf("Firestore client not initialized")
+	}
+	client, err := firestoreApp.Firestore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Firestore client: %v", err)
+	}
+	return client, nil
+}
+
+// GetArticle implements ArticleStore.
+func (s *FirestoreArticleStore) GetArticle(ctx context.Context, url string) (*NewsArticle, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	docSnap, err := client.Collection(articlesCollection).Doc(articleDocID(url)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil // Document not found
+		}
+		return nil, fmt.Errorf("error checking if article exists in Firestore: %v", err)
+	}
+	var existingArticle NewsArticle
+	if err := docSnap.DataTo(&existingArticle); err != nil {
+		componentLogger(LogComponentStore).Warn("Failed to convert existing Firestore document data to NewsArticle", LogKeyArticleURL, url, LogKeyError, err)
+		return nil, fmt.Errorf("failed to convert existing article data")
+	}
+	return &existingArticle, nil
+}
+
+// SaveArticle implements ArticleStore.
+func (s *FirestoreArticleStore) SaveArticle(ctx context.Context, article NewsArticle) error {
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, err = client.Collection(articlesCollection).Doc(articleDocID(article.URL)).Set(ctx, article)
+	if err != nil {
+		// Invalid UTF-8 is the usual cause; the fields are truncated by the logging policy.
+		componentLogger(LogComponentStore).Error("Firestore save attempt failed", LogKeyArticleURL, article.URL, LogKeyError, err,
+			"title", article.Title, "summary", article.Summary, "content", article.Content, "publisher", article.Source)
+		return fmt.Errorf("error saving article to Firestore: %v", err)
+	}
+	componentLogger(LogComponentStore).Debug("Article saved to Firestore", LogKeyArticleURL, article.URL, "title", article.Title)
+	return nil
+}
+
+// ResetAISummary implements ArticleStore.
+func (s *FirestoreArticleStore) ResetAISummary(ctx context.Context, url string) (bool, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	doc := client.Collection(articlesCollection).Doc(articleDocID(url))
+	docSnap, err := doc.Get(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error reading existing article: %v", err)
+	}
+	// Documents written before the crawler stored an empty aiSummary lack the field
+	if summary, err := docSnap.DataAt("aiSummary"); err == nil && summary == "" {
+		return false, nil
+	}
+	_, err = doc.Update(ctx, []firestore.Update{
+		{Path: "aiSummary", Value: ""},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error updating existing article's AISummary to empty: %v", err)
+	}
+	componentLogger(LogComponentStore).Debug("Updated existing article's AISummary to empty", LogKeyArticleURL, url)
+	return true, nil
+}
+
+// firestoreListMaxBatches bounds the documents read for one page when filters that cannot
+// be expressed as index equality (e.g. hasAISummary=true) discard most of them.
+const firestoreListMaxBatches = 5
+
+// ListArticles implements ArticleStore. Equality filters and the collectedAt range are run as
+// an indexed query (see firestore.indexes.json); the summary filters that Firestore cannot
+// combine with the ordering are applied to the query results.
+func (s *FirestoreArticleStore) ListArticles(ctx context.Context, q ArticleQuery) (*ArticlePage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	cursor, _ := decodeArticleCursor(q.Cursor)
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	query := client.Collection(articlesCollection).Query
+	if q.SourceID != "" {
+		query = query.Where("sourceId", "==", q.SourceID)
+	}
+	if q.Publisher != "" {
+		query = query.Where(q.publisherField(), "==", q.Publisher)
+	}
+	if q.Ticker != "" {
+		query = query.Where("tickers", "array-contains", q.Ticker)
+	}
+	if (q.HasAISummary != nil && !*q.HasAISummary) || q.SummaryStatus == SummaryStatusPending || q.SummaryStatus == SummaryStatusRetrying {
+		query = query.Where("aiSummary", "==", "")
+	}
+	if q.SummaryStatus == SummaryStatusPending {
+		query = query.Where("summaryRetryCount", "==", 0)
+	}
+	if !q.From.IsZero() {
+		query = query.Where("collectedAt", ">=", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("collectedAt", "<", q.To)
+	}
+	query = query.OrderBy("collectedAt", firestore.Desc).OrderBy(firestore.DocumentID, firestore.Desc)
+
+	page := &ArticlePage{Articles: []NewsArticle{}}
+	batchSize := q.Limit + 1
+	for batch := 0; batch < firestoreListMaxBatches; batch++ {
+		batchQuery := query.Limit(batchSize)
+		if cursor != nil {
+			batchQuery = batchQuery.StartAfter(cursor.CollectedAt, cursor.DocID)
+		}
+		docs, err := batchQuery.Documents(ctx).GetAll()
+		if err != nil && err != iterator.Done {
+			return nil, fmt.Errorf("error listing articles from Firestore: %v", err)
+		}
+		for _, doc := range docs {
+			var article NewsArticle
+			if err := doc.DataTo(&article); err != nil {
+				componentLogger(LogComponentStore).Warn("Failed to convert Firestore document data to NewsArticle", "doc_id", doc.Ref.ID, LogKeyError, err)
+				continue
+			}
+			if !q.matchesSummary(&article) {
+				cursor = &articleCursor{CollectedAt: article.CollectedAt, DocID: doc.Ref.ID}
+				continue
+			}
+			if len(page.Articles) == q.Limit {
+				// One more match exists: the page is full and the listing continues.
+				page.NextCursor = cursor.encode()
+				return page, nil
+			}
+			page.Articles = append(page.Articles, article)
+			cursor = &articleCursor{CollectedAt: article.CollectedAt, DocID: doc.Ref.ID}
+		}
+		if len(docs) < batchSize {
+			return page, nil // End of the collection
+		}
+	}
+	// Scan budget exhausted: hand out the position reached so the client can continue.
+	page.NextCursor = cursor.encode()
+	return page, nil
+}
+
+// MemoryArticleStore is an in-process ArticleStore, used for local runs without Firebase
+// (ARTICLE_STORE=memory) and in tests. Articles are lost on restart.
+type MemoryArticleStore struct {
+	mu       sync.RWMutex
+	articles map[string]NewsArticle // By document ID
+}
+
+// NewMemoryArticleStore creates an empty MemoryArticleStore.
+func NewMemoryArticleStore() *MemoryArticleStore {
+	return &MemoryArticleStore{articles: map[string]NewsArticle{}}
+}
+
+// GetArticle implements ArticleStore.
+func (s *MemoryArticleStore) GetArticle(ctx context.Context, url string) (*NewsArticle, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	article, ok := s.articles[articleDocID(url)]
+	if !ok {
+		return nil, nil
+	}
+	return &article, nil
+}
+
+// SaveArticle implements ArticleStore.
+func (s *MemoryArticleStore) SaveArticle(ctx context.Context, article NewsArticle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.articles[articleDocID(article.URL)] = article
+	return nil
+}
+
+// ResetAISummary implements ArticleStore.
+func (s *MemoryArticleStore) ResetAISummary(ctx context.Context, url string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docID := articleDocID(url)
+	article, ok := s.articles[docID]
+	if !ok {
+		return false, fmt.Errorf("article not found: %s", url)
+	}
+	if article.AISummary == "" {
+		return false, nil
+	}
+	article.AISummary = ""
+	s.articles[docID] = article
+	return true, nil
+}
+
+// ListArticles implements ArticleStore.
+func (s *MemoryArticleStore) ListArticles(ctx context.Context, q ArticleQuery) (*ArticlePage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	cursor, _ := decodeArticleCursor(q.Cursor)
+
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.articles))
+	for id := range s.articles {
+		ids = append(ids, id)
+	}
+	articles := make(map[string]NewsArticle, len(ids))
+	for _, id := range ids {
+		articles[id] = s.articles[id]
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := articles[ids[i]].CollectedAt, articles[ids[j]].CollectedAt
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return ids[i] > ids[j]
+	})
+
+	page := &ArticlePage{Articles: []NewsArticle{}}
+	for _, id := range ids {
+		article := articles[id]
+		if cursor != nil {
+			// Skip up to and including the cursor position.
+			if article.CollectedAt.After(cursor.CollectedAt) || (article.CollectedAt.Equal(cursor.CollectedAt) && id >= cursor.DocID) {
+				continue
+			}
+		}
+		if !q.Matches(&article) {
+			continue
+		}
+		if len(page.Articles) == q.Limit {
+			last := page.Articles[len(page.Articles)-1]
+			page.NextCursor = articleCursor{CollectedAt: last.CollectedAt, DocID: articleDocID(last.URL)}.encode()
+			break
+		}
+		page.Articles = append(page.Articles, article)
+	}
+	return page, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func seedMemoryStore(t *testing.T) *MemoryArticleStore {
+	t.Helper()
+	store := NewMemoryArticleStore()
+	base := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		article := NewsArticle{
+			Title:       fmt.Sprintf("article %d", i),
+			URL:         fmt.Sprintf("https://n.news.naver.com/mnews/article/015/000000000%d", i),
+			Source:      "한국경제",
+			SourceID:    NaverMainNewsSourceID,
+			CollectedAt: base.Add(time.Duration(i/2) * time.Hour), // Pairs share a timestamp
+		}
+		if i%3 == 0 {
+			article.AISummary = "요약"
+		}
+		if i == 4 {
+			article.SummaryRetryCount = 2
+			article.Tickers = []string{"005930"}
+			article.Source = "매일경제"
+			article.URL = "https://n.news.naver.com/mnews/article/009/0000000004"
+		}
+		article.OfficeID = officeIDFromURL(article.URL)
+		if err := store.SaveArticle(context.Background(), article); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestMemoryArticleStoreCursorPagination(t *testing.T) {
+	store := seedMemoryStore(t)
+	var titles []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination does not terminate")
+		}
+		page, err := store.ListArticles(context.Background(), ArticleQuery{Limit: 3, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range page.Articles {
+			titles = append(titles, a.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	want := []string{"article 6", "article 5", "article 4", "article 3", "article 2", "article 1", "article 0"}
+	if fmt.Sprint(titles) != fmt.Sprint(want) {
+		t.Errorf("titles = %v, want %v", titles, want)
+	}
+}
+
+func TestMemoryArticleStoreFilters(t *testing.T) {
+	store := seedMemoryStore(t)
+	yes, no := true, false
+	tests := []struct {
+		name  string
+		query ArticleQuery
+		want  int
+	}{
+		{"publisher name", ArticleQuery{Publisher: "매일경제"}, 1},
+		{"publisher office ID", ArticleQuery{Publisher: "015"}, 6},
+		{"ticker", ArticleQuery{Ticker: "005930"}, 1},
+		{"has AI summary", ArticleQuery{HasAISummary: &yes}, 3},
+		{"no AI summary", ArticleQuery{HasAISummary: &no}, 4},
+		{"retrying", ArticleQuery{SummaryStatus: SummaryStatusRetrying}, 1},
+		{"pending", ArticleQuery{SummaryStatus: SummaryStatusPending}, 3},
+		{"date range", ArticleQuery{From: time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC), To: time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)}, 4},
+		{"unknown source", ArticleQuery{SourceID: "nope"}, 0},
+	}
+	for _, tt := range tests {
+		page, err := store.ListArticles(context.Background(), tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(page.Articles) != tt.want {
+			t.Errorf("%s: got %d articles, want %d", tt.name, len(page.Articles), tt.want)
+		}
+	}
+
+	if _, err := store.ListArticles(context.Background(), ArticleQuery{Cursor: "not-a-cursor"}); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
+
+func TestArticlesEndpointQueryParameters(t *testing.T) {
+	store := seedMemoryStore(t)
+	app := fiber.New()
+	app.Get("/api/articles", func(c *fiber.Ctx) error {
+		return listArticlesHandler(c, store)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/articles?hasAISummary=false&limit=2&from=2024-05-10", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var page struct {
+		Articles   []map[string]any `json:"articles"`
+		NextCursor string           `json:"nextCursor"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+	if len(page.Articles) != 2 || page.NextCursor == "" {
+		t.Fatalf("unexpected page: %s", body)
+	}
+	// JSON field names follow the firestore tags.
+	if _, ok := page.Articles[0]["aiSummary"]; !ok {
+		t.Errorf("article JSON lacks aiSummary: %v", page.Articles[0])
+	}
+
+	// The cursor of the page reaches the remaining matches.
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/articles?hasAISummary=false&limit=2&from=2024-05-10&cursor="+url.QueryEscape(page.NextCursor), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	seen := page.Articles
+	page.Articles, page.NextCursor = nil, ""
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+	if seen = append(seen, page.Articles...); len(seen) != 4 || page.NextCursor != "" {
+		t.Fatalf("unexpected second page: %s", body)
+	}
+	for _, article := range seen {
+		if article["aiSummary"] != "" {
+			t.Errorf("hasAISummary=false returned %v", article)
+		}
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/articles?summaryStatus=unknown", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Syndication feed formats, selected by the extension of the feed URL.
+const (
+	FeedFormatRSS  = "rss"  // .xml or .rss
+	FeedFormatAtom = "atom" // .atom
+	FeedFormatJSON = "json" // .json (JSON Feed 1.1)
+)
+
+const (
+	feedSize      = 50  // Entries per feed
+	feedScanLimit = 500 // Recent articles scanned for search and watchlist feeds
+	feedMaxAge    = 300 // Cache-Control max-age of feed responses, in seconds
+)
+
+// feedContentTypes are the response content types of the feed formats.
+var feedContentTypes = map[string]string{
+	FeedFormatRSS:  "application/rss+xml; charset=utf-8",
+	FeedFormatAtom: "application/atom+xml; charset=utf-8",
+	FeedFormatJSON: "application/feed+json; charset=utf-8",
+}
+
+// feedMeta describes the feed being rendered.
+type feedMeta struct {
+	Title       string
+	Description string
+	SelfURL     string // Absolute URL of the feed itself
+	HomeURL     string
+}
+
+// splitFeedFile splits a feed file name such as "005930.atom" into its name and format.
+func splitFeedFile(file string) (name, format string, ok bool) {
+	file, err := url.PathUnescape(file)
+	if err != nil {
+		return "", "", false
+	}
+	i := strings.LastIndex(file, ".")
+	if i <= 0 {
+		return "", "", false
+	}
+	switch strings.ToLower(file[i+1:]) {
+	case "xml", "rss":
+		format = FeedFormatRSS
+	case "atom":
+		format = FeedFormatAtom
+	case "json":
+		format = FeedFormatJSON
+	default:
+		return "", "", false
+	}
+	return file[:i], format, true
+}
+
+// registerFeedRoutes registers the syndication feeds of the crawled articles:
+//
+//	/feeds/all.xml                      every source
+//	/feeds/search.xml?q=<expression>    keyword search (watchlist query syntax)
+//	/feeds/source/<sourceId>.xml
+//	/feeds/publisher/<name or office ID>.xml
+//	/feeds/ticker/<KRX code>.xml
+//	/feeds/watchlist/<rule ID>.xml      saved searches of the watchlist file
+//
+// Every feed is also available as .atom (Atom 1.0) and .json (JSON Feed 1.1).
+func registerFeedRoutes(app *fiber.App, s *NewsCrawlerService) {
+	app.Get("/feeds/:file", func(c *fiber.Ctx) error {
+		name, format, ok := splitFeedFile(c.Params("file"))
+		if !ok || (name != "all" && name != "search") {
+			return fiber.ErrNotFound
+		}
+		if name == "all" {
+			return s.serveFeed(c, format, feedMeta{Title: "News Crawler: all articles"}, ArticleQuery{}, nil)
+		}
+		q := strings.TrimSpace(c.Query("q"))
+		expr, err := compileWatchExpr(q)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid q: %v", err)})
+		}
+		match := func(a *NewsArticle) bool {
+			return expr.eval(strings.ToLower(a.Title + "\n" + a.Summary + "\n" + a.Content))
+		}
+		return s.serveFeed(c, format, feedMeta{Title: "News Crawler: " + q}, ArticleQuery{}, match)
+	})
+
+	app.Get("/feeds/:kind/:file", func(c *fiber.Ctx) error {
+		name, format, ok := splitFeedFile(c.Params("file"))
+		if !ok {
+			return fiber.ErrNotFound
+		}
+		s := s.Snapshot()
+		switch c.Params("kind") {
+		case "source":
+			if s.Sources[name] == nil && name != NaverMainNewsSourceID {
+				return fiber.ErrNotFound
+			}
+			return s.serveFeed(c, format, feedMeta{Title: "News Crawler: source " + name}, ArticleQuery{SourceID: name}, nil)
+		case "publisher":
+			return s.serveFeed(c, format, feedMeta{Title: "News Crawler: " + name}, ArticleQuery{Publisher: name}, nil)
+		case "ticker":
+			return s.serveFeed(c, format, feedMeta{Title: "News Crawler: ticker " + name}, ArticleQuery{Ticker: name}, nil)
+		case "watchlist":
+			if s.Watchlists == nil {
+				return fiber.ErrNotFound
+			}
+			for _, rule := range s.Watchlists.Rules {
+				if rule.ID == name {
+					return s.serveFeed(c, format, feedMeta{Title: "News Crawler: " + rule.displayName()}, ArticleQuery{}, rule.Matches)
+				}
+			}
+			return fiber.ErrNotFound
+		default:
+			return fiber.ErrNotFound
+		}
+	})
+}
+
+// serveFeed renders the newest articles of query (further filtered by match, when given) as a
+// feed. Responses carry an ETag and Last-Modified, so that feed readers polling an unchanged
+// feed get a 304 Not Modified.
+func (s *NewsCrawlerService) serveFeed(c *fiber.Ctx, format string, meta feedMeta, query ArticleQuery, match func(*NewsArticle) bool) error {
+	articles, err := recentArticles(c.Context(), s.Store, query, match)
+	if err != nil {
+		requestLogger(c).Error("Error listing articles for feed", "path", c.Path(), LogKeyError, err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Error listing articles.")
+	}
+	meta.SelfURL = c.BaseURL() + c.OriginalURL()
+	meta.HomeURL = c.BaseURL() + "/"
+	if meta.Description == "" {
+		meta.Description = "Financial news collected by the news crawler"
+	}
+	body, err := renderFeed(format, meta, articles)
+	if err != nil {
+		requestLogger(c).Error("Error rendering feed", "path", c.Path(), LogKeyError, err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Error rendering feed.")
+	}
+
+	sum := sha1.Sum(body)
+	c.Set(fiber.HeaderETag, `"`+hex.EncodeToString(sum[:12])+`"`)
+	if len(articles) > 0 {
+		c.Set(fiber.HeaderLastModified, latestCollectedAt(articles).UTC().Format(http.TimeFormat))
+	}
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", feedMaxAge))
+	if c.Fresh() {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderContentType, feedContentTypes[format])
+	return c.Send(body)
+}
+
+// recentArticles returns the newest feedSize articles of query. With match, up to feedScanLimit
+// recent articles are scanned for matching ones.
+func recentArticles(ctx context.Context, store ArticleStore, query ArticleQuery, match func(*NewsArticle) bool) ([]NewsArticle, error) {
+	if match == nil {
+		query.Limit = feedSize
+		page, err := store.ListArticles(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return page.Articles, nil
+	}
+	var articles []NewsArticle
+	query.Limit = MaxArticlePageSize
+	for scanned := 0; scanned < feedScanLimit && len(articles) < feedSize; {
+		page, err := store.ListArticles(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Articles {
+			if match(&page.Articles[i]) && len(articles) < feedSize {
+				articles = append(articles, page.Articles[i])
+			}
+		}
+		scanned += len(page.Articles)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	return articles, nil
+}
+
+func latestCollectedAt(articles []NewsArticle) time.Time {
+	var latest time.Time
+	for _, a := range articles {
+		if a.CollectedAt.After(latest) {
+			latest = a.CollectedAt
+		}
+	}
+	return latest
+}
+
+// entryDate is the date of a feed entry: the publication time when the source exposes it.
+func entryDate(a *NewsArticle) time.Time {
+	if !a.PublishedAt.IsZero() {
+		return a.PublishedAt
+	}
+	return a.CollectedAt
+}
+
+// entrySummary is the short text of a feed entry, preferring the AI summary.
+func entrySummary(a *NewsArticle) string {
+	if a.AISummary != "" {
+		return a.AISummary
+	}
+	return a.Summary
+}
+
+// entryHTML renders the body of a feed entry: the AI summary (when present), the lead image
+// and the article paragraphs.
+func entryHTML(a *NewsArticle) string {
+	var b strings.Builder
+	if a.AISummary != "" {
+		fmt.Fprintf(&b, "<p><strong>AI 요약</strong> %s</p>\n", html.EscapeString(a.AISummary))
+	}
+	if image := entryImage(a); image != "" {
+		fmt.Fprintf(&b, "<p><img src=\"%s\" alt=\"\"></p>\n", html.EscapeString(image))
+	}
+	body := a.Content
+	if body == "" {
+		body = a.Summary
+	}
+	for _, paragraph := range strings.Split(body, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(&b, "<p>%s</p>\n", strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>"))
+		}
+	}
+	return b.String()
+}
+
+// entryImage is the lead image of an entry, preferring the stored thumbnail.
+func entryImage(a *NewsArticle) string {
+	if a.ThumbnailURL != "" && strings.HasPrefix(a.ThumbnailURL, "http") {
+		return a.ThumbnailURL
+	}
+	return a.LeadImageURL
+}
+
+// entryAuthor is the author of an entry, falling back to the publisher.
+func entryAuthor(a *NewsArticle) string {
+	if a.Author != "" {
+		return a.Author
+	}
+	return a.Source
+}
+
+// entryTags are the categories and tickers of an entry.
+func entryTags(a *NewsArticle) []string {
+	tags := append([]string{}, a.Categories...)
+	return append(tags, a.Tickers...)
+}
+
+// renderFeed renders articles in the given feed format.
+func renderFeed(format string, meta feedMeta, articles []NewsArticle) ([]byte, error) {
+	updated := latestCollectedAt(articles)
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	switch format {
+	case FeedFormatRSS:
+		return renderRSS(meta, articles, updated)
+	case FeedFormatAtom:
+		return renderAtom(meta, articles, updated)
+	case FeedFormatJSON:
+		return renderJSONFeed(meta, articles)
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+}
+
+type rssOutput struct {
+	XMLName      xml.Name         `xml:"rss"`
+	Version      string           `xml:"version,attr"`
+	AtomNS       string           `xml:"xmlns:atom,attr"`
+	ContentNS    string           `xml:"xmlns:content,attr"`
+	DublinCoreNS string           `xml:"xmlns:dc,attr"`
+	Channel      rssOutputChannel `xml:"channel"`
+}
+
+type rssOutputChannel struct {
+	Title         string `xml:"title"`
+	Link          string `xml:"link"`
+	Description   string `xml:"description"`
+	Language      string `xml:"language"`
+	LastBuildDate string `xml:"lastBuildDate"`
+	SelfLink      struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"atom:link"`
+	Items []rssOutputItem `xml:"item"`
+}
+
+type rssOutputItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"content:encoded"`
+}
+
+func renderRSS(meta feedMeta, articles []NewsArticle, updated time.Time) ([]byte, error) {
+	doc := rssOutput{
+		Version:      "2.0",
+		AtomNS:       "http://www.w3.org/2005/Atom",
+		ContentNS:    "http://purl.org/rss/1.0/modules/content/",
+		DublinCoreNS: "http://purl.org/dc/elements/1.1/",
+	}
+	ch := &doc.Channel
+	ch.Title, ch.Link, ch.Description, ch.Language = meta.Title, meta.HomeURL, meta.Description, "ko"
+	ch.LastBuildDate = updated.Format(time.RFC1123Z)
+	ch.SelfLink.Href, ch.SelfLink.Rel, ch.SelfLink.Type = meta.SelfURL, "self", "application/rss+xml"
+	for i := range articles {
+		a := &articles[i]
+		ch.Items = append(ch.Items, rssOutputItem{
+			Title:       a.Title,
+			Link:        a.URL,
+			GUID:        a.URL,
+			PubDate:     entryDate(a).Format(time.RFC1123Z),
+			Creator:     entryAuthor(a),
+			Categories:  entryTags(a),
+			Description: entrySummary(a),
+			Content:     entryHTML(a),
+		})
+	}
+	return marshalFeedXML(doc)
+}
+
+type atomOutput struct {
+	XMLName xml.Name          `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string            `xml:"id"`
+	Title   string            `xml:"title"`
+	Updated string            `xml:"updated"`
+	Links   []atomOutputLink  `xml:"link"`
+	Entries []atomOutputEntry `xml:"entry"`
+}
+
+type atomOutputLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomOutputText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type atomOutputEntry struct {
+	ID         string           `xml:"id"`
+	Title      string           `xml:"title"`
+	Links      []atomOutputLink `xml:"link"`
+	Published  string           `xml:"published"`
+	Updated    string           `xml:"updated"`
+	AuthorName string           `xml:"author>name,omitempty"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Summary *atomOutputText `xml:"summary,omitempty"`
+	Content atomOutputText  `xml:"content"`
+}
+
+func renderAtom(meta feedMeta, articles []NewsArticle, updated time.Time) ([]byte, error) {
+	doc := atomOutput{
+		ID:      meta.SelfURL,
+		Title:   meta.Title,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomOutputLink{
+			{Href: meta.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: meta.HomeURL, Rel: "alternate"},
+		},
+	}
+	for i := range articles {
+		a := &articles[i]
+		entry := atomOutputEntry{
+			ID:         a.URL,
+			Title:      a.Title,
+			Links:      []atomOutputLink{{Href: a.URL, Rel: "alternate", Type: "text/html"}},
+			Published:  entryDate(a).Format(time.RFC3339),
+			Updated:    a.CollectedAt.Format(time.RFC3339),
+			AuthorName: entryAuthor(a),
+			Content:    atomOutputText{Type: "html", Text: entryHTML(a)},
+		}
+		if entry.AuthorName == "" {
+			entry.AuthorName = "News Crawler" // Atom requires an author
+		}
+		for _, tag := range entryTags(a) {
+			entry.Categories = append(entry.Categories, struct {
+				Term string `xml:"term,attr"`
+			}{tag})
+		}
+		if summary := entrySummary(a); summary != "" {
+			entry.Summary = &atomOutputText{Type: "text", Text: summary}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalFeedXML(doc)
+}
+
+func marshalFeedXML(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Language    string         `json:"language"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary,omitempty"`
+	ContentHTML   string           `json:"content_html"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+func renderJSONFeed(meta feedMeta, articles []NewsArticle) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       meta.Title,
+		HomePageURL: meta.HomeURL,
+		FeedURL:     meta.SelfURL,
+		Description: meta.Description,
+		Language:    "ko",
+		Items:       []jsonFeedItem{},
+	}
+	for i := range articles {
+		a := &articles[i]
+		item := jsonFeedItem{
+			ID:            a.URL,
+			URL:           a.URL,
+			Title:         a.Title,
+			Summary:       entrySummary(a),
+			ContentHTML:   entryHTML(a),
+			Image:         entryImage(a),
+			DatePublished: entryDate(a).Format(time.RFC3339),
+			DateModified:  a.CollectedAt.Format(time.RFC3339),
+			Tags:          entryTags(a),
+		}
+		if author := entryAuthor(a); author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: author}}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	body, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newFeedTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	store := seedMemoryStore(t)
+	published := NewsArticle{
+		Title:       "삼성전자 <반도체> 실적 & 전망",
+		URL:         "https://www.hankyung.com/article/2024051012345",
+		Source:      "한국경제",
+		SourceID:    "korean-business-feeds",
+		Content:     "첫 문단.\n\n둘째 문단.",
+		AISummary:   "삼성전자의 1분기 실적이 개선됐다.",
+		Tickers:     []string{"005930"},
+		PublishedAt: time.Date(2024, 5, 10, 6, 30, 0, 0, time.UTC),
+		CollectedAt: time.Date(2024, 5, 10, 20, 0, 0, 0, time.UTC),
+	}
+	if err := store.SaveArticle(context.Background(), published); err != nil {
+		t.Fatal(err)
+	}
+	service := &NewsCrawlerService{Store: store, Sources: map[string]*SourceDefinition{}}
+	app := fiber.New()
+	registerFeedRoutes(app, service)
+	return app
+}
+
+func getFeed(t *testing.T, app *fiber.App, target string, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req := httptest.NewRequest("GET", target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+func TestFeedFormats(t *testing.T) {
+	app := newFeedTestApp(t)
+
+	for _, target := range []string{"/feeds/all.xml", "/feeds/all.atom"} {
+		resp, body := getFeed(t, app, target, nil)
+		if resp.StatusCode != 200 {
+			t.Fatalf("%s: status %d", target, resp.StatusCode)
+		}
+		// Our own feed reader must understand the feeds we publish.
+		articles, err := parseFeed(body, resp.Header.Get("Content-Type"), FeedConfig{URL: target, Publisher: "self"})
+		if err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+		if len(articles) != 8 {
+			t.Fatalf("%s: %d entries, want 8", target, len(articles))
+		}
+		first := articles[0]
+		if first.Title != "삼성전자 <반도체> 실적 & 전망" || first.URL != "https://www.hankyung.com/article/2024051012345" {
+			t.Errorf("%s: first entry %q %s", target, first.Title, first.URL)
+		}
+		// PublishedAt is the entry date; the AI summary is the entry summary.
+		if !first.PublishedAt.Equal(time.Date(2024, 5, 10, 6, 30, 0, 0, time.UTC)) {
+			t.Errorf("%s: entry date %v", target, first.PublishedAt)
+		}
+		if first.Summary != "삼성전자의 1분기 실적이 개선됐다." {
+			t.Errorf("%s: entry summary %q", target, first.Summary)
+		}
+	}
+
+	resp, body := getFeed(t, app, "/feeds/all.json", nil)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/feed+json") {
+		t.Errorf("JSON Feed content type %q", ct)
+	}
+	var feed jsonFeed
+	if err := json.Unmarshal(body, &feed); err != nil {
+		t.Fatal(err)
+	}
+	item := feed.Items[0]
+	if feed.Version != "https://jsonfeed.org/version/1.1" || len(feed.Items) != 8 || item.DatePublished != "2024-05-10T06:30:00Z" ||
+		!strings.Contains(item.ContentHTML, "<strong>AI 요약</strong> 삼성전자의 1분기 실적이 개선됐다.") ||
+		!strings.Contains(item.ContentHTML, "<p>둘째 문단.</p>") || strings.Join(item.Tags, ",") != "005930" {
+		t.Errorf("unexpected JSON Feed:\n%s", body)
+	}
+}
+
+func TestFeedFilters(t *testing.T) {
+	app := newFeedTestApp(t)
+	tests := []struct {
+		target string
+		status int
+		items  int
+	}{
+		{"/feeds/ticker/005930.json", 200, 2},
+		{"/feeds/publisher/" + url.PathEscape("매일경제") + ".json", 200, 1},
+		{"/feeds/publisher/015.json", 200, 6},
+		{"/feeds/source/naver-mainnews.json", 200, 7},
+		{"/feeds/source/unknown.json", 404, 0},
+		{"/feeds/search.json?q=" + url.QueryEscape(`"article 1" OR "article 2"`), 200, 2},
+		{"/feeds/search.json?q=" + url.QueryEscape("(unbalanced"), 400, 0},
+		{"/feeds/watchlist/none.json", 404, 0},
+		{"/feeds/all.html", 404, 0},
+	}
+	for _, tt := range tests {
+		resp, body := getFeed(t, app, tt.target, nil)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.target, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+		var feed jsonFeed
+		if err := json.Unmarshal(body, &feed); err != nil {
+			t.Fatalf("%s: %v", tt.target, err)
+		}
+		if len(feed.Items) != tt.items {
+			t.Errorf("%s: %d items, want %d", tt.target, len(feed.Items), tt.items)
+		}
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	app := newFeedTestApp(t)
+	resp, _ := getFeed(t, app, "/feeds/all.xml", nil)
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" || lastModified != "Fri, 10 May 2024 20:00:00 GMT" {
+		t.Fatalf("ETag %q, Last-Modified %q", etag, lastModified)
+	}
+
+	resp, body := getFeed(t, app, "/feeds/all.xml", http.Header{"If-None-Match": {etag}})
+	if resp.StatusCode != fiber.StatusNotModified || len(body) != 0 {
+		t.Errorf("If-None-Match: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+	resp, _ = getFeed(t, app, "/feeds/all.xml", http.Header{"If-Modified-Since": {lastModified}})
+	if resp.StatusCode != fiber.StatusNotModified {
+		t.Errorf("If-Modified-Since: status %d", resp.StatusCode)
+	}
+	resp, _ = getFeed(t, app, "/feeds/all.xml", http.Header{"If-None-Match": {`"stale"`}})
+	if resp.StatusCode != 200 {
+		t.Errorf("stale ETag: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace exporters (tracing.exporter).
+const (
+	TracingExporterOTLP = "otlp" // OTLP over HTTP, to an OpenTelemetry collector or a tracing backend
+)
+
+// Attribute keys of the crawler spans, besides the OpenTelemetry semantic conventions.
+const (
+	TraceKeySource     = "crawler.source"
+	TraceKeyJobID      = "crawler.job_id"
+	TraceKeyPage       = "crawler.page" // List page number, or feed URL
+	TraceKeyArticleURL = "crawler.article_url"
+	TraceKeyAttempt    = "crawler.attempt"
+)
+
+// tracer creates the spans of the crawler. It uses the global tracer provider, installed by
+// setupTracing; spans are dropped until then.
+var tracer = otel.Tracer("news-crawler-app")
+
+// validateTracingConfig returns the errors of the tracing keys.
+func validateTracingConfig(cfg TracingConfig) []error {
+	var errs []error
+	if cfg.Exporter != "" && cfg.Exporter != TracingExporterOTLP {
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q is not supported (%s, or empty to disable tracing)", cfg.Exporter, TracingExporterOTLP))
+	}
+	if cfg.Endpoint != "" {
+		if u, err := url.Parse(cfg.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %q is not an http(s) URL", cfg.Endpoint))
+		}
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sampleRatio: must be between 0 and 1"))
+	}
+	if cfg.Exporter != "" && cfg.ServiceName == "" {
+		errs = append(errs, fmt.Errorf("tracing.serviceName: must be set"))
+	}
+	return errs
+}
+
+// setupTracing installs the W3C trace context propagator and, when an exporter is configured,
+// the global tracer provider exporting the spans. The returned function flushes the pending
+// spans and stops the exporter.
+func setupTracing(cfg TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the OTLP trace exporter: %v", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating the trace resource: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		componentLogger(LogComponentApp).Warn("OpenTelemetry error", LogKeyError, err)
+	}))
+	otel.SetTracerProvider(provider)
+	componentLogger(LogComponentApp).Info("Tracing enabled", "exporter", cfg.Exporter, "endpoint", cfg.Endpoint, "sample_ratio", cfg.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// tracingMiddleware starts a server span for every HTTP request, continuing the trace of the
+// caller's traceparent header, and makes it the user context of the request, so that the work
+// of the handlers (e.g. a crawl) is part of the request's trace.
+func tracingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carrier := propagation.HeaderCarrier{}
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			carrier.Set(string(key), string(value))
+		})
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+		ctx, span := tracer.Start(ctx, c.Method()+" "+c.Path(), trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(c.Method()), semconv.URLPath(c.Path())))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		status := responseStatus(c, err)
+		route := c.Route().Path
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status), attribute.String(LogKeyRequestID, requestID(c)))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		return err
+	}
+}
+
+// endSpan records err, if any, as the status of span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
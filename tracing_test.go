@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	testSpansOnce sync.Once
+	testSpans     *tracetest.InMemoryExporter
+)
+
+// recordSpans installs, once, a tracer provider recording the spans in memory, and clears them.
+func recordSpans() *tracetest.InMemoryExporter {
+	testSpansOnce.Do(func() {
+		testSpans = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(testSpans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	testSpans.Reset()
+	return testSpans
+}
+
+func TestCrawlTrace(t *testing.T) {
+	spans := recordSpans()
+	var timedOutOnce atomic.Bool
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>test</title>
+<item><title>첫 기사</title><link>http://%[1]s/a/1</link><description>요약 1</description></item>
+<item><title>둘째 기사</title><link>http://%[1]s/a/2</link><description>요약 2</description></item>
+</channel></rss>`, r.Host)
+		case "/a/2":
+			if timedOutOnce.CompareAndSwap(false, true) {
+				time.Sleep(300 * time.Millisecond) // The first request times out, and is retried
+				return
+			}
+			fallthrough
+		default:
+			if r.Header.Get("Traceparent") != "" {
+				t.Errorf("trace context sent to the crawled site: %s", r.Header.Get("Traceparent"))
+			}
+			fmt.Fprint(w, `<html><body><article><p>본문입니다.</p></article></body></html>`)
+		}
+	}))
+	defer site.Close()
+	receiver := &webhookReceiver{}
+	hooks := httptest.NewServer(receiver)
+	defer hooks.Close()
+
+	cfg := DefaultConfig()
+	cfg.ArticleStore = "memory"
+	cfg.ArticleDelay, cfg.ArticleRetryDelay, cfg.ArticleFetchTimeout = 0, 0, 100*time.Millisecond
+	s := NewNewsCrawlerService(cfg)
+	s.Store = instrumentArticleStore(NewMemoryArticleStore())
+	s.Webhooks = NewWebhookDispatcher(NewMemoryWebhookStore(), 3)
+	if _, err := s.Webhooks.Subscribe(context.Background(), WebhookSubscription{URL: hooks.URL, Events: []string{WebhookEventArticleCreated}}); err != nil {
+		t.Fatal(err)
+	}
+	def := &SourceDefinition{ID: "tracing-test", Type: SourceTypeFeed, Feeds: []FeedConfig{
+		{URL: site.URL + "/feed", Publisher: "테스트", Body: FieldSelector{Selector: "article"}},
+	}}
+
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(tracingMiddleware())
+	app.Post("/crawl", func(c *fiber.Ctx) error {
+		_, err := s.Job(c.UserContext(), JobInfo{ID: requestID(c), Trigger: RunTriggerManual}).CrawlSource(def, 1)
+		return err
+	})
+	const traceID, callerSpanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest("POST", "/crawl", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+callerSpanID+"-01")
+	resp, err := app.Test(req, -1)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /crawl: %v, %v", resp, err)
+	}
+	if _, err := s.Webhooks.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := map[string][]tracetest.SpanStub{}
+	byID := map[string]tracetest.SpanStub{}
+	for _, span := range spans.GetSpans() {
+		if span.SpanContext.TraceID().String() != traceID {
+			t.Errorf("span %s is not part of the request's trace", span.Name)
+		}
+		byName[span.Name] = append(byName[span.Name], span)
+		byID[span.SpanContext.SpanID().String()] = span
+	}
+	parentName := func(span tracetest.SpanStub) string {
+		if span.Parent.SpanID().String() == callerSpanID {
+			return "caller"
+		}
+		return byID[span.Parent.SpanID().String()].Name
+	}
+	for name, want := range map[string]struct {
+		count  int
+		parent string
+	}{
+		"POST /crawl":        {1, "caller"},
+		"crawl tracing-test": {1, "POST /crawl"},
+		"feed":               {1, "crawl tracing-test"},
+		"parse feed":         {1, "feed"},
+		"article":            {2, "feed"},
+		"parse article":      {2, "article"},
+		"store.get":          {2, "feed"},
+		"store.save":         {2, "feed"},
+		"webhook.deliver":    {2, "feed"},
+	} {
+		if got := byName[name]; len(got) != want.count {
+			t.Errorf("%d %q spans, want %d", len(got), name, want.count)
+		} else if parent := parentName(got[0]); parent != want.parent {
+			t.Errorf("parent of %q = %q, want %q", name, parent, want.parent)
+		}
+	}
+	if got := len(byName["HTTP GET"]); got != 4 {
+		t.Errorf("%d fetch spans, want 4 (feed, two articles, one retry)", got)
+	}
+	retries := 0
+	for _, span := range byName["article"] {
+		for _, event := range span.Events {
+			if event.Name == "retry" {
+				retries++
+			}
+		}
+	}
+	if retries != 1 {
+		t.Errorf("%d retry events, want 1", retries)
+	}
+
+	if len(receiver.requests) != 2 {
+		t.Fatalf("receiver got %d requests", len(receiver.requests))
+	}
+	for _, r := range receiver.requests {
+		if traceparent := r.Header.Get("traceparent"); !strings.HasPrefix(traceparent, "00-"+traceID+"-") {
+			t.Errorf("webhook traceparent = %q", traceparent)
+		}
+	}
+}
+
+func TestTracingConfigValidation(t *testing.T) {
+	cfg := DefaultConfig().Tracing
+	cfg.Exporter, cfg.Endpoint, cfg.SampleRatio, cfg.ServiceName = "jaeger", "localhost:4318", 1.5, ""
+	if errs := validateTracingConfig(cfg); len(errs) != 4 {
+		t.Errorf("got %d errors, want 4: %v", len(errs), errs)
+	}
+
+	loaded, err := loadConfigLayers(ConfigSource{Overrides: []string{"tracing.exporter=otlp", "tracing.endpoint=http://collector:4318"}})
+	if err != nil || loaded.Tracing.Exporter != TracingExporterOTLP || loaded.Tracing.SampleRatio != 1 || loaded.Tracing.ServiceName != "news-crawler" {
+		t.Errorf("-set tracing: %+v (%v)", loaded.Tracing, err)
+	}
+}
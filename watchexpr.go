@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// watchExpr is a compiled watchlist keyword expression.
+type watchExpr interface {
+	eval(text string) bool // text is lower-cased
+}
+
+type (
+	watchTerm string // Case-insensitive substring
+	watchNot  struct{ expr watchExpr }
+	watchAnd  []watchExpr
+	watchOr   []watchExpr
+)
+
+func (t watchTerm) eval(text string) bool { return strings.Contains(text, string(t)) }
+func (n watchNot) eval(text string) bool  { return !n.expr.eval(text) }
+
+func (a watchAnd) eval(text string) bool {
+	for _, expr := range a {
+		if !expr.eval(text) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o watchOr) eval(text string) bool {
+	for _, expr := range o {
+		if expr.eval(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileWatchExpr compiles a boolean keyword expression such as
+//
+//	삼성전자 AND (실적 OR "영업이익") AND NOT 루머
+//
+// Terms match case-insensitively anywhere in the title, summary or content. Operators are
+// AND (also &&, or simply juxtaposition), OR (||) and NOT (!, or a leading -), with
+// parentheses for grouping; NOT binds tighter than AND, which binds tighter than OR.
+// Double quotes make a phrase, or a term out of an operator word.
+func compileWatchExpr(query string) (watchExpr, error) {
+	tokens, err := tokenizeWatchExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &watchParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in query", p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+type watchToken struct {
+	text   string
+	quoted bool
+}
+
+func (t watchToken) is(op string) bool { return !t.quoted && t.text == op }
+
+// tokenizeWatchExpr splits a query into terms, quoted phrases, parentheses and operators.
+func tokenizeWatchExpr(query string) ([]watchToken, error) {
+	var tokens []watchToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, watchToken{text: string(r)})
+			i++
+		case r == '!' || (r == '-' && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '(')):
+			tokens = append(tokens, watchToken{text: "NOT"})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote in query")
+			}
+			phrase := strings.TrimSpace(string(runes[i+1 : end]))
+			if phrase == "" {
+				return nil, fmt.Errorf("empty phrase in query")
+			}
+			tokens = append(tokens, watchToken{text: phrase, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			switch word {
+			case "&&":
+				word = "AND"
+			case "||":
+				word = "OR"
+			}
+			tokens = append(tokens, watchToken{text: word})
+			i = end
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	return tokens, nil
+}
+
+// watchParser is a recursive descent parser over the tokens of a query.
+type watchParser struct {
+	tokens []watchToken
+	pos    int
+}
+
+func (p *watchParser) peek() (watchToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return watchToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// parseOr parses: and ("OR" and)*
+func (p *watchParser) parseOr() (watchExpr, error) {
+	var terms watchOr
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, expr)
+		if tok, ok := p.peek(); !ok || !tok.is("OR") {
+			break
+		}
+		p.pos++
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parseAnd parses: not ("AND"? not)*
+func (p *watchParser) parseAnd() (watchExpr, error) {
+	var terms watchAnd
+	for {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, expr)
+		tok, ok := p.peek()
+		if !ok || tok.is("OR") || tok.is(")") {
+			break
+		}
+		if tok.is("AND") {
+			p.pos++
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parseNot parses: "NOT" not | "(" or ")" | term
+func (p *watchParser) parseNot() (watchExpr, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("query ends with an operator")
+	}
+	p.pos++
+	switch {
+	case tok.is("NOT"):
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return watchNot{expr}, nil
+	case tok.is("("):
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || !closing.is(")") {
+			return nil, fmt.Errorf("missing ) in query")
+		}
+		p.pos++
+		return expr, nil
+	case tok.is(")") || tok.is("AND") || tok.is("OR"):
+		return nil, fmt.Errorf("unexpected %q in query", tok.text)
+	default:
+		return watchTerm(strings.ToLower(tok.text)), nil
+	}
+}
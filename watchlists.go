@@ -0,0 +1,593 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"cloud.google.com/go/firestore"
+	"gopkg.in/yaml.v3"
+)
+
+// Notification channel types.
+const (
+	ChannelWebhook  = "webhook"  // Generic JSON POST of the alert
+	ChannelSlack    = "slack"    // Slack-compatible incoming webhook ({"text": ...})
+	ChannelTelegram = "telegram" // Telegram bot API sendMessage
+	ChannelEmail    = "email"    // SMTP email
+)
+
+// DefaultWatchlistDedupWindow is the period during which a story alerts a channel only once.
+const DefaultWatchlistDedupWindow = 6 * time.Hour
+
+// WatchlistFile is the YAML (or JSON) watchlist configuration. ${VAR} references are
+// expanded from the environment, so that channel secrets can stay out of the file.
+type WatchlistFile struct {
+	DedupWindow string          `json:"dedupWindow,omitempty" yaml:"dedupWindow,omitempty"` // Go duration, default 6h
+	Channels    []ChannelConfig `json:"channels" yaml:"channels"`
+	Rules       []WatchlistRule `json:"rules" yaml:"rules"`
+}
+
+// ChannelConfig configures a notification channel. The fields used depend on Type.
+type ChannelConfig struct {
+	Name     string   `json:"name" yaml:"name"`
+	Type     string   `json:"type" yaml:"type"`                             // ChannelWebhook, ChannelSlack, ChannelTelegram or ChannelEmail
+	URL      string   `json:"url,omitempty" yaml:"url,omitempty"`           // webhook, slack
+	Token    string   `json:"token,omitempty" yaml:"token,omitempty"`       // telegram bot token
+	ChatID   string   `json:"chatId,omitempty" yaml:"chatId,omitempty"`     // telegram
+	APIBase  string   `json:"apiBase,omitempty" yaml:"apiBase,omitempty"`   // telegram, default https://api.telegram.org
+	SMTPAddr string   `json:"smtpAddr,omitempty" yaml:"smtpAddr,omitempty"` // email, host:port
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"` // email, optional SMTP auth
+	Password string   `json:"password,omitempty" yaml:"password,omitempty"`
+	From     string   `json:"from,omitempty" yaml:"from,omitempty"`
+	To       []string `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// WatchlistRule selects the articles an analyst wants to be alerted about.
+// All the criteria that are set must match.
+type WatchlistRule struct {
+	ID           string   `json:"id" yaml:"id"`
+	Name         string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Query        string   `json:"query,omitempty" yaml:"query,omitempty"`               // Boolean keyword expression (see compileWatchExpr)
+	Tickers      []string `json:"tickers,omitempty" yaml:"tickers,omitempty"`           // Any of these KRX codes
+	Sources      []string `json:"sources,omitempty" yaml:"sources,omitempty"`           // Any of these source IDs
+	MinSentiment *float64 `json:"minSentiment,omitempty" yaml:"minSentiment,omitempty"` // Minimum NewsArticle.Sentiment, -1 to 1; unscored articles never match
+	Channels     []string `json:"channels" yaml:"channels"`                             // Names of the channels to notify
+
+	expr watchExpr
+}
+
+// Matches reports whether the article satisfies the rule.
+func (r *WatchlistRule) Matches(article *NewsArticle) bool {
+	if len(r.Sources) > 0 && !containsString(r.Sources, article.SourceID) {
+		return false
+	}
+	if len(r.Tickers) > 0 {
+		found := false
+		for _, ticker := range r.Tickers {
+			if containsString(article.Tickers, ticker) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.MinSentiment != nil && (article.Sentiment == nil || *article.Sentiment < *r.MinSentiment) {
+		return false
+	}
+	if r.expr != nil {
+		text := strings.ToLower(article.Title + "\n" + article.Summary + "\n" + article.Content)
+		if !r.expr.eval(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// displayName is the name shown in notifications.
+func (r *WatchlistRule) displayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.ID
+}
+
+// Alert is a notification for an article matched by one or more rules.
+type Alert struct {
+	Rules   []string    `json:"rules"` // Names of the matched rules
+	Article NewsArticle `json:"article"`
+}
+
+// NotificationChannel delivers alerts.
+type NotificationChannel interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// Watchlists evaluates the watchlist rules against newly saved articles and notifies their channels.
+type Watchlists struct {
+	Rules       []*WatchlistRule
+	Channels    map[string]NotificationChannel
+	DedupWindow time.Duration
+	Dedup       AlertDedupStore // Stories already alerted, per channel
+
+	now func() time.Time
+}
+
+// NewWatchlists creates Watchlists from compiled rules and channels.
+func NewWatchlists(rules []*WatchlistRule, channels map[string]NotificationChannel, dedupWindow time.Duration) *Watchlists {
+	if dedupWindow <= 0 {
+		dedupWindow = DefaultWatchlistDedupWindow
+	}
+	return &Watchlists{
+		Rules:       rules,
+		Channels:    channels,
+		DedupWindow: dedupWindow,
+		Dedup:       NewMemoryAlertDedupStore(),
+		now:         time.Now,
+	}
+}
+
+// LoadWatchlists loads a watchlist configuration file. A missing file yields nil (watchlists disabled).
+func LoadWatchlists(path string, client *http.Client) (*Watchlists, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading watchlists %s: %v", path, err)
+	}
+	data = []byte(os.Expand(string(data), func(name string) string { return os.Getenv(name) }))
+
+	var file WatchlistFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding watchlists %s: %v", path, err)
+	}
+	w, err := file.compile(client)
+	if err != nil {
+		return nil, fmt.Errorf("invalid watchlists %s: %v", path, err)
+	}
+	return w, nil
+}
+
+// compile validates the configuration and builds its channels and rule expressions.
+func (f *WatchlistFile) compile(client *http.Client) (*Watchlists, error) {
+	var window time.Duration
+	if f.DedupWindow != "" {
+		d, err := time.ParseDuration(f.DedupWindow)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid dedupWindow %q", f.DedupWindow)
+		}
+		window = d
+	}
+
+	channels := map[string]NotificationChannel{}
+	for _, cfg := range f.Channels {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("channel name is required")
+		}
+		if _, ok := channels[cfg.Name]; ok {
+			return nil, fmt.Errorf("channel %q is defined twice", cfg.Name)
+		}
+		channel, err := newNotificationChannel(cfg, client)
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %v", cfg.Name, err)
+		}
+		channels[cfg.Name] = channel
+	}
+
+	seen := map[string]bool{}
+	rules := make([]*WatchlistRule, 0, len(f.Rules))
+	for i := range f.Rules {
+		rule := f.Rules[i]
+		if rule.ID == "" {
+			return nil, fmt.Errorf("rule %d: id is required", i)
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("rule %q is defined twice", rule.ID)
+		}
+		seen[rule.ID] = true
+		if rule.Query == "" && len(rule.Tickers) == 0 && len(rule.Sources) == 0 && rule.MinSentiment == nil {
+			return nil, fmt.Errorf("rule %q: a query, tickers, sources or minSentiment is required", rule.ID)
+		}
+		if rule.Query != "" {
+			expr, err := compileWatchExpr(rule.Query)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %v", rule.ID, err)
+			}
+			rule.expr = expr
+		}
+		if len(rule.Channels) == 0 {
+			return nil, fmt.Errorf("rule %q: at least one channel is required", rule.ID)
+		}
+		for _, name := range rule.Channels {
+			if _, ok := channels[name]; !ok {
+				return nil, fmt.Errorf("rule %q: unknown channel %q", rule.ID, name)
+			}
+		}
+		rules = append(rules, &rule)
+	}
+	return NewWatchlists(rules, channels, window), nil
+}
+
+// ChannelAlert is an alert due for one notification channel.
+type ChannelAlert struct {
+	Channel string
+	Alert   Alert
+}
+
+// Evaluate returns the alerts for a newly saved article: one per channel, listing every rule
+// that matched. A channel already alerted about the same story (the same URL, or the same
+// normalized title from another source) within the dedup window is skipped. When the dedup
+// store fails, the alert is sent: a repeated alert is better than a missed one.
+func (w *Watchlists) Evaluate(ctx context.Context, article NewsArticle) []ChannelAlert {
+	byChannel := map[string][]string{}
+	var order []string
+	for _, rule := range w.Rules {
+		if !rule.Matches(&article) {
+			continue
+		}
+		for _, channel := range rule.Channels {
+			if _, ok := byChannel[channel]; !ok {
+				order = append(order, channel)
+			}
+			byChannel[channel] = append(byChannel[channel], rule.displayName())
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	logger := contextLogger(ctx, LogComponentWatchlists).With(LogKeyArticleURL, article.URL)
+	now := w.now()
+	stories := storyKeys(&article)
+	var alerts []ChannelAlert
+	for _, channel := range order {
+		keys := make([]string, len(stories))
+		for i, key := range stories {
+			keys[i] = channel + "|" + key
+		}
+		claimed, err := w.Dedup.ClaimAlert(ctx, keys, now, w.DedupWindow)
+		if err != nil {
+			logger.Warn("Watchlist alert dedup failed, alerting anyway", "channel", channel, LogKeyError, err)
+		} else if !claimed {
+			logger.Info("Watchlist alert suppressed as a duplicate story", "channel", channel, "title", article.Title)
+			continue
+		}
+		alerts = append(alerts, ChannelAlert{Channel: channel, Alert: Alert{Rules: byChannel[channel], Article: article}})
+	}
+	return alerts
+}
+
+// Send delivers the alerts, logging failures.
+func (w *Watchlists) Send(ctx context.Context, alerts []ChannelAlert) {
+	for _, pending := range alerts {
+		channel := w.Channels[pending.Channel]
+		if channel == nil {
+			continue
+		}
+		logger := contextLogger(ctx, LogComponentWatchlists).With("channel", pending.Channel, LogKeyArticleURL, pending.Alert.Article.URL)
+		if err := channel.Send(ctx, pending.Alert); err != nil {
+			logger.Warn("Watchlist alert failed", LogKeyError, err)
+			continue
+		}
+		logger.Info("Watchlist alert sent", "rules", pending.Alert.Rules, "title", pending.Alert.Article.Title)
+	}
+}
+
+// checkWatchlists evaluates the watchlists against a newly saved article, if configured,
+// and sends the alerts in the background so that slow channels never hold up the crawl.
+func (s *NewsCrawlerService) checkWatchlists(ctx context.Context, article NewsArticle) {
+	if s.Watchlists == nil {
+		return
+	}
+	pending := &s.root().alerts
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+		// Not canceled with the crawl, but keeping its log fields.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		if alerts := s.Watchlists.Evaluate(ctx, article); len(alerts) > 0 {
+			s.Watchlists.Send(ctx, alerts)
+		}
+	}()
+}
+
+// AlertDedupStore records the stories alerted to each channel. Kept in Firestore, the dedup
+// window holds across restarts and between the instances of the service.
+type AlertDedupStore interface {
+	// ClaimAlert records keys as alerted at now, unless one of them was recorded less than
+	// window ago. It reports whether the alert may be sent.
+	ClaimAlert(ctx context.Context, keys []string, now time.Time, window time.Duration) (bool, error)
+}
+
+// alertDedupCollection is the Firestore collection of the alerted stories. Its expiresAt
+// field is a TTL policy (see firestore.indexes.json), which purges the expired records.
+const alertDedupCollection = "alertDedup"
+
+// alertDedupRecord is a story alerted to a channel.
+type alertDedupRecord struct {
+	Key       string    `firestore:"key"` // Channel and story key
+	AlertedAt time.Time `firestore:"alertedAt"`
+	ExpiresAt time.Time `firestore:"expiresAt"`
+}
+
+// FirestoreAlertDedupStore is the AlertDedupStore backed by Firestore.
+type FirestoreAlertDedupStore struct {
+	FirestoreArticleStore // Shares the client setup
+}
+
+// ClaimAlert implements AlertDedupStore. The keys are checked and recorded in a transaction,
+// so that two instances crawling the same story alert once.
+func (s *FirestoreAlertDedupStore) ClaimAlert(ctx context.Context, keys []string, now time.Time, window time.Duration) (bool, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	refs := make([]*firestore.DocumentRef, len(keys))
+	for i, key := range keys {
+		sum := sha1.Sum([]byte(key)) // Keys contain URLs, which are not valid document IDs
+		refs[i] = client.Collection(alertDedupCollection).Doc(hex.EncodeToString(sum[:]))
+	}
+	var claimed bool
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = false
+		docs, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			var record alertDedupRecord
+			if doc.Exists() && doc.DataTo(&record) == nil && now.Sub(record.AlertedAt) < window {
+				return nil
+			}
+		}
+		for i, ref := range refs {
+			if err := tx.Set(ref, alertDedupRecord{Key: keys[i], AlertedAt: now, ExpiresAt: now.Add(window)}); err != nil {
+				return err
+			}
+		}
+		claimed = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("error recording watchlist alert: %v", err)
+	}
+	return claimed, nil
+}
+
+// MemoryAlertDedupStore is an in-memory AlertDedupStore, lost on restart.
+type MemoryAlertDedupStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // Key -> time the channel was alerted
+}
+
+// NewMemoryAlertDedupStore creates an empty MemoryAlertDedupStore.
+func NewMemoryAlertDedupStore() *MemoryAlertDedupStore {
+	return &MemoryAlertDedupStore{seen: map[string]time.Time{}}
+}
+
+// ClaimAlert implements AlertDedupStore.
+func (s *MemoryAlertDedupStore) ClaimAlert(ctx context.Context, keys []string, now time.Time, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, at := range s.seen {
+		if now.Sub(at) >= window {
+			delete(s.seen, key)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := s.seen[key]; ok {
+			return false, nil
+		}
+	}
+	for _, key := range keys {
+		s.seen[key] = now
+	}
+	return true, nil
+}
+
+// WaitForAlerts waits until the watchlist alerts being sent are done, so that one-shot
+// commands do not exit before their alerts are delivered.
+func (s *NewsCrawlerService) WaitForAlerts() {
+	s.root().alerts.Wait()
+}
+
+// storyTagPattern matches the bracketed tags news titles start with (e.g. [속보], [단독], (종합)).
+var storyTagPattern = regexp.MustCompile(`^\s*(?:[\[(【<][^\])】>]{1,10}[\])】>]\s*)+`)
+
+// storyKeys returns the dedup keys of an article: its URL and its normalized title, so that
+// the same story syndicated by several sources, or re-sent with a tag like [종합], is one story.
+func storyKeys(article *NewsArticle) []string {
+	var keys []string
+	if article.URL != "" {
+		keys = append(keys, "url:"+article.URL)
+	}
+	title := storyTagPattern.ReplaceAllString(article.Title, "")
+	normalized := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+	if len([]rune(normalized)) >= 8 { // Too short titles would merge unrelated stories
+		sum := sha1.Sum([]byte(normalized))
+		keys = append(keys, "title:"+hex.EncodeToString(sum[:8]))
+	}
+	return keys
+}
+
+// alertText renders an alert as plain text for chat and email channels.
+func alertText(alert Alert) string {
+	a := alert.Article
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s\n", strings.Join(alert.Rules, ", "), a.Title)
+	meta := a.Source
+	if len(a.Tickers) > 0 {
+		meta += " · " + strings.Join(a.Tickers, ", ")
+	}
+	if meta != "" {
+		b.WriteString(meta + "\n")
+	}
+	if a.Summary != "" {
+		b.WriteString(a.Summary + "\n")
+	}
+	b.WriteString(a.URL)
+	return b.String()
+}
+
+// newNotificationChannel builds a channel from its configuration.
+func newNotificationChannel(cfg ChannelConfig, client *http.Client) (NotificationChannel, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	switch cfg.Type {
+	case ChannelWebhook, ChannelSlack:
+		if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+			return nil, fmt.Errorf("an http(s) url is required")
+		}
+		if cfg.Type == ChannelSlack {
+			return &SlackChannel{URL: cfg.URL, Client: client}, nil
+		}
+		return &WebhookChannel{URL: cfg.URL, Client: client}, nil
+	case ChannelTelegram:
+		if cfg.Token == "" || cfg.ChatID == "" {
+			return nil, fmt.Errorf("token and chatId are required")
+		}
+		apiBase := cfg.APIBase
+		if apiBase == "" {
+			apiBase = "https://api.telegram.org"
+		}
+		return &TelegramChannel{APIBase: strings.TrimRight(apiBase, "/"), Token: cfg.Token, ChatID: cfg.ChatID, Client: client}, nil
+	case ChannelEmail:
+		if cfg.SMTPAddr == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("smtpAddr, from and to are required")
+		}
+		channel := &EmailChannel{Addr: cfg.SMTPAddr, From: cfg.From, To: cfg.To, sendMail: smtp.SendMail}
+		if cfg.Username != "" {
+			host := cfg.SMTPAddr
+			if i := strings.LastIndex(host, ":"); i >= 0 {
+				host = host[:i]
+			}
+			channel.Auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+		}
+		return channel, nil
+	default:
+		return nil, fmt.Errorf("unknown channel type %q", cfg.Type)
+	}
+}
+
+// postJSON POSTs a JSON body and treats any non-2xx response as an error.
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// WebhookChannel POSTs the alert as JSON: {"rules": [...], "article": {...}}.
+type WebhookChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send implements NotificationChannel.
+func (c *WebhookChannel) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, c.Client, c.URL, alert)
+}
+
+// SlackChannel posts to a Slack-compatible incoming webhook (Slack, Mattermost, Rocket.Chat...).
+type SlackChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send implements NotificationChannel.
+func (c *SlackChannel) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, c.Client, c.URL, map[string]string{"text": alertText(alert)})
+}
+
+// TelegramChannel sends a message through the Telegram bot API.
+type TelegramChannel struct {
+	APIBase string
+	Token   string
+	ChatID  string
+	Client  *http.Client
+}
+
+// Send implements NotificationChannel.
+func (c *TelegramChannel) Send(ctx context.Context, alert Alert) error {
+	err := postJSON(ctx, c.Client, c.APIBase+"/bot"+c.Token+"/sendMessage", map[string]string{
+		"chat_id": c.ChatID,
+		"text":    alertText(alert),
+	})
+	if err != nil {
+		// Do not leak the bot token through the logged URL.
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), c.Token, "***"))
+	}
+	return nil
+}
+
+// EmailChannel sends alerts by SMTP email.
+type EmailChannel struct {
+	Addr string // host:port
+	Auth smtp.Auth
+	From string
+	To   []string
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Send implements NotificationChannel.
+func (c *EmailChannel) Send(ctx context.Context, alert Alert) error {
+	subject := fmt.Sprintf("[%s] %s", strings.Join(alert.Rules, ", "), alert.Article.Title)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(alertText(alert), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return c.sendMail(c.Addr, c.Auth, c.From, c.To, msg.Bytes())
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileWatchExpr(t *testing.T) {
+	text := strings.ToLower("삼성전자, 1분기 영업이익 6.6조원… K-반도체 Turnaround 기대")
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"삼성전자", true},
+		{"삼성전자 AND 영업이익", true},
+		{"삼성전자 영업이익", true}, // Juxtaposition is AND
+		{"삼성전자 AND 적자", false},
+		{"적자 OR 영업이익", true},
+		{"삼성전자 AND NOT 루머", true},
+		{"삼성전자 -반도체", false},
+		{"!(적자 || 손실) && turnaround", true},
+		{`"영업이익 6.6조"`, true},
+		{`"영업이익 7조"`, false},
+		{"(LG전자 OR 삼성전자) AND (실적 OR 영업이익)", true},
+		{"적자 OR 손실 AND 삼성전자", false}, // AND binds tighter than OR
+		{`"OR"`, false},              // Quoted operator words are terms
+		{"K-반도체", true},              // A dash inside a word is not NOT
+	}
+	for _, tt := range tests {
+		expr, err := compileWatchExpr(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := expr.eval(text); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"", "삼성전자 AND", "(삼성전자", "삼성전자)", `"삼성전자`, "OR 실적", "NOT"} {
+		if _, err := compileWatchExpr(query); err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+}
+
+func TestScoreSentiment(t *testing.T) {
+	tests := []struct {
+		article NewsArticle
+		check   func(float64) bool
+	}{
+		{NewsArticle{Title: "SK하이닉스, 사상 최대 실적에 신고가"}, func(s float64) bool { return s == 1 }},
+		{NewsArticle{Title: "코스피 급락… 외국인 순매도"}, func(s float64) bool { return s == -1 }},
+		{NewsArticle{Title: "수출 상승세 둔화"}, func(s float64) bool { return s == -1 }},
+		{NewsArticle{Title: "한국은행 기준금리 동결"}, func(s float64) bool { return s == 0 }},
+		{NewsArticle{Title: "실적 개선", Content: "적자 우려 손실 감소"}, func(s float64) bool { return s < 0 }},
+	}
+	for _, tt := range tests {
+		if got := scoreSentiment(&tt.article); !tt.check(*got) {
+			t.Errorf("%s / %s: sentiment %g", tt.article.Title, tt.article.Content, *got)
+		}
+	}
+}
+
+func TestLoadWatchlistsExample(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T/B/X")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123:abc")
+	t.Setenv("TELEGRAM_CHAT_ID", "-100200")
+	w, err := LoadWatchlists("watchlists.example.yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Rules) != 3 || len(w.Channels) != 4 || w.DedupWindow != 6*time.Hour {
+		t.Errorf("loaded %d rules, %d channels, window %v", len(w.Rules), len(w.Channels), w.DedupWindow)
+	}
+	if tg := w.Channels["analyst-telegram"].(*TelegramChannel); tg.Token != "123:abc" || tg.ChatID != "-100200" {
+		t.Errorf("environment not expanded: %+v", tg)
+	}
+
+	if w, err := LoadWatchlists("testdata/no-such-watchlists.yaml", nil); w != nil || err != nil {
+		t.Errorf("missing file: %v, %v", w, err)
+	}
+}
+
+func TestWatchlistFileValidation(t *testing.T) {
+	tests := map[string]WatchlistFile{
+		"unknown channel": {Rules: []WatchlistRule{{ID: "a", Query: "x", Channels: []string{"nope"}}}},
+		"no criteria": {
+			Channels: []ChannelConfig{{Name: "c", Type: ChannelWebhook, URL: "http://example.com"}},
+			Rules:    []WatchlistRule{{ID: "a", Channels: []string{"c"}}},
+		},
+		"bad query": {
+			Channels: []ChannelConfig{{Name: "c", Type: ChannelWebhook, URL: "http://example.com"}},
+			Rules:    []WatchlistRule{{ID: "a", Query: "(x", Channels: []string{"c"}}},
+		},
+		"telegram without token": {Channels: []ChannelConfig{{Name: "c", Type: ChannelTelegram, ChatID: "1"}}},
+		"unknown type":           {Channels: []ChannelConfig{{Name: "c", Type: "pager"}}},
+		"bad window":             {DedupWindow: "soon"},
+	}
+	for name, file := range tests {
+		if _, err := file.compile(nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// recordingChannel records the alerts it is sent.
+type recordingChannel struct{ alerts []Alert }
+
+func (c *recordingChannel) Send(ctx context.Context, alert Alert) error {
+	c.alerts = append(c.alerts, alert)
+	return nil
+}
+
+func TestWatchlistsDeduplicateStories(t *testing.T) {
+	minSentiment, neutral, negativeScore := 0.0, 0.0, -0.5
+	file := WatchlistFile{
+		Channels: []ChannelConfig{
+			{Name: "slack", Type: ChannelSlack, URL: "http://example.com"},
+			{Name: "mail", Type: ChannelWebhook, URL: "http://example.com"},
+		},
+		Rules: []WatchlistRule{
+			{ID: "samsung", Name: "삼성전자", Query: "삼성전자", Channels: []string{"slack", "mail"}},
+			{ID: "ticker", Tickers: []string{"005930"}, MinSentiment: &minSentiment, Channels: []string{"slack"}},
+			{ID: "other-source", Query: "삼성전자", Sources: []string{"korean-business-feeds"}, Channels: []string{"mail"}},
+		},
+	}
+	w, err := file.compile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return clock }
+
+	article := NewsArticle{
+		Title:     "삼성전자, 1분기 영업이익 6.6조원",
+		URL:       "https://n.news.naver.com/mnews/article/015/0000000001",
+		SourceID:  NaverMainNewsSourceID,
+		Tickers:   []string{"005930"},
+		Sentiment: &neutral,
+	}
+	alerts := w.Evaluate(context.Background(), article)
+	if len(alerts) != 2 || alerts[0].Channel != "slack" || alerts[1].Channel != "mail" {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	// Both rules matching for the Slack channel make a single alert.
+	if got := strings.Join(alerts[0].Alert.Rules, ","); got != "삼성전자,ticker" {
+		t.Errorf("slack alert rules = %s", got)
+	}
+
+	// The same story from another outlet, re-titled with a tag, does not alert again.
+	syndicated := article
+	syndicated.Title = "[종합] 삼성전자 1분기 영업이익 6.6조원"
+	syndicated.URL = "https://www.hankyung.com/article/2024051000001"
+	syndicated.SourceID = "korean-business-feeds"
+	if alerts := w.Evaluate(context.Background(), syndicated); len(alerts) != 0 {
+		t.Errorf("duplicate story alerted: %+v", alerts)
+	}
+
+	// A negative story fails the ticker rule's minimum sentiment, but is a new story.
+	negative := article
+	negative.Title = "삼성전자 노조 파업 돌입"
+	negative.URL = "https://n.news.naver.com/mnews/article/015/0000000002"
+	negative.Sentiment = &negativeScore
+	if alerts := w.Evaluate(context.Background(), negative); len(alerts) != 2 || strings.Join(alerts[0].Alert.Rules, ",") != "삼성전자" {
+		t.Errorf("negative story alerts = %+v", alerts)
+	}
+
+	// An article stored before sentiment was scored does not match a minimum either.
+	unscored := negative
+	unscored.URL = "https://n.news.naver.com/mnews/article/015/0000000003"
+	unscored.Sentiment = nil
+	if rule := w.Rules[1]; rule.Matches(&unscored) {
+		t.Errorf("unscored article matches the minimum sentiment of rule %s", rule.ID)
+	}
+
+	// After the dedup window the story may alert again.
+	clock = clock.Add(w.DedupWindow)
+	if alerts := w.Evaluate(context.Background(), syndicated); len(alerts) != 2 {
+		t.Errorf("after the window: %+v", alerts)
+	}
+
+	// Another instance, or the watchlists after a reload, sharing the dedup store.
+	other, _ := file.compile(nil)
+	other.now, other.Dedup = w.now, w.Dedup
+	if alerts := other.Evaluate(context.Background(), article); len(alerts) != 0 {
+		t.Errorf("alerted again by another instance: %+v", alerts)
+	}
+	s := NewNewsCrawlerService(DefaultConfig())
+	s.Reload(DefaultConfig(), nil, other)
+	if other.Dedup != s.AlertDedup {
+		t.Error("reloaded watchlists do not use the service's dedup store")
+	}
+
+	recorder := &recordingChannel{}
+	w.Channels["slack"] = recorder
+	w.Channels["mail"] = recorder
+	w.Send(context.Background(), alerts)
+	if len(recorder.alerts) != 2 {
+		t.Errorf("sent %d alerts", len(recorder.alerts))
+	}
+}
+
+func TestNotificationChannels(t *testing.T) {
+	var paths []string
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		json.Unmarshal(data, &body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, body)
+		if strings.Contains(r.URL.Path, "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	alert := Alert{
+		Rules:   []string{"삼성전자"},
+		Article: NewsArticle{Title: "삼성전자 실적", Source: "한국경제", URL: "https://example.com/1", Tickers: []string{"005930"}},
+	}
+	channels := []ChannelConfig{
+		{Name: "hook", Type: ChannelWebhook, URL: server.URL + "/hook"},
+		{Name: "slack", Type: ChannelSlack, URL: server.URL + "/slack"},
+		{Name: "telegram", Type: ChannelTelegram, APIBase: server.URL, Token: "123:abc", ChatID: "42"},
+	}
+	for _, cfg := range channels {
+		channel, err := newNotificationChannel(cfg, server.Client())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := channel.Send(context.Background(), alert); err != nil {
+			t.Errorf("%s: %v", cfg.Name, err)
+		}
+	}
+	wantText := "[삼성전자] 삼성전자 실적\n한국경제 · 005930\nhttps://example.com/1"
+	if paths[0] != "/hook" || bodies[0]["article"].(map[string]any)["title"] != "삼성전자 실적" {
+		t.Errorf("webhook request %s %v", paths[0], bodies[0])
+	}
+	if paths[1] != "/slack" || bodies[1]["text"] != wantText {
+		t.Errorf("slack request %s %v", paths[1], bodies[1])
+	}
+	if paths[2] != "/bot123:abc/sendMessage" || bodies[2]["chat_id"] != "42" || bodies[2]["text"] != wantText {
+		t.Errorf("telegram request %s %v", paths[2], bodies[2])
+	}
+
+	failing := &TelegramChannel{APIBase: server.URL + "/fail", Token: "123:abc", ChatID: "42", Client: server.Client()}
+	if err := failing.Send(context.Background(), alert); err == nil || strings.Contains(err.Error(), "123:abc") {
+		t.Errorf("telegram error = %v", err)
+	}
+
+	channel, err := newNotificationChannel(ChannelConfig{Name: "mail", Type: ChannelEmail, SMTPAddr: "smtp.example.com:587", From: "alerts@example.com", To: []string{"a@example.com"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent string
+	email := channel.(*EmailChannel)
+	email.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = addr + " " + from + " " + strings.Join(to, ",") + "\n" + string(msg)
+		return nil
+	}
+	if err := email.Send(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sent, "smtp.example.com:587 alerts@example.com a@example.com\n") ||
+		!strings.Contains(sent, "Subject: =?UTF-8?b?") || !strings.Contains(sent, "\r\n\r\n[삼성전자] 삼성전자 실적\r\n") {
+		t.Errorf("unexpected email:\n%s", sent)
+	}
+}
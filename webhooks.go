@@ -0,0 +1,627 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Webhook event types.
+const (
+	WebhookEventArticleCreated = "article.created" // A new article was saved
+	WebhookEventArticleUpdated = "article.updated" // A known article was updated (e.g. its AI summary was reset)
+	WebhookEventAll            = "*"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // Gave up after MaxAttempts
+)
+
+// Webhook request headers.
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery" // Stable across retries, for idempotency
+)
+
+// WebhookSubscription is a receiver registered for article events.
+type WebhookSubscription struct {
+	ID          string    `firestore:"id" json:"id"`
+	URL         string    `firestore:"url" json:"url"`
+	Events      []string  `firestore:"events" json:"events"` // Event types, or "*" for all
+	Secret      string    `firestore:"secret" json:"secret,omitempty"`
+	Description string    `firestore:"description,omitempty" json:"description,omitempty"`
+	CreatedAt   time.Time `firestore:"createdAt" json:"createdAt"`
+}
+
+// Wants reports whether the subscription receives events of the given type.
+func (s *WebhookSubscription) Wants(event string) bool {
+	return containsString(s.Events, event) || containsString(s.Events, WebhookEventAll)
+}
+
+// WebhookDelivery is a queued or completed webhook request, kept as delivery log.
+type WebhookDelivery struct {
+	ID             string     `firestore:"id" json:"id"`
+	SubscriptionID string     `firestore:"subscriptionId" json:"subscriptionId"`
+	Event          string     `firestore:"event" json:"event"`
+	Payload        string     `firestore:"payload" json:"-"`
+	Status         string     `firestore:"status" json:"status"`
+	Attempts       int        `firestore:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time  `firestore:"nextAttemptAt" json:"nextAttemptAt"`
+	LastStatusCode int        `firestore:"lastStatusCode,omitempty" json:"lastStatusCode,omitempty"`
+	LastError      string     `firestore:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt      time.Time  `firestore:"createdAt" json:"createdAt"`
+	DeliveredAt    *time.Time `firestore:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+
+	TraceContext map[string]string `firestore:"traceContext,omitempty" json:"-"` // Trace of the crawl that queued it (traceparent), continued by the requests
+}
+
+// WebhookStore persists webhook subscriptions and the delivery queue.
+type WebhookStore interface {
+	SaveSubscription(ctx context.Context, sub WebhookSubscription) error
+	GetSubscription(ctx context.Context, id string) (*WebhookSubscription, error) // nil when not found
+	DeleteSubscription(ctx context.Context, id string) error
+	ListSubscriptions(ctx context.Context) ([]WebhookSubscription, error)
+
+	SaveDelivery(ctx context.Context, delivery WebhookDelivery) error
+	// DueDeliveries returns pending deliveries whose next attempt is due, oldest first.
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	// ListDeliveries returns the latest deliveries of a subscription, newest first.
+	ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]WebhookDelivery, error)
+}
+
+// webhookPayload is the JSON body of a webhook request.
+type webhookPayload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      struct {
+		Article NewsArticle `json:"article"`
+	} `json:"data"`
+}
+
+// WebhookDispatcher queues webhook deliveries for article events and sends them,
+// retrying failed requests with exponential backoff.
+type WebhookDispatcher struct {
+	Store        WebhookStore
+	Client       *http.Client
+	MaxAttempts  int           // Attempts before a delivery is marked failed
+	BaseDelay    time.Duration // Delay before the first retry; doubled on every further retry
+	MaxDelay     time.Duration
+	PollInterval time.Duration // Interval at which the queue is checked for due retries
+	Workers      int           // Subscriptions delivered to in parallel; each one's deliveries are sent in order
+
+	now        func() time.Time
+	wakeUp     chan struct{}
+	processing sync.Mutex // Held by ProcessDue: the worker and the last pass at shutdown never send the same delivery twice
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher with the default retry policy
+// (maxAttempts attempts, 30s doubling up to 1h between them).
+func NewWebhookDispatcher(store WebhookStore, maxAttempts int) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		Store:        store,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts:  maxAttempts,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     time.Hour,
+		PollInterval: 5 * time.Second,
+		Workers:      4,
+		now:          time.Now,
+		wakeUp:       make(chan struct{}, 1),
+	}
+}
+
+// newWebhookID returns a random identifier for subscriptions, deliveries and secrets.
+func newWebhookID(prefix string, size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+// Subscribe validates and registers a subscription. A secret is generated when none is given.
+func (d *WebhookDispatcher) Subscribe(ctx context.Context, sub WebhookSubscription) (*WebhookSubscription, error) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q: an absolute http(s) URL is required", sub.URL)
+	}
+	if len(sub.Events) == 0 {
+		return nil, fmt.Errorf("at least one event type is required (%s, %s or %s)", WebhookEventArticleCreated, WebhookEventArticleUpdated, WebhookEventAll)
+	}
+	for _, event := range sub.Events {
+		switch event {
+		case WebhookEventArticleCreated, WebhookEventArticleUpdated, WebhookEventAll:
+		default:
+			return nil, fmt.Errorf("unknown event type %q", event)
+		}
+	}
+	if sub.Secret == "" {
+		sub.Secret = newWebhookID("whsec_", 24)
+	}
+	sub.ID = newWebhookID("wh_", 8)
+	sub.CreatedAt = d.now()
+	if err := d.Store.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Enqueue queues a delivery of the event to every subscription that wants it.
+// Queued deliveries survive restarts; they are sent by Run, continuing the trace of ctx.
+func (d *WebhookDispatcher) Enqueue(ctx context.Context, event string, article NewsArticle) error {
+	subs, err := d.Store.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	now := d.now()
+	payload := webhookPayload{ID: newWebhookID("evt_", 12), Type: event, CreatedAt: now}
+	payload.Data.Article = article
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook payload: %v", err)
+	}
+	traceContext := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, traceContext)
+	queued := 0
+	for _, sub := range subs {
+		if !sub.Wants(event) {
+			continue
+		}
+		delivery := WebhookDelivery{
+			ID:             newWebhookID("whd_", 12),
+			SubscriptionID: sub.ID,
+			Event:          event,
+			Payload:        string(body),
+			Status:         DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			TraceContext:   traceContext,
+		}
+		if err := d.Store.SaveDelivery(ctx, delivery); err != nil {
+			return err
+		}
+		queued++
+	}
+	if queued > 0 {
+		select {
+		case d.wakeUp <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.ProcessDue(ctx); err != nil {
+			componentLogger(LogComponentWebhooks).Warn("Webhook queue processing failed", LogKeyError, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wakeUp:
+		}
+	}
+}
+
+// ProcessDue sends the deliveries that are due and returns how many were attempted.
+// Up to Workers subscriptions are served at once, so that a slow receiver only holds
+// back its own deliveries, which are still sent oldest first.
+func (d *WebhookDispatcher) ProcessDue(ctx context.Context) (int, error) {
+	d.processing.Lock()
+	defer d.processing.Unlock()
+	due, err := d.Store.DueDeliveries(ctx, d.now(), 50)
+	if err != nil {
+		return 0, err
+	}
+	var order []string
+	bySubscription := map[string][]WebhookDelivery{}
+	for _, delivery := range due {
+		if _, ok := bySubscription[delivery.SubscriptionID]; !ok {
+			order = append(order, delivery.SubscriptionID)
+		}
+		bySubscription[delivery.SubscriptionID] = append(bySubscription[delivery.SubscriptionID], delivery)
+	}
+	queue := make(chan []WebhookDelivery, len(order))
+	for _, id := range order {
+		queue <- bySubscription[id]
+	}
+	close(queue)
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(order); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for deliveries := range queue {
+				for _, delivery := range deliveries {
+					d.attempt(ctx, delivery)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// attempt sends a delivery once and records the outcome.
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery WebhookDelivery) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(delivery.TraceContext))
+	ctx, span := tracer.Start(ctx, "webhook.deliver", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("webhook.delivery_id", delivery.ID), attribute.String("webhook.event", delivery.Event), attribute.Int("webhook.attempt", delivery.Attempts+1)))
+	defer span.End()
+	logger := componentLogger(LogComponentWebhooks).With("delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID, "event", delivery.Event)
+
+	sub, err := d.Store.GetSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		logger.Warn("Webhook delivery postponed", LogKeyError, err)
+		return
+	}
+	if sub == nil {
+		delivery.Status = DeliveryFailed
+		delivery.LastError = "subscription deleted"
+		d.saveDelivery(ctx, logger, delivery)
+		return
+	}
+
+	delivery.Attempts++
+	statusCode, err := d.send(ctx, sub, delivery)
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		deliveredAt := d.now()
+		delivery.DeliveredAt = &deliveredAt
+		d.saveDelivery(ctx, logger, delivery)
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(otelcodes.Error, err.Error())
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = DeliveryFailed
+		logger.Warn("Webhook delivery failed permanently", "url", sub.URL, "attempts", delivery.Attempts, LogKeyError, err)
+	} else {
+		delivery.NextAttemptAt = d.now().Add(d.retryDelay(delivery.Attempts))
+		logger.Info("Webhook delivery failed, will retry", "url", sub.URL, "attempt", delivery.Attempts, "max_attempts", d.MaxAttempts,
+			"next_attempt_at", delivery.NextAttemptAt, LogKeyError, err)
+	}
+	d.saveDelivery(ctx, logger, delivery)
+}
+
+func (d *WebhookDispatcher) saveDelivery(ctx context.Context, logger *slog.Logger, delivery WebhookDelivery) {
+	if err := d.Store.SaveDelivery(ctx, delivery); err != nil {
+		logger.Warn("Failed to record webhook delivery", LogKeyError, err)
+	}
+}
+
+// retryDelay is the exponential backoff after the given number of failed attempts,
+// with up to 10% jitter so that retries of a burst do not arrive together.
+func (d *WebhookDispatcher) retryDelay(attempts int) time.Duration {
+	delay := float64(d.BaseDelay) * math.Pow(2, float64(attempts-1))
+	if delay > float64(d.MaxDelay) {
+		delay = float64(d.MaxDelay)
+	}
+	return time.Duration(delay * (1 + mathrand.Float64()*0.1))
+}
+
+// send POSTs a signed delivery, with the trace context of ctx. Any non-2xx response is an error.
+func (d *WebhookDispatcher) send(ctx context.Context, sub *WebhookSubscription, delivery WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "news-crawler-webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, "t="+timestamp+",v1="+SignWebhookPayload(sub.Secret, timestamp, []byte(delivery.Payload)))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>" with the subscription
+// secret. Receivers recompute it to authenticate a request and reject stale timestamps.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks queues article event deliveries, if webhooks are configured.
+// Queueing failures are logged and never fail the crawl.
+func (s *NewsCrawlerService) notifyWebhooks(ctx context.Context, event string, article NewsArticle) {
+	if s.Webhooks == nil {
+		return
+	}
+	if err := s.Webhooks.Enqueue(ctx, event, article); err != nil {
+		contextLogger(ctx, LogComponentWebhooks).Warn("Failed to queue webhooks", "event", event, LogKeyArticleURL, article.URL, LogKeyError, err)
+	}
+}
+
+// Firestore collections of the webhook store.
+const (
+	webhookSubscriptionsCollection = "webhookSubscriptions"
+	webhookDeliveriesCollection    = "webhookDeliveries"
+)
+
+// FirestoreWebhookStore is the WebhookStore backed by Firestore.
+type FirestoreWebhookStore struct {
+	FirestoreArticleStore // Shares the client setup
+}
+
+// SaveSubscription implements WebhookStore.
+func (s *FirestoreWebhookStore) SaveSubscription(ctx context.Context, sub WebhookSubscription) error {
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.Collection(webhookSubscriptionsCollection).Doc(sub.ID).Set(ctx, sub); err != nil {
+		return fmt.Errorf("error saving webhook subscription: %v", err)
+	}
+	return nil
+}
+
+// GetSubscription implements WebhookStore.
+func (s *FirestoreWebhookStore) GetSubscription(ctx context.Context, id string) (*WebhookSubscription, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	snap, err := client.Collection(webhookSubscriptionsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading webhook subscription: %v", err)
+	}
+	var sub WebhookSubscription
+	if err := snap.DataTo(&sub); err != nil {
+		return nil, fmt.Errorf("error decoding webhook subscription: %v", err)
+	}
+	return &sub, nil
+}
+
+// DeleteSubscription implements WebhookStore.
+func (s *FirestoreWebhookStore) DeleteSubscription(ctx context.Context, id string) error {
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.Collection(webhookSubscriptionsCollection).Doc(id).Delete(ctx); err != nil {
+		return fmt.Errorf("error deleting webhook subscription: %v", err)
+	}
+	return nil
+}
+
+// ListSubscriptions implements WebhookStore.
+func (s *FirestoreWebhookStore) ListSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	var subs []WebhookSubscription
+	iter := client.Collection(webhookSubscriptionsCollection).OrderBy("createdAt", firestore.Asc).Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing webhook subscriptions: %v", err)
+		}
+		var sub WebhookSubscription
+		if err := doc.DataTo(&sub); err != nil {
+			componentLogger(LogComponentWebhooks).Warn("Failed to decode webhook subscription", "subscription_id", doc.Ref.ID, LogKeyError, err)
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// SaveDelivery implements WebhookStore.
+func (s *FirestoreWebhookStore) SaveDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.Collection(webhookDeliveriesCollection).Doc(delivery.ID).Set(ctx, delivery); err != nil {
+		return fmt.Errorf("error saving webhook delivery: %v", err)
+	}
+	return nil
+}
+
+// DueDeliveries implements WebhookStore.
+func (s *FirestoreWebhookStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, func(c *firestore.Client) firestore.Query {
+		return c.Collection(webhookDeliveriesCollection).
+			Where("status", "==", DeliveryPending).
+			Where("nextAttemptAt", "<=", now).
+			OrderBy("nextAttemptAt", firestore.Asc).
+			Limit(limit)
+	})
+}
+
+// ListDeliveries implements WebhookStore.
+func (s *FirestoreWebhookStore) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, func(c *firestore.Client) firestore.Query {
+		return c.Collection(webhookDeliveriesCollection).
+			Where("subscriptionId", "==", subscriptionID).
+			OrderBy("createdAt", firestore.Desc).
+			Limit(limit)
+	})
+}
+
+func (s *FirestoreWebhookStore) queryDeliveries(ctx context.Context, build func(*firestore.Client) firestore.Query) ([]WebhookDelivery, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	docs, err := build(client).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook deliveries: %v", err)
+	}
+	deliveries := make([]WebhookDelivery, 0, len(docs))
+	for _, doc := range docs {
+		var delivery WebhookDelivery
+		if err := doc.DataTo(&delivery); err != nil {
+			componentLogger(LogComponentWebhooks).Warn("Failed to decode webhook delivery", "delivery_id", doc.Ref.ID, LogKeyError, err)
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// MemoryWebhookStore is an in-process WebhookStore (ARTICLE_STORE=memory and tests).
+type MemoryWebhookStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]WebhookSubscription
+	deliveries    map[string]WebhookDelivery
+}
+
+// NewMemoryWebhookStore creates an empty MemoryWebhookStore.
+func NewMemoryWebhookStore() *MemoryWebhookStore {
+	return &MemoryWebhookStore{subscriptions: map[string]WebhookSubscription{}, deliveries: map[string]WebhookDelivery{}}
+}
+
+// SaveSubscription implements WebhookStore.
+func (s *MemoryWebhookStore) SaveSubscription(ctx context.Context, sub WebhookSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[sub.ID] = sub
+	return nil
+}
+
+// GetSubscription implements WebhookStore.
+func (s *MemoryWebhookStore) GetSubscription(ctx context.Context, id string) (*WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &sub, nil
+}
+
+// DeleteSubscription implements WebhookStore.
+func (s *MemoryWebhookStore) DeleteSubscription(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscriptions, id)
+	return nil
+}
+
+// ListSubscriptions implements WebhookStore.
+func (s *MemoryWebhookStore) ListSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := make([]WebhookSubscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+	return subs, nil
+}
+
+// SaveDelivery implements WebhookStore.
+func (s *MemoryWebhookStore) SaveDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+// DueDeliveries implements WebhookStore.
+func (s *MemoryWebhookStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// ListDeliveries implements WebhookStore.
+func (s *MemoryWebhookStore) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deliveries []WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
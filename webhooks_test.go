@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver is a local receiver that records requests and answers with scripted statuses.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int // Status of each successive request; 200 once exhausted
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestDispatcher(t *testing.T, receiver *webhookReceiver, events ...string) (*WebhookDispatcher, *WebhookSubscription, *time.Time) {
+	t.Helper()
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	clock := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+	d := NewWebhookDispatcher(NewMemoryWebhookStore(), 3)
+	d.now = func() time.Time { return clock }
+	sub, err := d.Subscribe(context.Background(), WebhookSubscription{URL: server.URL + "/hook", Events: events})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, sub, &clock
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	receiver := &webhookReceiver{}
+	d, sub, clock := newTestDispatcher(t, receiver, WebhookEventArticleCreated)
+	if !strings.HasPrefix(sub.Secret, "whsec_") {
+		t.Fatalf("generated secret = %q", sub.Secret)
+	}
+
+	article := NewsArticle{Title: "삼성전자 실적", URL: "https://example.com/1"}
+	if err := d.Enqueue(context.Background(), WebhookEventArticleCreated, article); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.ProcessDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ProcessDue = %d, %v", n, err)
+	}
+
+	if len(receiver.requests) != 1 {
+		t.Fatalf("receiver got %d requests", len(receiver.requests))
+	}
+	req, body := receiver.requests[0], receiver.bodies[0]
+	timestamp := strconv.FormatInt(clock.Unix(), 10)
+	want := "t=" + timestamp + ",v1=" + SignWebhookPayload(sub.Secret, timestamp, body)
+	if got := req.Header.Get(WebhookSignatureHeader); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.Header.Get(WebhookEventHeader) != WebhookEventArticleCreated || req.Header.Get(WebhookDeliveryHeader) == "" {
+		t.Errorf("unexpected headers: %v", req.Header)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Type != WebhookEventArticleCreated || payload.Data.Article.Title != article.Title {
+		t.Errorf("unexpected payload: %s", body)
+	}
+
+	deliveries, _ := d.Store.ListDeliveries(context.Background(), sub.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].Status != DeliverySucceeded || deliveries[0].LastStatusCode != 200 {
+		t.Errorf("unexpected delivery log: %+v", deliveries)
+	}
+}
+
+func TestWebhookDeliveryRetries(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError}}
+	d, sub, clock := newTestDispatcher(t, receiver, WebhookEventAll)
+	ctx := context.Background()
+
+	d.Enqueue(ctx, WebhookEventArticleUpdated, NewsArticle{Title: "a"})
+	d.ProcessDue(ctx)
+	deliveries, _ := d.Store.ListDeliveries(ctx, sub.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].Status != DeliveryPending || deliveries[0].LastStatusCode != 500 {
+		t.Fatalf("after a failed attempt: %+v", deliveries)
+	}
+	if logged, _ := json.Marshal(deliveries[0]); strings.Contains(string(logged), "deliveredAt") {
+		t.Errorf("undelivered entry has a delivery time: %s", logged)
+	}
+
+	// Not due before the backoff has elapsed.
+	if n, _ := d.ProcessDue(ctx); n != 0 {
+		t.Fatalf("retried %d deliveries before the backoff", n)
+	}
+	*clock = clock.Add(d.BaseDelay * 2)
+	if n, _ := d.ProcessDue(ctx); n != 1 {
+		t.Fatalf("retried %d deliveries after the backoff, want 1", n)
+	}
+	deliveries, _ = d.Store.ListDeliveries(ctx, sub.ID, 10)
+	if deliveries[0].Status != DeliverySucceeded || deliveries[0].Attempts != 2 {
+		t.Errorf("after the retry: %+v", deliveries[0])
+	}
+	if receiver.requests[0].Header.Get(WebhookDeliveryHeader) != receiver.requests[1].Header.Get(WebhookDeliveryHeader) {
+		t.Error("the delivery ID changed between retries")
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{500, 502, 503, 504}}
+	d, sub, clock := newTestDispatcher(t, receiver, WebhookEventAll)
+	ctx := context.Background()
+
+	d.Enqueue(ctx, WebhookEventArticleCreated, NewsArticle{Title: "a"})
+	for i := 0; i < 5; i++ {
+		d.ProcessDue(ctx)
+		*clock = clock.Add(d.MaxDelay * 2)
+	}
+	deliveries, _ := d.Store.ListDeliveries(ctx, sub.ID, 10)
+	if deliveries[0].Status != DeliveryFailed || deliveries[0].Attempts != d.MaxAttempts || len(receiver.requests) != d.MaxAttempts {
+		t.Errorf("delivery = %+v after %d requests", deliveries[0], len(receiver.requests))
+	}
+}
+
+func TestWebhookEventFilter(t *testing.T) {
+	receiver := &webhookReceiver{}
+	d, sub, _ := newTestDispatcher(t, receiver, WebhookEventArticleUpdated)
+	ctx := context.Background()
+
+	d.Enqueue(ctx, WebhookEventArticleCreated, NewsArticle{Title: "a"})
+	d.ProcessDue(ctx)
+	if deliveries, _ := d.Store.ListDeliveries(ctx, sub.ID, 10); len(deliveries) != 0 || len(receiver.requests) != 0 {
+		t.Errorf("unwanted event was delivered: %+v", deliveries)
+	}
+
+	if _, err := d.Subscribe(ctx, WebhookSubscription{URL: "ftp://example.com", Events: []string{WebhookEventAll}}); err == nil {
+		t.Error("expected an error for a non-http URL")
+	}
+	if _, err := d.Subscribe(ctx, WebhookSubscription{URL: "https://example.com", Events: []string{"article.deleted"}}); err == nil {
+		t.Error("expected an error for an unknown event type")
+	}
+}
+
+func TestKnownArticleIsNotUpdated(t *testing.T) {
+	receiver := &webhookReceiver{}
+	d, sub, _ := newTestDispatcher(t, receiver, WebhookEventArticleUpdated)
+	ctx := context.Background()
+	s := NewNewsCrawlerService(DefaultConfig())
+	s.Store = NewMemoryArticleStore()
+	s.Webhooks = d
+	article := NewsArticle{URL: "https://example.com/pending", Title: "요약 대기"}
+	if err := s.Store.SaveArticle(ctx, article); err != nil {
+		t.Fatal(err)
+	}
+
+	health := NewParserHealth(ParserHealthThresholds{}, "")
+	if !s.articleAlreadyKnown(ctx, article.URL, health) || !s.articleAlreadyKnown(ctx, article.URL, health) {
+		t.Fatal("stored article not reported as known")
+	}
+	if deliveries, _ := d.Store.ListDeliveries(ctx, sub.ID, 10); len(deliveries) != 0 {
+		t.Errorf("article.updated sent for an unchanged article: %+v", deliveries)
+	}
+	if s.articleAlreadyKnown(ctx, "https://example.com/new", health) {
+		t.Error("unknown article reported as known")
+	}
+}
+
+func TestSlowReceiverDoesNotHoldTheQueue(t *testing.T) {
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stalled.Close()
+	defer close(release)
+
+	receiver := &webhookReceiver{}
+	d, sub, clock := newTestDispatcher(t, receiver, WebhookEventAll)
+	ctx := context.Background()
+	slow, err := d.Subscribe(ctx, WebhookSubscription{URL: stalled.URL, Events: []string{WebhookEventAll}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Due first, so that a sequential queue would be stuck on it.
+	d.Store.SaveDelivery(ctx, WebhookDelivery{ID: "whd_slow", SubscriptionID: slow.ID, Event: WebhookEventArticleCreated, Payload: "{}",
+		Status: DeliveryPending, NextAttemptAt: clock.Add(-time.Minute), CreatedAt: clock.Add(-time.Minute)})
+	d.Enqueue(ctx, WebhookEventArticleCreated, NewsArticle{Title: "a"})
+	*clock = clock.Add(time.Second)
+	d.Enqueue(ctx, WebhookEventArticleCreated, NewsArticle{Title: "b"})
+
+	done := make(chan struct{})
+	go func() {
+		d.ProcessDue(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries, _ := d.Store.ListDeliveries(ctx, sub.ID, 10)
+		if len(deliveries) == 2 && deliveries[0].Status == DeliverySucceeded && deliveries[1].Status == DeliverySucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries to the responsive receiver waited for the stalled one: %+v", deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("ProcessDue returned before the stalled receiver answered")
+	default:
+	}
+	var titles []string
+	for _, body := range receiver.bodies {
+		var payload webhookPayload
+		json.Unmarshal(body, &payload)
+		titles = append(titles, payload.Data.Article.Title)
+	}
+	if strings.Join(titles, ",") != "a,b" {
+		t.Errorf("deliveries sent out of order: %v", titles)
+	}
+}